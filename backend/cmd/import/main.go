@@ -0,0 +1,80 @@
+// Утилита импорта клиентов и абонементов из CSV.
+//
+//	go run ./cmd/import -file members.csv -dry-run
+//	go run ./cmd/import -file members.csv -batch 100
+//
+// Подключение к БД берется из .env так же, как у сервера.
+package main
+
+import (
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/importer"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	path := flag.String("file", "", "CSV-файл (колонки: "+strings.Join(importer.Columns, ", ")+")")
+	dryRun := flag.Bool("dry-run", false, "только проверить файл, ничего не сохраняя")
+	batch := flag.Int("batch", 0, "фиксировать каждые N строк (0 — весь файл одной транзакцией)")
+	startRow := flag.Int("start-row", 0, "продолжить с указанной строки файла")
+	update := flag.Bool("update", false, "обновлять данные клиентов с уже существующим email")
+	asJSON := flag.Bool("json", false, "вывести отчет в JSON")
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Ошибка открытия файла: %v", err)
+	}
+	defer file.Close()
+
+	if err := database.InitDB(); err != nil {
+		log.Fatalf("Ошибка инициализации БД: %v", err)
+	}
+	defer database.CloseDB()
+
+	report, err := importer.Import(database.DB, file, importer.Options{
+		DryRun:         *dryRun,
+		BatchSize:      *batch,
+		StartRow:       *startRow,
+		UpdateExisting: *update,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка импорта: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, row := range report.Rows {
+			if len(row.Errors) > 0 {
+				fmt.Printf("строка %d (%s): %s — %s\n", row.Row, row.Email, row.Status, strings.Join(row.Errors, "; "))
+			}
+		}
+		fmt.Printf("Строк: %d, создано: %d, обновлено: %d, пропущено: %d, ошибок: %d\n",
+			report.TotalRows, report.Created, report.Updated, report.Skipped, report.Failed)
+		switch {
+		case report.DryRun:
+			fmt.Println("Проверка без сохранения (dry-run)")
+		case !report.Committed:
+			fmt.Println("Изменения не сохранены")
+		}
+		if report.NextRow > 0 {
+			fmt.Printf("Импорт прерван, продолжить: -start-row %d\n", report.NextRow)
+		}
+	}
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"fitness-club/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Аналитика посещаемости за период from–to (по умолчанию текущий месяц), как и финансовые отчеты,
+// отдается в JSON или CSV. Отмененные и удаленные тренировки не учитываются. Доли — в процентах.
+// Заполняемость везде одна: записанные (без отмененных записей, вместе с пробными занятиями
+// лидов) к числу мест. Посещаемость и неявки считаются только по прошедшим тренировкам, где
+// посещение отмечено (тренировка завершена или хотя бы один участник отмечен посетившим):
+// записанный участник такой тренировки, которого не отметили посетившим, считается неявкой.
+
+// trainingSeats — занятые места на тренировках: записи клиентов и пробные занятия лидов.
+// Статус пробного занятия booked приводится к registered, остальные статусы совпадают.
+const trainingSeats = `(
+	SELECT training_id, status FROM training_participants
+	UNION ALL
+	SELECT training_id, CASE status WHEN 'booked' THEN 'registered' ELSE status END FROM lead_trials
+)`
+
+// percent возвращает долю part от whole в процентах или nil, если считать не от чего
+func percent(part, whole int) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	p := round2(100 * float64(part) / float64(whole))
+	return &p
+}
+
+func formatPercent(p *float64) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*p, 'f', 2, 64)
+}
+
+// TrainingAttendanceRow — заполняемость и посещаемость одной тренировки
+type TrainingAttendanceRow struct {
+	TrainingID      int       `json:"training_id"`
+	Title           string    `json:"title"`
+	Type            string    `json:"type"`
+	HallType        string    `json:"hall_type"`
+	TrainerName     string    `json:"trainer_name"`
+	StartTime       time.Time `json:"start_time"`
+	MaxParticipants int       `json:"max_participants"`
+	Registered      int       `json:"registered"` // записи без отмененных
+	Attended        int       `json:"attended"`
+	Cancelled       int       `json:"cancelled"`
+	FillRate        *float64  `json:"fill_rate"`       // записались / мест
+	AttendanceRate  *float64  `json:"attendance_rate"` // посетили / записались
+	NoShowRate      *float64  `json:"no_show_rate"`
+}
+
+// GetTrainingAttendanceStats возвращает заполняемость, посещаемость и неявки по каждой тренировке
+func GetTrainingAttendanceStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/trainings - посещаемость тренировок")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT t.id, t.title, t.type, t.hall_type, COALESCE(u.name, ''), t.start_time, t.max_participants,
+		       t.start_time < NOW() AND (t.status = 'completed' OR COUNT(tp.training_id) FILTER (WHERE tp.status = 'attended') > 0),
+		       COUNT(tp.training_id) FILTER (WHERE tp.status <> 'cancelled'),
+		       COUNT(tp.training_id) FILTER (WHERE tp.status = 'attended'),
+		       COUNT(tp.training_id) FILTER (WHERE tp.status = 'cancelled')
+		FROM trainings t
+		LEFT JOIN users u ON t.trainer_id = u.id
+		LEFT JOIN `+trainingSeats+` tp ON tp.training_id = t.id
+		WHERE t.deleted_at IS NULL AND t.status <> 'cancelled' AND t.start_time >= $1 AND t.start_time < $2
+		GROUP BY t.id, u.name
+		ORDER BY t.start_time
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]TrainingAttendanceRow, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var t TrainingAttendanceRow
+		var marked bool
+		err := rows.Scan(&t.TrainingID, &t.Title, &t.Type, &t.HallType, &t.TrainerName, &t.StartTime,
+			&t.MaxParticipants, &marked, &t.Registered, &t.Attended, &t.Cancelled)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		t.FillRate = percent(t.Registered, t.MaxParticipants)
+		if marked {
+			t.AttendanceRate = percent(t.Attended, t.Registered)
+			t.NoShowRate = percent(t.Registered-t.Attended, t.Registered)
+		}
+		result = append(result, t)
+		records = append(records, []string{
+			strconv.Itoa(t.TrainingID), t.Title, t.Type, t.HallType, t.TrainerName, t.StartTime.Format("2006-01-02 15:04"),
+			strconv.Itoa(t.MaxParticipants), strconv.Itoa(t.Registered), strconv.Itoa(t.Attended), strconv.Itoa(t.Cancelled),
+			formatPercent(t.FillRate), formatPercent(t.AttendanceRate), formatPercent(t.NoShowRate),
+		})
+	}
+
+	report := newReport("training-attendance", from, to)
+	report.Rows = result
+	writeReport(w, r, report, []string{"Тренировка", "Название", "Тип", "Зал", "Тренер", "Начало", "Мест",
+		"Записано", "Посетили", "Отменили", "Заполняемость, %", "Посещаемость, %", "Неявки, %"}, records)
+}
+
+// HallPopularityRow — популярность тренировок в зале определенного типа
+type HallPopularityRow struct {
+	HallType       string   `json:"hall_type"`
+	Trainings      int      `json:"trainings"`
+	Capacity       int      `json:"capacity"` // сумма мест
+	Registered     int      `json:"registered"`
+	Attended       int      `json:"attended"`
+	FillRate       *float64 `json:"fill_rate"`
+	AttendanceRate *float64 `json:"attendance_rate"` // по прошедшим тренировкам с отмеченным посещением
+	NoShowRate     *float64 `json:"no_show_rate"`
+}
+
+// GetHallPopularityStats возвращает популярность тренировок по типам залов
+func GetHallPopularityStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/halls - популярность залов")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		WITH t AS (
+			SELECT t.id, t.hall_type, t.max_participants,
+			       t.start_time < NOW() AND (t.status = 'completed' OR COUNT(tp.training_id) FILTER (WHERE tp.status = 'attended') > 0) AS marked,
+			       COUNT(tp.training_id) FILTER (WHERE tp.status <> 'cancelled') AS registered,
+			       COUNT(tp.training_id) FILTER (WHERE tp.status = 'attended') AS attended
+			FROM trainings t
+			LEFT JOIN `+trainingSeats+` tp ON tp.training_id = t.id
+			WHERE t.deleted_at IS NULL AND t.status <> 'cancelled' AND t.start_time >= $1 AND t.start_time < $2
+			GROUP BY t.id
+		)
+		SELECT hall_type, COUNT(*), COALESCE(SUM(max_participants), 0), COALESCE(SUM(registered), 0),
+		       COALESCE(SUM(attended), 0), COALESCE(SUM(registered) FILTER (WHERE marked), 0)
+		FROM t
+		GROUP BY hall_type
+		ORDER BY SUM(registered) DESC
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]HallPopularityRow, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var h HallPopularityRow
+		var registeredMarked int
+		err := rows.Scan(&h.HallType, &h.Trainings, &h.Capacity, &h.Registered, &h.Attended, &registeredMarked)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		h.FillRate = percent(h.Registered, h.Capacity)
+		h.AttendanceRate = percent(h.Attended, registeredMarked)
+		h.NoShowRate = percent(registeredMarked-h.Attended, registeredMarked)
+		result = append(result, h)
+		records = append(records, []string{
+			h.HallType, strconv.Itoa(h.Trainings), strconv.Itoa(h.Capacity), strconv.Itoa(h.Registered),
+			strconv.Itoa(h.Attended), formatPercent(h.FillRate), formatPercent(h.AttendanceRate), formatPercent(h.NoShowRate),
+		})
+	}
+
+	report := newReport("hall-popularity", from, to)
+	report.Rows = result
+	writeReport(w, r, report, []string{"Зал", "Тренировок", "Мест", "Записано", "Посетили",
+		"Заполняемость, %", "Посещаемость, %", "Неявки, %"}, records)
+}
+
+// HeatmapCell — тренировки, начинающиеся в определенный час дня недели
+type HeatmapCell struct {
+	Weekday    int      `json:"weekday"` // 1 — понедельник, 7 — воскресенье
+	Hour       int      `json:"hour"`
+	Trainings  int      `json:"trainings"`
+	Registered int      `json:"registered"`
+	FillRate   *float64 `json:"fill_rate"`
+}
+
+// GetAttendanceHeatmap возвращает тепловую карту записей по дням недели и часам начала тренировок.
+// Фильтр hall_type ограничивает карту одним типом зала; пустые ячейки не возвращаются.
+func GetAttendanceHeatmap(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/heatmap - тепловая карта посещаемости")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		WITH t AS (
+			SELECT t.start_time, t.max_participants,
+			       COUNT(tp.training_id) FILTER (WHERE tp.status <> 'cancelled') AS registered
+			FROM trainings t
+			LEFT JOIN `+trainingSeats+` tp ON tp.training_id = t.id
+			WHERE t.deleted_at IS NULL AND t.status <> 'cancelled' AND t.start_time >= $1 AND t.start_time < $2
+			AND ($3 = '' OR t.hall_type = $3)
+			GROUP BY t.id
+		)
+		SELECT EXTRACT(ISODOW FROM start_time)::int, EXTRACT(HOUR FROM start_time)::int,
+		       COUNT(*), SUM(registered), SUM(max_participants)
+		FROM t
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, from, to, r.URL.Query().Get("hall_type"))
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	cells := make([]HeatmapCell, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var c HeatmapCell
+		var capacity int
+		if err := rows.Scan(&c.Weekday, &c.Hour, &c.Trainings, &c.Registered, &capacity); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		c.FillRate = percent(c.Registered, capacity)
+		cells = append(cells, c)
+		records = append(records, []string{strconv.Itoa(c.Weekday), strconv.Itoa(c.Hour), strconv.Itoa(c.Trainings),
+			strconv.Itoa(c.Registered), formatPercent(c.FillRate)})
+	}
+
+	report := newReport("attendance-heatmap", from, to)
+	report.Rows = cells
+	writeReport(w, r, report, []string{"День недели", "Час", "Тренировок", "Записано", "Заполняемость, %"}, records)
+}
+
+// TrainerUtilizationRow — загрузка тренера: проведенные часы относительно часов в сменах
+type TrainerUtilizationRow struct {
+	TrainerID      int      `json:"trainer_id"`
+	Name           string   `json:"name"`
+	Trainings      int      `json:"trainings"`
+	HoursTaught    float64  `json:"hours_taught"`
+	HoursAvailable float64  `json:"hours_available"` // часы смен
+	Utilization    *float64 `json:"utilization"`     // без смен — не считается
+	FillRate       *float64 `json:"fill_rate"`
+}
+
+// GetTrainerUtilizationStats возвращает загрузку тренеров. Учитывается прошедшая часть периода:
+// проведенные (завершенные) тренировки и смены, начавшиеся до текущего момента.
+func GetTrainerUtilizationStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/trainers - загрузка тренеров")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	until := to
+	if now := time.Now(); now.Before(until) {
+		until = now
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT u.id, u.name,
+		       COALESCE(tt.trainings, 0), COALESCE(tt.minutes, 0), COALESCE(tt.registered, 0), COALESCE(tt.capacity, 0),
+		       COALESCE((
+		           SELECT SUM(EXTRACT(EPOCH FROM sh.end_time - sh.start_time)) / 60
+		           FROM shifts sh JOIN employees e ON sh.employee_id = e.id
+		           WHERE e.user_id = u.id AND sh.start_time >= $1 AND sh.start_time < $2
+		       ), 0)
+		FROM users u
+		LEFT JOIN (
+			SELECT t.trainer_id, COUNT(*) AS trainings, SUM(t.duration_minutes) AS minutes,
+			       SUM(t.max_participants) AS capacity,
+			       SUM((SELECT COUNT(*) FROM `+trainingSeats+` tp
+			            WHERE tp.training_id = t.id AND tp.status <> 'cancelled')) AS registered
+			FROM trainings t
+			WHERE t.deleted_at IS NULL AND t.status = 'completed' AND t.start_time >= $1 AND t.start_time < $2
+			GROUP BY t.trainer_id
+		) tt ON tt.trainer_id = u.id
+		WHERE (u.role = 'trainer' AND u.deleted_at IS NULL) OR tt.trainings > 0
+		ORDER BY u.name
+	`, from, until)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]TrainerUtilizationRow, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var t TrainerUtilizationRow
+		var minutes, registered, capacity int
+		var availableMinutes float64
+		err := rows.Scan(&t.TrainerID, &t.Name, &t.Trainings, &minutes, &registered, &capacity, &availableMinutes)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		t.HoursTaught = round2(float64(minutes) / 60)
+		t.HoursAvailable = round2(availableMinutes / 60)
+		t.Utilization = percent(minutes, int(availableMinutes))
+		t.FillRate = percent(registered, capacity)
+		result = append(result, t)
+		records = append(records, []string{
+			strconv.Itoa(t.TrainerID), t.Name, strconv.Itoa(t.Trainings),
+			strconv.FormatFloat(t.HoursTaught, 'f', 2, 64), strconv.FormatFloat(t.HoursAvailable, 'f', 2, 64),
+			formatPercent(t.Utilization), formatPercent(t.FillRate),
+		})
+	}
+
+	report := newReport("trainer-utilization", from, to)
+	report.Rows = result
+	writeReport(w, r, report, []string{"Тренер", "Имя", "Тренировок", "Проведено часов", "Часов в сменах",
+		"Загрузка, %", "Заполняемость, %"}, records)
+}
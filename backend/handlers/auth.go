@@ -43,7 +43,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	err := database.DB.QueryRow(`
 		SELECT id, name, email, password, role, created_at 
 		FROM users 
-		WHERE LOWER(TRIM(email)) = LOWER(TRIM($1))
+		WHERE LOWER(TRIM(email)) = LOWER(TRIM($1)) AND deleted_at IS NULL
 	`, req.Email).Scan(&user.ID, &user.Name, &user.Email, &passwordFromDB, &user.Role, &user.CreatedAt)
 
 	if err != nil {
@@ -169,8 +169,19 @@ func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Код входа в клуб для QR, меняется каждые CHECKIN_TOKEN_TTL_SECONDS
+	resp := currentUserResponse{User: user}
+	resp.CheckinToken, resp.CheckinTokenExpiresAt = checkinToken(user.ID, time.Now())
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// currentUserResponse — текущий пользователь с кодом входа в клуб
+type currentUserResponse struct {
+	models.User
+	CheckinToken          string    `json:"checkin_token"`
+	CheckinTokenExpiresAt time.Time `json:"checkin_token_expires_at"`
 }
 
 // generateToken генерирует случайный токен
@@ -182,6 +193,42 @@ func generateToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// getSessionUser возвращает пользователя активной сессии по токену из заголовка Authorization
+func getSessionUser(r *http.Request) (models.User, error) {
+	var user models.User
+	err := database.DB.QueryRow(`
+		SELECT u.id, u.name, u.email, u.role, u.created_at
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.token = $1 AND s.expires_at > NOW()
+	`, r.Header.Get("Authorization")).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt)
+	return user, err
+}
+
+// canAccessClient проверяет доступ пользователя к данным клиента:
+// сам клиент, администратор или тренер, у которого клиент записан на тренировку
+func canAccessClient(user models.User, clientID int) (bool, error) {
+	if user.Role == "admin" {
+		return true, nil
+	}
+	var allowed bool
+	err := database.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM clients c
+			WHERE c.id = $1 AND (
+				c.user_id = $2
+				OR ($3 = 'trainer' AND EXISTS(
+					SELECT 1
+					FROM training_participants tp
+					JOIN trainings t ON tp.training_id = t.id
+					WHERE tp.user_id = c.user_id AND t.trainer_id = $2
+				))
+			)
+		)
+	`, clientID, user.ID, user.Role).Scan(&allowed)
+	return allowed, err
+}
+
 // Register обрабатывает регистрацию нового пользователя
 func Register(w http.ResponseWriter, r *http.Request) {
     var req struct {
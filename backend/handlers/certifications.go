@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxCertificationDocumentSize — максимальный размер скана сертификата
+const maxCertificationDocumentSize = 5 << 20
+
+// certificationDocumentTypes — допустимые форматы скана сертификата
+var certificationDocumentTypes = map[string]bool{
+	"application/pdf": true, "image/jpeg": true, "image/png": true,
+}
+
+const certificationColumns = `
+	c.id, c.employee_id, u.name, c.cert_type, c.issuer, c.issued_at, c.expires_at,
+	COALESCE(c.document_name, ''), c.document IS NOT NULL, c.created_at
+`
+
+func scanCertification(row interface{ Scan(...interface{}) error }) (models.Certification, error) {
+	var c models.Certification
+	var issuedAt, expiresAt sql.NullTime
+	err := row.Scan(&c.ID, &c.EmployeeID, &c.EmployeeName, &c.CertType, &c.Issuer, &issuedAt, &expiresAt,
+		&c.DocumentName, &c.HasDocument, &c.CreatedAt)
+	if err != nil {
+		return c, err
+	}
+	if issuedAt.Valid {
+		c.IssuedAt = &issuedAt.Time
+	}
+	if expiresAt.Valid {
+		c.ExpiresAt = &expiresAt.Time
+	}
+	return c, nil
+}
+
+// missingQualifications возвращает сертификаты, которые требуются для тренировки в зале hallType
+// типа trainingType, но не действуют у тренера (пользователя) на дату тренировки
+func missingQualifications(q queryer, trainerID int, hallType, trainingType string, at time.Time) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT DISTINCT qr.cert_type
+		FROM qualification_requirements qr
+		WHERE (qr.hall_type IS NULL OR qr.hall_type = $2)
+		AND (qr.training_type IS NULL OR qr.training_type = $3)
+		AND NOT EXISTS (
+			SELECT 1 FROM certifications c
+			JOIN employees e ON c.employee_id = e.id
+			WHERE e.user_id = $1 AND c.cert_type = qr.cert_type
+			AND (c.issued_at IS NULL OR c.issued_at <= $4::date)
+			AND (c.expires_at IS NULL OR c.expires_at >= $4::date)
+		)
+		ORDER BY qr.cert_type
+	`, trainerID, hallType, trainingType, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var certType string
+		if err := rows.Scan(&certType); err != nil {
+			return nil, err
+		}
+		missing = append(missing, certType)
+	}
+	return missing, rows.Err()
+}
+
+// GetCertifications возвращает сертификаты сотрудника
+func GetCertifications(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/employees/%d/certifications - получение сертификатов", id)
+
+	rows, err := database.DB.Query(`
+		SELECT `+certificationColumns+`
+		FROM certifications c
+		JOIN employees e ON c.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE c.employee_id = $1
+		ORDER BY c.cert_type, c.expires_at DESC NULLS FIRST
+	`, id)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	certifications := make([]models.Certification, 0)
+	for rows.Next() {
+		c, err := scanCertification(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		certifications = append(certifications, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certifications)
+}
+
+// CreateCertification добавляет сертификат сотруднику. Принимает multipart/form-data с полями
+// cert_type, issuer, issued_at, expires_at (YYYY-MM-DD) и необязательным файлом document (PDF, JPEG, PNG).
+func CreateCertification(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/employees/%d/certifications - добавление сертификата", id)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCertificationDocumentSize+1<<20)
+	if err := r.ParseMultipartForm(maxCertificationDocumentSize); err != nil {
+		http.Error(w, "Ожидается multipart/form-data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	certType := strings.ToLower(strings.TrimSpace(r.FormValue("cert_type")))
+	issuer := strings.TrimSpace(r.FormValue("issuer"))
+	if certType == "" || issuer == "" {
+		http.Error(w, "Тип сертификата и организация, выдавшая его, обязательны", http.StatusBadRequest)
+		return
+	}
+	dates := map[string]*time.Time{}
+	for _, field := range []string{"issued_at", "expires_at"} {
+		value := r.FormValue(field)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Неверный формат "+field+", ожидается YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		dates[field] = &parsed
+	}
+	issuedAt, expiresAt := dates["issued_at"], dates["expires_at"]
+	if issuedAt != nil && expiresAt != nil && expiresAt.Before(*issuedAt) {
+		http.Error(w, "Срок действия не может закончиться раньше даты выдачи", http.StatusBadRequest)
+		return
+	}
+
+	var document []byte
+	var documentName, documentType *string
+	file, header, err := r.FormFile("document")
+	if err == nil {
+		defer file.Close()
+		if document, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Ошибка чтения файла: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		detected := http.DetectContentType(document)
+		if !certificationDocumentTypes[detected] {
+			http.Error(w, "Документ должен быть в формате PDF, JPEG или PNG", http.StatusBadRequest)
+			return
+		}
+		documentName, documentType = &header.Filename, &detected
+	} else if err != http.ErrMissingFile {
+		http.Error(w, "Ошибка загрузки файла: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := employeeName(database.DB, id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Сотрудник не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanCertification(database.DB.QueryRow(`
+		WITH c AS (
+			INSERT INTO certifications (employee_id, cert_type, issuer, issued_at, expires_at,
+			                            document, document_name, document_type)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING *
+		)
+		SELECT `+certificationColumns+`
+		FROM c
+		JOIN employees e ON c.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+	`, id, certType, issuer, issuedAt, expiresAt, document, documentName, documentType))
+	if err != nil {
+		log.Printf("Ошибка создания сертификата: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Сотруднику %d добавлен сертификат %s", id, certType)
+}
+
+// GetCertificationDocument отдает загруженный скан сертификата
+func GetCertificationDocument(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+	certID, err := strconv.Atoi(vars["certId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/employees/%d/certifications/%d/document - скачивание сертификата", id, certID)
+
+	// Скан сертификата — личный документ: его видит сам сотрудник и администратор
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	if user.Role != "admin" {
+		own, err := employeeForUser(user.ID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Ошибка поиска сотрудника: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err == sql.ErrNoRows || own != id {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+			return
+		}
+	}
+
+	var document []byte
+	var name, contentType string
+	err = database.DB.QueryRow(`
+		SELECT document, document_name, document_type FROM certifications
+		WHERE id = $1 AND employee_id = $2 AND document IS NOT NULL
+	`, certID, id).Scan(&document, &name, &contentType)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Документ не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename=%q`, name))
+	w.Write(document)
+}
+
+// DeleteCertification удаляет сертификат сотрудника
+func DeleteCertification(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+	certID, err := strconv.Atoi(vars["certId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/employees/%d/certifications/%d - удаление сертификата", id, certID)
+
+	result, err := database.DB.Exec(`DELETE FROM certifications WHERE id = $1 AND employee_id = $2`, certID, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Сертификат не найден", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetExpiringCertifications возвращает сертификаты, срок которых истекает в ближайшие days дней
+// (по умолчанию CERT_EXPIRY_ALERT_DAYS, 30). Сертификаты, уже продленные новым того же типа, не попадают.
+func GetExpiringCertifications(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/certifications/expiring - истекающие сертификаты")
+
+	days := getEnvInt("CERT_EXPIRY_ALERT_DAYS", 30)
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Неверное значение days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	today := currentDate()
+
+	rows, err := database.DB.Query(`
+		SELECT `+certificationColumns+`
+		FROM certifications c
+		JOIN employees e ON c.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE c.expires_at BETWEEN $1 AND $2
+		AND NOT EXISTS (
+			SELECT 1 FROM certifications n
+			WHERE n.employee_id = c.employee_id AND n.cert_type = c.cert_type AND n.id <> c.id
+			AND (n.expires_at IS NULL OR n.expires_at > c.expires_at)
+		)
+		ORDER BY c.expires_at, u.name
+	`, today, today.AddDate(0, 0, days))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	certifications := make([]models.Certification, 0)
+	for rows.Next() {
+		c, err := scanCertification(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		daysLeft := int(c.ExpiresAt.Sub(today).Hours() / 24)
+		c.DaysLeft = &daysLeft
+		certifications = append(certifications, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certifications)
+}
+
+// GetQualificationRequirements возвращает требования к сертификатам тренеров
+func GetQualificationRequirements(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/qualification-requirements - получение требований к квалификации")
+
+	rows, err := database.DB.Query(`
+		SELECT id, hall_type, training_type, cert_type, created_at
+		FROM qualification_requirements
+		ORDER BY hall_type NULLS LAST, training_type NULLS LAST, cert_type
+	`)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	requirements := make([]models.QualificationRequirement, 0)
+	for rows.Next() {
+		var q models.QualificationRequirement
+		if err := rows.Scan(&q.ID, &q.HallType, &q.TrainingType, &q.CertType, &q.CreatedAt); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		requirements = append(requirements, q)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requirements)
+}
+
+// CreateQualificationRequirement добавляет требование: тренировки в зале hall_type и/или
+// типа training_type может вести только тренер с действующим сертификатом cert_type
+func CreateQualificationRequirement(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/qualification-requirements - добавление требования к квалификации")
+
+	var q models.QualificationRequirement
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	q.CertType = strings.ToLower(strings.TrimSpace(q.CertType))
+	if q.CertType == "" {
+		http.Error(w, "Тип сертификата обязателен", http.StatusBadRequest)
+		return
+	}
+	if q.HallType != nil && *q.HallType == "" {
+		q.HallType = nil
+	}
+	if q.TrainingType != nil && *q.TrainingType == "" {
+		q.TrainingType = nil
+	}
+	if q.HallType == nil && q.TrainingType == nil {
+		http.Error(w, "Укажите hall_type или training_type", http.StatusBadRequest)
+		return
+	}
+	if q.TrainingType != nil && *q.TrainingType != "personal" && *q.TrainingType != "group" {
+		http.Error(w, "training_type должен быть personal или group", http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.QueryRow(`
+		INSERT INTO qualification_requirements (hall_type, training_type, cert_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`, q.HallType, q.TrainingType, q.CertType).Scan(&q.ID, &q.CreatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Такое требование уже существует", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка создания требования: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(q)
+	log.Printf("Добавлено требование к квалификации %d: %s", q.ID, q.CertType)
+}
+
+// DeleteQualificationRequirement удаляет требование к квалификации
+func DeleteQualificationRequirement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/qualification-requirements/%d - удаление требования к квалификации", id)
+
+	result, err := database.DB.Exec(`DELETE FROM qualification_requirements WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Требование не найдено", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
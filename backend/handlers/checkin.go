@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errInvalidCheckinToken = errors.New("Недействительный или просроченный код входа")
+
+var (
+	checkinSecretOnce sync.Once
+	checkinSecretKey  []byte
+)
+
+// checkinSecret возвращает ключ подписи кодов входа из CHECKIN_SECRET.
+// Если ключ не задан, генерируется случайный: коды перестанут действовать после перезапуска сервера.
+func checkinSecret() []byte {
+	checkinSecretOnce.Do(func() {
+		if secret := getEnv("CHECKIN_SECRET", ""); secret != "" {
+			checkinSecretKey = []byte(secret)
+			return
+		}
+		checkinSecretKey = make([]byte, 32)
+		if _, err := rand.Read(checkinSecretKey); err != nil {
+			log.Fatalf("Ошибка генерации ключа кодов входа: %v", err)
+		}
+		log.Println("CHECKIN_SECRET не задан, используется временный ключ кодов входа")
+	})
+	return checkinSecretKey
+}
+
+// checkinTTL — период смены кода входа
+func checkinTTL() time.Duration {
+	return time.Duration(getEnvInt("CHECKIN_TOKEN_TTL_SECONDS", 60)) * time.Second
+}
+
+func signCheckin(payload string) string {
+	mac := hmac.New(sha256.New, checkinSecret())
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// checkinToken выдает код входа вида "<user_id>.<истекает>.<подпись>".
+// Код меняется каждый период CHECKIN_TOKEN_TTL_SECONDS и действует не меньше одного периода.
+func checkinToken(userID int, now time.Time) (string, time.Time) {
+	ttl := checkinTTL()
+	expiresAt := now.Truncate(ttl).Add(2 * ttl)
+	payload := fmt.Sprintf("%d.%d", userID, expiresAt.Unix())
+	return payload + "." + signCheckin(payload), expiresAt
+}
+
+// parseCheckinToken проверяет подпись и срок действия кода входа и возвращает ID пользователя
+func parseCheckinToken(token string, now time.Time) (int, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return 0, errInvalidCheckinToken
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errInvalidCheckinToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errInvalidCheckinToken
+	}
+	expected := signCheckin(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return 0, errInvalidCheckinToken
+	}
+	expiresAt := time.Unix(expires, 0)
+	// Код с дальним сроком не мог быть выдан сервером
+	if now.After(expiresAt) || expiresAt.Sub(now) > 2*checkinTTL() {
+		return 0, errInvalidCheckinToken
+	}
+	return userID, nil
+}
+
+const visitColumns = `
+	v.id, v.client_id, v.subscription_id, v.hall, v.checked_in_at, v.checked_out_at, v.checked_in_by, v.checked_out_by
+`
+
+func scanVisit(row interface{ Scan(...interface{}) error }) (models.Visit, error) {
+	var v models.Visit
+	var subscriptionID, checkedInBy, checkedOutBy sql.NullInt64
+	var checkedOutAt sql.NullTime
+	err := row.Scan(&v.ID, &v.ClientID, &subscriptionID, &v.Hall, &v.CheckedInAt, &checkedOutAt, &checkedInBy, &checkedOutBy)
+	if err != nil {
+		return v, err
+	}
+	if subscriptionID.Valid {
+		id := int(subscriptionID.Int64)
+		v.SubscriptionID = &id
+	}
+	if checkedOutAt.Valid {
+		v.CheckedOutAt = &checkedOutAt.Time
+	}
+	if checkedInBy.Valid {
+		id := int(checkedInBy.Int64)
+		v.CheckedInBy = &id
+	}
+	if checkedOutBy.Valid {
+		id := int(checkedOutBy.Int64)
+		v.CheckedOutBy = &id
+	}
+	return v, nil
+}
+
+// checkinRequest — отметка клиента на ресепшене
+type checkinRequest struct {
+	Token     string `json:"token"`     // код входа из QR клиента
+	Direction string `json:"direction"` // in, out; пусто — определить автоматически
+	Hall      string `json:"hall"`      // зал при входе, по умолчанию gym
+}
+
+// checkinResponse — результат отметки
+type checkinResponse struct {
+	Direction  string       `json:"direction"`
+	ClientName string       `json:"client_name"`
+	Visit      models.Visit `json:"visit"`
+}
+
+// Checkin отмечает вход или выход клиента по коду из QR
+func Checkin(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/checkin - отметка входа в клуб")
+
+	var req checkinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if req.Direction != "" && req.Direction != "in" && req.Direction != "out" {
+		http.Error(w, "direction должен быть in или out", http.StatusBadRequest)
+		return
+	}
+	if req.Hall == "" {
+		req.Hall = "gym"
+	}
+	if !models.ValidHall(req.Hall) {
+		http.Error(w, "Неизвестный зал. Доступны: "+strings.Join(models.Halls, ", "), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := parseCheckinToken(req.Token, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	staff, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	resp := checkinResponse{Direction: req.Direction}
+	var clientID int
+	err = tx.QueryRow(`
+		SELECT c.id, u.name FROM clients c JOIN users u ON c.user_id = u.id
+		WHERE c.user_id = $1 AND c.deleted_at IS NULL AND u.deleted_at IS NULL
+	`, userID).Scan(&clientID, &resp.ClientName)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Клиент не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	open, err := scanVisit(tx.QueryRow(`
+		SELECT `+visitColumns+` FROM visits v WHERE v.client_id = $1 AND v.checked_out_at IS NULL FOR UPDATE
+	`, clientID))
+	hasOpen := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if resp.Direction == "" {
+		resp.Direction = "in"
+		if hasOpen {
+			resp.Direction = "out"
+		}
+	}
+
+	if resp.Direction == "out" {
+		if !hasOpen {
+			http.Error(w, "Клиент не отмечен на входе", http.StatusConflict)
+			return
+		}
+		resp.Visit, err = scanVisit(tx.QueryRow(`
+			UPDATE visits v SET checked_out_at = NOW(), checked_out_by = $1
+			WHERE v.id = $2
+			RETURNING `+visitColumns, staff.ID, open.ID))
+	} else {
+		if hasOpen {
+			http.Error(w, "Клиент уже в клубе", http.StatusConflict)
+			return
+		}
+
+		var subscriptionID int
+		err = tx.QueryRow(`
+			SELECT id FROM subscriptions
+			WHERE client_id = $1 AND status = 'active' AND deleted_at IS NULL
+			AND start_date <= CURRENT_DATE AND end_date >= CURRENT_DATE
+			ORDER BY end_date DESC
+			LIMIT 1
+		`, clientID).Scan(&subscriptionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "У клиента нет действующего абонемента", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка проверки абонемента: %v", err)
+			http.Error(w, "Ошибка проверки абонемента", http.StatusInternalServerError)
+			return
+		}
+
+		resp.Visit, err = scanVisit(tx.QueryRow(`
+			INSERT INTO visits AS v (client_id, subscription_id, hall, checked_in_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING `+visitColumns, clientID, subscriptionID, req.Hall, staff.ID))
+		if err == nil {
+			err = awardPoints(tx, loyaltyEntry{
+				ClientID: clientID,
+				Reason:   "checkin",
+				Points:   getEnvInt("LOYALTY_CHECKIN_POINTS", 5),
+				VisitID:  &resp.Visit.ID,
+			})
+		}
+	}
+	if err != nil {
+		// Параллельная отметка того же клиента упирается в уникальный индекс открытых посещений
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Клиент уже в клубе", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка записи посещения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения посещения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	publishOccupancy()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	log.Printf("Отметка %s: клиент %s (ID: %d), посещение %d", resp.Direction, resp.ClientName, clientID, resp.Visit.ID)
+}
+
+// GetVisits возвращает журнал посещений с фильтрами client_id, from, to (YYYY-MM-DD) и open=true
+func GetVisits(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/visits - получение журнала посещений")
+
+	query := `SELECT ` + visitColumns + ` FROM visits v WHERE 1=1`
+	args := []interface{}{}
+	argNum := 1
+
+	if clientID := r.URL.Query().Get("client_id"); clientID != "" {
+		query += " AND v.client_id = $" + strconv.Itoa(argNum)
+		args = append(args, clientID)
+		argNum++
+	}
+	if from := r.URL.Query().Get("from"); from != "" {
+		query += " AND v.checked_in_at >= $" + strconv.Itoa(argNum)
+		args = append(args, from)
+		argNum++
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		query += " AND v.checked_in_at < $" + strconv.Itoa(argNum) + "::date + 1"
+		args = append(args, to)
+		argNum++
+	}
+	if r.URL.Query().Get("open") == "true" {
+		query += " AND v.checked_out_at IS NULL"
+	}
+	query += " ORDER BY v.checked_in_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	visits := make([]models.Visit, 0)
+	for rows.Next() {
+		v, err := scanVisit(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		visits = append(visits, v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visits)
+	log.Printf("Возвращено посещений: %d", len(visits))
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCheckinToken(t *testing.T) {
+	t.Setenv("CHECKIN_TOKEN_TTL_SECONDS", "60")
+	now := time.Date(2026, time.October, 19, 10, 0, 30, 0, time.UTC)
+	token, expiresAt := checkinToken(42, now)
+	if want := time.Date(2026, time.October, 19, 10, 2, 0, 0, time.UTC); !expiresAt.Equal(want) {
+		t.Fatalf("код истекает %v, ожидалось %v", expiresAt, want)
+	}
+
+	parts := strings.Split(token, ".")
+	signed := func(userID int, expires time.Time) string {
+		payload := fmt.Sprintf("%d.%d", userID, expires.Unix())
+		return payload + "." + signCheckin(payload)
+	}
+	flip := func(s string) string {
+		last := s[len(s)-1]
+		if last == '0' {
+			return s[:len(s)-1] + "1"
+		}
+		return s[:len(s)-1] + "0"
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+		want  int
+	}{
+		{"действующий код", token, now, 42},
+		{"пробелы вокруг кода", " " + token + "\n", now, 42},
+		{"последняя секунда", token, expiresAt, 42},
+		{"истекший код", token, expiresAt.Add(time.Second), 0},
+		{"подпись изменена", flip(token), now, 0},
+		{"подмена пользователя", "43." + parts[1] + "." + parts[2], now, 0},
+		{"продление срока", parts[0] + "." + fmt.Sprint(expiresAt.Add(time.Hour).Unix()) + "." + parts[2], now, 0},
+		{"срок дальше двух периодов", signed(42, now.Add(time.Hour)), now, 0},
+		{"пустой код", "", now, 0},
+		{"без разделителей", "42", now, 0},
+		{"без подписи", parts[0] + "." + parts[1], now, 0},
+		{"лишняя часть", token + ".1", now, 0},
+		{"ID не число", "abc." + parts[1] + "." + parts[2], now, 0},
+		{"срок не число", parts[0] + ".soon." + parts[2], now, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCheckinToken(tt.token, tt.at)
+			if tt.want == 0 {
+				if err != errInvalidCheckinToken {
+					t.Errorf("parseCheckinToken(%q) = %d, %v, ожидалась ошибка", tt.token, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("parseCheckinToken(%q) = %d, %v, ожидалось %d", tt.token, got, err, tt.want)
+			}
+		})
+	}
+}
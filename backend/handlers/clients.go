@@ -8,21 +8,162 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
-// GetClients возвращает список всех клиентов
+// clientSortColumns — допустимые значения параметра sort ("-" в начале — по убыванию)
+var clientSortColumns = map[string]string{
+	"name":       "u.name",
+	"email":      "u.email",
+	"created_at": "c.created_at",
+	"last_visit": "lv.last_visit_at",
+	"end_date":   "ss.end_date",
+	"birth_date": "c.birth_date",
+}
+
+// likePattern экранирует спецсимволы LIKE и оборачивает строку для поиска подстроки
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// normalizePhone оставляет в номере телефона только цифры
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+}
+
+// GetClients возвращает список клиентов с поиском, фильтрами, сортировкой и пагинацией.
+// Параметры: q (имя, email или телефон), name, email, phone, subscription_status
+// (active — включая истекающие, expiring, expired, none), last_visit_from, last_visit_to,
+// birthday_month, sort, page, per_page. Общее количество найденных — в заголовке X-Total-Count.
 func GetClients(w http.ResponseWriter, r *http.Request) {
 	log.Println("GET /api/clients - получение списка клиентов")
 
-	rows, err := database.DB.Query(`
-		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.created_at,
-		       u.id, u.name, u.email, u.role
+	format, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	where := []string{"1=1"}
+	if !includeDeleted(r) {
+		where = append(where, "c.deleted_at IS NULL", "u.deleted_at IS NULL")
+	}
+	args := []interface{}{getEnvInt("SUBSCRIPTION_EXPIRING_DAYS", 7)}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		conditions := []string{"u.name ILIKE " + arg(likePattern(q)), "u.email ILIKE " + arg(likePattern(q))}
+		if digits := normalizePhone(q); len(digits) >= 3 {
+			conditions = append(conditions, `regexp_replace(c.phone, '\D', '', 'g') LIKE `+arg(likePattern(digits)))
+		}
+		where = append(where, "("+strings.Join(conditions, " OR ")+")")
+	}
+	if name := strings.TrimSpace(params.Get("name")); name != "" {
+		where = append(where, "u.name ILIKE "+arg(likePattern(name)))
+	}
+	if email := strings.TrimSpace(params.Get("email")); email != "" {
+		where = append(where, "u.email ILIKE "+arg(likePattern(email)))
+	}
+	if phone := normalizePhone(params.Get("phone")); phone != "" {
+		where = append(where, `regexp_replace(c.phone, '\D', '', 'g') LIKE `+arg(likePattern(phone)))
+	}
+	switch status := params.Get("subscription_status"); status {
+	case "":
+	case "active":
+		where = append(where, "ss.status IN ('active', 'expiring')")
+	case "expiring", "expired", "none":
+		where = append(where, "ss.status = "+arg(status))
+	default:
+		http.Error(w, "subscription_status должен быть active, expiring, expired или none", http.StatusBadRequest)
+		return
+	}
+	if from := params.Get("last_visit_from"); from != "" {
+		where = append(where, "lv.last_visit_at >= "+arg(from)+"::date")
+	}
+	if to := params.Get("last_visit_to"); to != "" {
+		where = append(where, "lv.last_visit_at < "+arg(to)+"::date + 1")
+	}
+	if month := params.Get("birthday_month"); month != "" {
+		m, err := strconv.Atoi(month)
+		if err != nil || m < 1 || m > 12 {
+			http.Error(w, "birthday_month должен быть от 1 до 12", http.StatusBadRequest)
+			return
+		}
+		where = append(where, "EXTRACT(MONTH FROM c.birth_date) = "+arg(m))
+	}
+
+	orderBy := "c.created_at DESC"
+	if sort := params.Get("sort"); sort != "" {
+		direction := "ASC"
+		if strings.HasPrefix(sort, "-") {
+			direction = "DESC"
+			sort = sort[1:]
+		}
+		column, ok := clientSortColumns[sort]
+		if !ok {
+			http.Error(w, "Недопустимая сортировка", http.StatusBadRequest)
+			return
+		}
+		orderBy = column + " " + direction + " NULLS LAST, c.id"
+	}
+
+	filterArgs := len(args)
+	pagination := ""
+	if params.Get("page") != "" || params.Get("per_page") != "" {
+		page, err := strconv.Atoi(params.Get("page"))
+		if params.Get("page") == "" {
+			page, err = 1, nil
+		}
+		if err != nil || page < 1 {
+			http.Error(w, "Неверный номер страницы", http.StatusBadRequest)
+			return
+		}
+		perPage, err := strconv.Atoi(params.Get("per_page"))
+		if params.Get("per_page") == "" {
+			perPage, err = 50, nil
+		}
+		if err != nil || perPage < 1 || perPage > 200 {
+			http.Error(w, "per_page должен быть от 1 до 200", http.StatusBadRequest)
+			return
+		}
+		pagination = " LIMIT " + arg(perPage) + " OFFSET " + arg((page-1)*perPage)
+	}
+
+	// Фильтры ссылаются на ss и lv, поэтому подсчет на пустой странице использует тот же FROM
+	source := `
 		FROM clients c
 		LEFT JOIN users u ON c.user_id = u.id
-		ORDER BY c.created_at DESC
-	`)
+		LEFT JOIN LATERAL (
+			SELECT CASE
+				WHEN bool_or(s.status = 'active' AND s.end_date >= CURRENT_DATE + $1::int) THEN 'active'
+				WHEN bool_or(s.status = 'active' AND s.end_date >= CURRENT_DATE) THEN 'expiring'
+				WHEN COUNT(s.id) > 0 THEN 'expired'
+				ELSE 'none'
+			END AS status,
+			MAX(s.end_date) AS end_date
+			FROM subscriptions s
+			WHERE s.client_id = c.id AND s.deleted_at IS NULL
+		) ss ON true
+		LEFT JOIN LATERAL (
+			SELECT MAX(v.checked_in_at) AS last_visit_at FROM visits v WHERE v.client_id = c.id
+		) lv ON true
+		WHERE ` + strings.Join(where, " AND ")
+
+	rows, err := database.DB.Query(`
+		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.is_student, c.family_id, c.created_at, c.deleted_at,
+		       u.id, u.name, u.email, u.role, ss.status, lv.last_visit_at, COUNT(*) OVER()`+source+`
+		ORDER BY `+orderBy+pagination, args...)
 	if err != nil {
 		log.Printf("Ошибка запроса: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -30,19 +171,30 @@ func GetClients(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	export, err := beginExport(w, format, "clients", "ID", "ID пользователя", "Имя", "Email", "Телефон", "Адрес",
+		"Дата рождения", "Студент", "ID семьи", "Абонемент", "Последний визит", "Создан", "Удален")
+	if err != nil {
+		log.Printf("Ошибка выгрузки клиентов: %v", err)
+		return
+	}
+
 	var clients []models.Client
 	// Инициализируем как пустой массив, а не nil
 	clients = make([]models.Client, 0)
-	
+	total := 0
+
 	for rows.Next() {
 		var c models.Client
 		var u models.User
 		var birthDate sql.NullTime
+		var familyID sql.NullInt64
 		var phone sql.NullString
 		var address sql.NullString
+		var lastVisit sql.NullTime
+		var deletedAt sql.NullTime
 
-		err := rows.Scan(&c.ID, &c.UserID, &phone, &address, &birthDate, &c.CreatedAt,
-			&u.ID, &u.Name, &u.Email, &u.Role)
+		err := rows.Scan(&c.ID, &c.UserID, &phone, &address, &birthDate, &c.IsStudent, &familyID, &c.CreatedAt, &deletedAt,
+			&u.ID, &u.Name, &u.Email, &u.Role, &c.SubscriptionStatus, &lastVisit, &total)
 		if err != nil {
 			log.Printf("Ошибка сканирования: %v", err)
 			continue
@@ -58,17 +210,49 @@ func GetClients(w http.ResponseWriter, r *http.Request) {
 		if birthDate.Valid {
 			c.BirthDate = &birthDate.Time
 		}
+		if familyID.Valid {
+			family := int(familyID.Int64)
+			c.FamilyID = &family
+		}
+		if lastVisit.Valid {
+			c.LastVisitAt = &lastVisit.Time
+		}
+		if deletedAt.Valid {
+			c.DeletedAt = &deletedAt.Time
+		}
 		c.User = &u
+		if export != nil {
+			var birth string
+			if c.BirthDate != nil {
+				birth = c.BirthDate.Format("2006-01-02")
+			}
+			if !export.Write(c.ID, c.UserID, u.Name, u.Email, c.Phone, c.Address, birth, c.IsStudent,
+				c.FamilyID, c.SubscriptionStatus, c.LastVisitAt, c.CreatedAt, c.DeletedAt) {
+				return
+			}
+			continue
+		}
 		clients = append(clients, c)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	// Всегда возвращаем массив, даже если пустой
-	if clients == nil {
-		clients = []models.Client{}
+	if export != nil {
+		export.Close()
+		return
 	}
+
+	// COUNT(*) OVER() не возвращается, если страница оказалась за концом списка
+	if len(clients) == 0 && pagination != "" {
+		if err := database.DB.QueryRow(`SELECT COUNT(*)`+source, args[:filterArgs]...).Scan(&total); err != nil {
+			log.Printf("Ошибка подсчета клиентов: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(clients)
-	log.Printf("Возвращено клиентов: %d", len(clients))
+	log.Printf("Возвращено клиентов: %d из %d", len(clients), total)
 }
 
 // GetClient возвращает одного клиента по ID
@@ -85,16 +269,18 @@ func GetClient(w http.ResponseWriter, r *http.Request) {
 	var c models.Client
 	var u models.User
 	var birthDate sql.NullTime
+	var familyID sql.NullInt64
 	var phone sql.NullString
 	var address sql.NullString
+	var deletedAt sql.NullTime
 
 	err = database.DB.QueryRow(`
-		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.created_at,
+		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.is_student, c.family_id, c.created_at, c.deleted_at,
 		       u.id, u.name, u.email, u.role
 		FROM clients c
 		LEFT JOIN users u ON c.user_id = u.id
-		WHERE c.id = $1
-	`, id).Scan(&c.ID, &c.UserID, &phone, &address, &birthDate, &c.CreatedAt,
+		WHERE c.id = $1`+notDeleted(r, "c"),
+		id).Scan(&c.ID, &c.UserID, &phone, &address, &birthDate, &c.IsStudent, &familyID, &c.CreatedAt, &deletedAt,
 		&u.ID, &u.Name, &u.Email, &u.Role)
 	
 	// Обрабатываем NULL значения
@@ -121,8 +307,25 @@ func GetClient(w http.ResponseWriter, r *http.Request) {
 	if birthDate.Valid {
 		c.BirthDate = &birthDate.Time
 	}
+	if familyID.Valid {
+		family := int(familyID.Int64)
+		c.FamilyID = &family
+	}
+	if deletedAt.Valid {
+		c.DeletedAt = &deletedAt.Time
+	}
 	c.User = &u
 
+	// Заметки о клиенте видят только сотрудники с доступом к нему
+	if viewer, err := getSessionUser(r); err == nil && (viewer.Role == "admin" || viewer.Role == "trainer") {
+		if allowed, err := canAccessClient(viewer, c.ID); err == nil && allowed {
+			c.Notes, err = loadClientNotes(c.ID, viewer, nil)
+			if err != nil {
+				log.Printf("Ошибка загрузки заметок клиента %d: %v", c.ID, err)
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(c)
 }
@@ -145,7 +348,7 @@ func CreateClient(w http.ResponseWriter, r *http.Request) {
 	// Проверяем, существует ли пользователь
 	var userExists bool
 	err := database.DB.QueryRow(`
-		SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)
+		SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)
 	`, c.UserID).Scan(&userExists)
 	
 	if err != nil {
@@ -183,10 +386,10 @@ func CreateClient(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = database.DB.QueryRow(`
-		INSERT INTO clients (user_id, phone, address, birth_date) 
-		VALUES ($1, $2, $3, $4) 
+		INSERT INTO clients (user_id, phone, address, birth_date, is_student, family_id) 
+		VALUES ($1, $2, $3, $4, $5, $6) 
 		RETURNING id
-	`, c.UserID, c.Phone, c.Address, birthDate).Scan(&id)
+	`, c.UserID, c.Phone, c.Address, birthDate, c.IsStudent, c.FamilyID).Scan(&id)
 
 	if err != nil {
 		log.Printf("Ошибка создания клиента: %v", err)
@@ -201,7 +404,7 @@ func CreateClient(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Создан клиент с ID: %d", id)
 }
 
-// DeleteClient удаляет клиента
+// DeleteClient помечает клиента удаленным; абонементы и посещения сохраняются
 func DeleteClient(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -212,15 +415,13 @@ func DeleteClient(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("DELETE /api/clients/%d - удаление клиента", id)
 
-	result, err := database.DB.Exec("DELETE FROM clients WHERE id = $1", id)
+	deleted, err := softDelete(database.DB, "clients", id)
 	if err != nil {
 		log.Printf("Ошибка удаления: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		http.Error(w, "Клиент не найден", http.StatusNotFound)
 		return
 	}
@@ -248,7 +449,7 @@ func UpdateClient(w http.ResponseWriter, r *http.Request) {
 
 	// Проверяем существование клиента
 	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM clients WHERE id = $1)", id).Scan(&exists)
+	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM clients WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
 	if err != nil {
 		log.Printf("Ошибка проверки клиента: %v", err)
 		http.Error(w, "Ошибка проверки клиента", http.StatusInternalServerError)
@@ -267,9 +468,9 @@ func UpdateClient(w http.ResponseWriter, r *http.Request) {
 
 	_, err = database.DB.Exec(`
 		UPDATE clients 
-		SET phone = $1, address = $2, birth_date = $3
-		WHERE id = $4
-	`, c.Phone, c.Address, birthDate, id)
+		SET phone = $1, address = $2, birth_date = $3, is_student = $4, family_id = $5
+		WHERE id = $6
+	`, c.Phone, c.Address, birthDate, c.IsStudent, c.FamilyID, id)
 
 	if err != nil {
 		log.Printf("Ошибка обновления: %v", err)
@@ -281,16 +482,17 @@ func UpdateClient(w http.ResponseWriter, r *http.Request) {
 	var updatedClient models.Client
 	var u models.User
 	var birthDateNull sql.NullTime
+	var familyID sql.NullInt64
 	var phone sql.NullString
 	var address sql.NullString
 
 	err = database.DB.QueryRow(`
-		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.created_at,
+		SELECT c.id, c.user_id, c.phone, c.address, c.birth_date, c.is_student, c.family_id, c.created_at,
 		       u.id, u.name, u.email, u.role
 		FROM clients c
 		LEFT JOIN users u ON c.user_id = u.id
 		WHERE c.id = $1
-	`, id).Scan(&updatedClient.ID, &updatedClient.UserID, &phone, &address, &birthDateNull, &updatedClient.CreatedAt,
+	`, id).Scan(&updatedClient.ID, &updatedClient.UserID, &phone, &address, &birthDateNull, &updatedClient.IsStudent, &familyID, &updatedClient.CreatedAt,
 		&u.ID, &u.Name, &u.Email, &u.Role)
 
 	if err != nil {
@@ -308,6 +510,10 @@ func UpdateClient(w http.ResponseWriter, r *http.Request) {
 	if birthDateNull.Valid {
 		updatedClient.BirthDate = &birthDateNull.Time
 	}
+	if familyID.Valid {
+		family := int(familyID.Int64)
+		updatedClient.FamilyID = &family
+	}
 	updatedClient.User = &u
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"fitness-club/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// validationError — ошибка во входных данных, текст которой можно показать пользователю
+type validationError string
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// appliedDiscount — скидка, примененная к цене абонемента
+type appliedDiscount struct {
+	Reason string       `json:"reason"`
+	Amount models.Money `json:"amount"`
+}
+
+// subscriptionQuote — расчет цены абонемента
+type subscriptionQuote struct {
+	Plan           models.SubscriptionPlan `json:"plan"`
+	BasePrice      models.Money            `json:"base_price"`
+	Discounts      []appliedDiscount       `json:"discounts"`
+	DiscountAmount models.Money            `json:"discount_amount"`
+	Total          models.Money            `json:"total"`
+	PromoCodeID    *int                    `json:"promo_code_id,omitempty"`
+	PointsRedeemed int                     `json:"points_redeemed,omitempty"`
+}
+
+// discountReason возвращает описание всех примененных скидок одной строкой
+func (q subscriptionQuote) discountReason() string {
+	reasons := make([]string, 0, len(q.Discounts))
+	for _, d := range q.Discounts {
+		reasons = append(reasons, d.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// quoteSubscription рассчитывает цену абонемента: сначала применяется лучшая из
+// автоматических скидок (студент, пенсионер, второй член семьи), затем промокод,
+// затем оплата бонусными баллами.
+// Внутри транзакции строка промокода блокируется до ее завершения, чтобы
+// параллельные покупки не превысили лимиты использования.
+func quoteSubscription(q queryer, clientID int, plan models.SubscriptionPlan, promoCode string, redeemPoints int) (subscriptionQuote, error) {
+	quote := subscriptionQuote{Plan: plan, BasePrice: plan.Price, Discounts: []appliedDiscount{}}
+	price := plan.Price
+
+	auto, err := automaticDiscount(q, clientID, price)
+	if err != nil {
+		return quote, err
+	}
+	if auto != nil {
+		quote.Discounts = append(quote.Discounts, *auto)
+		price = price.Sub(auto.Amount)
+	}
+
+	if code := strings.TrimSpace(promoCode); code != "" {
+		promo, err := lockPromoCode(q, clientID, plan, code)
+		if err != nil {
+			return quote, err
+		}
+		amount := promoDiscount(promo, price)
+		quote.Discounts = append(quote.Discounts, appliedDiscount{Reason: "Промокод " + promo.Code, Amount: amount})
+		price = price.Sub(amount)
+		quote.PromoCodeID = &promo.ID
+	}
+
+	points, amount, err := pointsDiscount(q, clientID, redeemPoints, price)
+	if err != nil {
+		return quote, err
+	}
+	if points > 0 {
+		quote.Discounts = append(quote.Discounts, appliedDiscount{Reason: fmt.Sprintf("Оплата баллами (%d)", points), Amount: amount})
+		price = price.Sub(amount)
+		quote.PointsRedeemed = points
+	}
+
+	quote.Total = price
+	quote.DiscountAmount = quote.BasePrice.Sub(quote.Total)
+	return quote, nil
+}
+
+// promoDiscount возвращает скидку по промокоду, не превышающую цену
+func promoDiscount(promo models.PromoCode, price models.Money) models.Money {
+	amount := price.Percent(promo.DiscountPercent)
+	if promo.DiscountType == "fixed" && promo.DiscountAmount != nil {
+		amount = *promo.DiscountAmount
+	}
+	return models.MinMoney(amount, price)
+}
+
+// automaticDiscount выбирает наибольшую из положенных клиенту автоматических скидок
+func automaticDiscount(q queryer, clientID int, price models.Money) (*appliedDiscount, error) {
+	var birthDate sql.NullTime
+	var isStudent bool
+	var familyID sql.NullInt64
+	err := q.QueryRow(`
+		SELECT birth_date, is_student, family_id FROM clients WHERE id = $1
+	`, clientID).Scan(&birthDate, &isStudent, &familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []appliedDiscount{}
+	if isStudent {
+		percent := getEnvInt("DISCOUNT_STUDENT_PERCENT", 10)
+		candidates = append(candidates, appliedDiscount{
+			Reason: fmt.Sprintf("Студенческая скидка %d%%", percent),
+			Amount: price.Percent(models.WholePercent(percent)),
+		})
+	}
+	if birthDate.Valid && ageAt(birthDate.Time, time.Now()) >= getEnvInt("SENIOR_AGE", 60) {
+		percent := getEnvInt("DISCOUNT_SENIOR_PERCENT", 15)
+		candidates = append(candidates, appliedDiscount{
+			Reason: fmt.Sprintf("Скидка для пенсионеров %d%%", percent),
+			Amount: price.Percent(models.WholePercent(percent)),
+		})
+	}
+	if familyID.Valid {
+		// Скидка положена, если у другого члена семьи уже есть действующий абонемент
+		var hasFamilyMember bool
+		err = q.QueryRow(`
+			SELECT EXISTS(
+				SELECT 1
+				FROM clients c
+				JOIN subscriptions s ON s.client_id = c.id
+				WHERE c.family_id = $1 AND c.id <> $2
+				AND s.status = 'active' AND s.end_date >= CURRENT_DATE AND s.deleted_at IS NULL
+			)
+		`, familyID.Int64, clientID).Scan(&hasFamilyMember)
+		if err != nil {
+			return nil, err
+		}
+		if hasFamilyMember {
+			percent := getEnvInt("DISCOUNT_FAMILY_PERCENT", 10)
+			candidates = append(candidates, appliedDiscount{
+				Reason: fmt.Sprintf("Семейная скидка %d%%", percent),
+				Amount: price.Percent(models.WholePercent(percent)),
+			})
+		}
+	}
+
+	var best *appliedDiscount
+	for i := range candidates {
+		if candidates[i].Amount.IsPositive() && (best == nil || best.Amount.Less(candidates[i].Amount)) {
+			best = &candidates[i]
+		}
+	}
+	return best, nil
+}
+
+// ageAt возвращает полное число лет на указанную дату
+func ageAt(birthDate, at time.Time) int {
+	age := at.Year() - birthDate.Year()
+	if at.Month() < birthDate.Month() || at.Month() == birthDate.Month() && at.Day() < birthDate.Day() {
+		age--
+	}
+	return age
+}
+
+const promoCodeColumns = `
+	id, code, COALESCE(description, ''), discount_type, discount_value, valid_from, valid_to,
+	usage_limit, per_client_limit, plans, times_used, active, created_at
+`
+
+// scanPromoCode читает строку с колонками promoCodeColumns
+func scanPromoCode(row interface{ Scan(...interface{}) error }) (models.PromoCode, error) {
+	var p models.PromoCode
+	var validFrom, validTo sql.NullTime
+	var usageLimit, perClientLimit sql.NullInt64
+	var value models.Money
+	err := row.Scan(&p.ID, &p.Code, &p.Description, &p.DiscountType, &value, &validFrom, &validTo,
+		&usageLimit, &perClientLimit, pq.Array(&p.Plans), &p.TimesUsed, &p.Active, &p.CreatedAt)
+	if err != nil {
+		return p, err
+	}
+	if p.DiscountType == "percent" {
+		p.DiscountPercent = models.Percent(value.Amount)
+	} else {
+		p.DiscountAmount = &value
+	}
+	if validFrom.Valid {
+		p.ValidFrom = &validFrom.Time
+	}
+	if validTo.Valid {
+		p.ValidTo = &validTo.Time
+	}
+	if usageLimit.Valid {
+		limit := int(usageLimit.Int64)
+		p.UsageLimit = &limit
+	}
+	if perClientLimit.Valid {
+		limit := int(perClientLimit.Int64)
+		p.PerClientLimit = &limit
+	}
+	return p, nil
+}
+
+// lockPromoCode находит промокод, блокирует его и проверяет, что клиент может его применить
+func lockPromoCode(q queryer, clientID int, plan models.SubscriptionPlan, code string) (models.PromoCode, error) {
+	p, err := scanPromoCode(q.QueryRow(`
+		SELECT `+promoCodeColumns+`
+		FROM promo_codes
+		WHERE UPPER(code) = UPPER($1)
+		FOR UPDATE
+	`, code))
+	if err == sql.ErrNoRows {
+		return p, validationError("Промокод не найден")
+	}
+	if err != nil {
+		return p, err
+	}
+
+	today := currentDate()
+	switch {
+	case !p.Active:
+		return p, validationError("Промокод не активен")
+	case p.ValidFrom != nil && p.ValidFrom.After(today):
+		return p, validationError("Промокод еще не действует")
+	case p.ValidTo != nil && p.ValidTo.Before(today):
+		return p, validationError("Срок действия промокода истек")
+	case p.UsageLimit != nil && p.TimesUsed >= *p.UsageLimit:
+		return p, validationError("Лимит использований промокода исчерпан")
+	}
+
+	if len(p.Plans) > 0 {
+		allowed := false
+		for _, code := range p.Plans {
+			if code == plan.Code {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return p, validationError(fmt.Sprintf("Промокод не действует для тарифа «%s»", plan.Name))
+		}
+	}
+
+	if p.PerClientLimit != nil {
+		var used int
+		err = q.QueryRow(`
+			SELECT COUNT(*) FROM promo_code_redemptions WHERE promo_code_id = $1 AND client_id = $2
+		`, p.ID, clientID).Scan(&used)
+		if err != nil {
+			return p, err
+		}
+		if used >= *p.PerClientLimit {
+			return p, validationError("Клиент уже использовал этот промокод максимальное число раз")
+		}
+	}
+	return p, nil
+}
+
+// redeemPromoCode фиксирует использование промокода при покупке абонемента
+func redeemPromoCode(q queryer, promoCodeID, clientID, subscriptionID int) error {
+	_, err := q.Exec(`
+		INSERT INTO promo_code_redemptions (promo_code_id, client_id, subscription_id)
+		VALUES ($1, $2, $3)
+	`, promoCodeID, clientID, subscriptionID)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`UPDATE promo_codes SET times_used = times_used + 1 WHERE id = $1`, promoCodeID)
+	return err
+}
@@ -16,6 +16,11 @@ import (
 func GetEmployees(w http.ResponseWriter, r *http.Request) {
 	log.Println("GET /api/employees - получение списка сотрудников")
 
+	format, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
 	rows, err := database.DB.Query(`
 		SELECT e.id, e.user_id, e.position, e.salary, e.hire_date, e.created_at,
 		       u.id, u.name, u.email, u.role
@@ -30,11 +35,18 @@ func GetEmployees(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	export, err := beginExport(w, format, "employees", "ID", "ID пользователя", "Имя", "Email", "Роль", "Должность",
+		"Оклад", "Дата найма", "Создан")
+	if err != nil {
+		log.Printf("Ошибка выгрузки сотрудников: %v", err)
+		return
+	}
+
 	var employees []models.Employee
 	for rows.Next() {
 		var e models.Employee
 		var u models.User
-		var salary sql.NullFloat64
+		var salary *models.Money
 
 		err := rows.Scan(&e.ID, &e.UserID, &e.Position, &salary, &e.HireDate, &e.CreatedAt,
 			&u.ID, &u.Name, &u.Email, &u.Role)
@@ -43,13 +55,23 @@ func GetEmployees(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		if salary.Valid {
-			e.Salary = &salary.Float64
-		}
+		e.Salary = salary
 		e.User = &u
+		if export != nil {
+			if !export.Write(e.ID, e.UserID, u.Name, u.Email, u.Role, e.Position, e.Salary,
+				e.HireDate.Format("2006-01-02"), e.CreatedAt) {
+				return
+			}
+			continue
+		}
 		employees = append(employees, e)
 	}
 
+	if export != nil {
+		export.Close()
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(employees)
 	log.Printf("Возвращено сотрудников: %d", len(employees))
@@ -68,7 +90,7 @@ func GetEmployee(w http.ResponseWriter, r *http.Request) {
 
 	var e models.Employee
 	var u models.User
-	var salary sql.NullFloat64
+	var salary *models.Money
 
 	err = database.DB.QueryRow(`
 		SELECT e.id, e.user_id, e.position, e.salary, e.hire_date, e.created_at,
@@ -89,9 +111,7 @@ func GetEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if salary.Valid {
-		e.Salary = &salary.Float64
-	}
+	e.Salary = salary
 	e.User = &u
 
 	w.Header().Set("Content-Type", "application/json")
@@ -217,7 +237,7 @@ func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
 	// Возвращаем обновленного сотрудника
 	var updatedEmployee models.Employee
 	var u models.User
-	var salaryNull sql.NullFloat64
+	var salaryNull *models.Money
 
 	err = database.DB.QueryRow(`
 		SELECT e.id, e.user_id, e.position, e.salary, e.hire_date, e.created_at,
@@ -235,9 +255,7 @@ func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if salaryNull.Valid {
-		updatedEmployee.Salary = &salaryNull.Float64
-	}
+	updatedEmployee.Salary = salaryNull
 	updatedEmployee.User = &u
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fitness-club/models"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportFlushRows — через сколько строк выгрузка отправляется клиенту, чтобы большие списки
+// не накапливались в памяти
+const exportFlushRows = 500
+
+// exporter построчно пишет таблицу в ответ. Строки не буферизуются: каждая уходит в ответ
+// сразу после чтения из базы.
+type exporter interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+}
+
+// exportFormat возвращает формат выгрузки из параметра format: csv, xlsx
+// или пустую строку для обычного JSON-ответа
+func exportFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		return "", nil
+	case "csv", "xlsx":
+		return format, nil
+	default:
+		return "", errors.New("format должен быть json, csv или xlsx")
+	}
+}
+
+// exportRequest возвращает запрошенный формат выгрузки списка. Выгрузка доступна только
+// администратору; при ошибке ответ уже отправлен и ok = false.
+func exportRequest(w http.ResponseWriter, r *http.Request) (format string, ok bool) {
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if format == "" {
+		return "", true
+	}
+	user, err := getSessionUser(r)
+	if err != nil || user.Role != "admin" {
+		http.Error(w, "Доступ запрещен. Требуются права администратора", http.StatusForbidden)
+		return "", false
+	}
+	return format, true
+}
+
+// listExport — выгрузка списка, которую обработчик ведет вместо JSON-ответа
+type listExport struct {
+	out    exporter
+	name   string
+	format string
+	rows   int
+}
+
+// beginExport начинает выгрузку списка name. Для пустого format (обычный JSON) возвращает nil.
+func beginExport(w http.ResponseWriter, format, name string, header ...string) (*listExport, error) {
+	if format == "" {
+		return nil, nil
+	}
+	out, err := newExporter(w, format, name, header...)
+	if err != nil {
+		return nil, err
+	}
+	return &listExport{out: out, name: name, format: format}, nil
+}
+
+// Write выгружает строку. false — ответ оборван, обработчик должен завершиться.
+func (e *listExport) Write(values ...interface{}) bool {
+	if err := e.out.WriteRow(values...); err != nil {
+		log.Printf("Ошибка выгрузки %s: %v", e.name, err)
+		return false
+	}
+	e.rows++
+	return true
+}
+
+// Close завершает выгрузку
+func (e *listExport) Close() {
+	if err := e.out.Close(); err != nil {
+		log.Printf("Ошибка выгрузки %s: %v", e.name, err)
+		return
+	}
+	log.Printf("Выгрузка %s: %d строк (%s)", e.name, e.rows, e.format)
+}
+
+// newExporter начинает выгрузку name в формате format и пишет строку заголовков.
+// После вызова заголовки ответа уже отправлены, ошибки можно только залогировать.
+func newExporter(w http.ResponseWriter, format, name string, header ...string) (exporter, error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var out exporter
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		out = newCSVExporter(w)
+	case "xlsx":
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		x, err := newXLSXExporter(w, name)
+		if err != nil {
+			return nil, err
+		}
+		out = x
+	default:
+		return nil, fmt.Errorf("неизвестный формат выгрузки: %s", format)
+	}
+
+	values := make([]interface{}, len(header))
+	for i, h := range header {
+		values[i] = h
+	}
+	return out, out.WriteRow(values...)
+}
+
+// exportCell приводит значение ячейки к строке или числу. Пустые указатели дают пустую ячейку.
+func exportCell(value interface{}) (text string, number bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, false
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		if v {
+			return "да", false
+		}
+		return "нет", false
+	case models.Money:
+		return v.String(), true
+	case *models.Money:
+		if v == nil {
+			return "", false
+		}
+		return v.String(), true
+	case time.Time:
+		return v.Format("2006-01-02 15:04"), false
+	case *time.Time:
+		if v == nil {
+			return "", false
+		}
+		return v.Format("2006-01-02 15:04"), false
+	case *int:
+		if v == nil {
+			return "", false
+		}
+		return strconv.Itoa(*v), true
+	default:
+		return fmt.Sprint(v), false
+	}
+}
+
+// flushResponse отправляет клиенту уже записанную часть ответа
+func flushResponse(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// csvExporter пишет CSV с разделителем ';', как отчеты; текст, похожий на формулу, экранируется.
+// В XLSX это не нужно: текст записывается inline-строками и формулой не становится.
+type csvExporter struct {
+	w    http.ResponseWriter
+	out  *csv.Writer
+	rows int
+}
+
+func newCSVExporter(w http.ResponseWriter) *csvExporter {
+	out := csv.NewWriter(w)
+	out.Comma = ';'
+	return &csvExporter{w: w, out: out}
+}
+
+func (e *csvExporter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		text, number := exportCell(v)
+		if !number {
+			text = csvSafe(text)
+		}
+		record[i] = text
+	}
+	if err := e.out.Write(record); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		e.out.Flush()
+		flushResponse(e.w)
+		return e.out.Error()
+	}
+	return nil
+}
+
+func (e *csvExporter) Close() error {
+	e.out.Flush()
+	return e.out.Error()
+}
+
+// xlsxExporter пишет книгу Excel с одним листом. Служебные части книги записываются
+// заранее, а лист — потоком, по строке за раз; строки хранятся как inline-строки,
+// поэтому общая таблица строк в памяти не нужна.
+type xlsxExporter struct {
+	w     http.ResponseWriter
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+func newXLSXExporter(w http.ResponseWriter, name string) (*xlsxExporter, error) {
+	e := &xlsxExporter{w: w, zip: zip.NewWriter(w)}
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(name))},
+	}
+	for _, part := range parts {
+		f, err := e.zip.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+	e.sheet = sheet
+	return e, nil
+}
+
+func (e *xlsxExporter) WriteRow(values ...interface{}) error {
+	var row strings.Builder
+	row.WriteString("<row>")
+	for _, v := range values {
+		text, number := exportCell(v)
+		switch {
+		case text == "":
+			row.WriteString("<c/>")
+		case number:
+			row.WriteString("<c><v>" + text + "</v></c>")
+		default:
+			row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(text) + "</t></is></c>")
+		}
+	}
+	row.WriteString("</row>")
+	if _, err := io.WriteString(e.sheet, row.String()); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		if err := e.zip.Flush(); err != nil {
+			return err
+		}
+		flushResponse(e.w)
+	}
+	return nil
+}
+
+func (e *xlsxExporter) Close() error {
+	if _, err := io.WriteString(e.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+// xmlEscape экранирует текст для XML; недопустимые в XML символы заменяются
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
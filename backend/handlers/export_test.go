@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"fitness-club/models"
+)
+
+func TestExportCell(t *testing.T) {
+	var nilMoney *models.Money
+	var nilTime *time.Time
+	var nilInt *int
+	family := 7
+	price := models.NewMoney(200050)
+	at := time.Date(2026, time.March, 5, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		text   string
+		number bool
+	}{
+		{"nil", nil, "", false},
+		{"строка", "Иванов", "Иванов", false},
+		{"int", 42, "42", true},
+		{"int64", int64(-3), "-3", true},
+		{"float64", 12.5, "12.5", true},
+		{"true", true, "да", false},
+		{"false", false, "нет", false},
+		{"деньги", price, "2000.50", true},
+		{"указатель на деньги", &price, "2000.50", true},
+		{"пустые деньги", nilMoney, "", false},
+		{"время", at, "2026-03-05 09:30", false},
+		{"указатель на время", &at, "2026-03-05 09:30", false},
+		{"пустое время", nilTime, "", false},
+		{"указатель на int", &family, "7", true},
+		{"пустой int", nilInt, "", false},
+		{"прочее", models.WholePercent(15), "15.00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, number := exportCell(tt.value)
+			if text != tt.text || number != tt.number {
+				t.Errorf("exportCell(%v) = %q, %v, ожидалось %q, %v", tt.value, text, number, tt.text, tt.number)
+			}
+		})
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out, err := newExporter(rec, "csv", "clients", "ID", "Имя")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.WriteRow(1, "Петров; Петр"); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.WriteRow(-2, "=HYPERLINK(\"http://example.com\")"); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	in := csv.NewReader(rec.Body)
+	in.Comma = ';'
+	records, err := in.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "1" || records[1][1] != "Петров; Петр" {
+		t.Fatalf("неожиданное содержимое CSV: %q", records)
+	}
+	if records[2][0] != "-2" || records[2][1] != `'=HYPERLINK("http://example.com")` {
+		t.Errorf("формула не экранирована: %q", records[2])
+	}
+}
+
+// xlsxSheet — разбор листа книги, достаточный для проверки ячеек
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXExport(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out, err := newExporter(rec, "xlsx", "clients & co", "ID", "Имя", "Сумма", "Семья")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if err := out.WriteRow(1, `<Анна & "Ко">`+"\x01", models.NewMoney(150), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Строк больше, чем exportFlushRows, чтобы проверить промежуточную отправку архива
+	for i := 2; i <= exportFlushRows+10; i++ {
+		if err := out.WriteRow(i, "Клиент "+strconv.Itoa(i), models.Rubles(int64(i)), &i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	body := rec.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("выгрузка не является zip-архивом: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		// Каждая часть книги должна быть корректным XML
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: некорректный XML: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = data
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("в книге нет части %s", name)
+		}
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatal(err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "clients & co" {
+		t.Errorf("листы книги: %+v", workbook.Sheets)
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != exportFlushRows+11 {
+		t.Fatalf("строк на листе: %d, ожидалось %d", len(sheet.Rows), exportFlushRows+11)
+	}
+	header := sheet.Rows[0].Cells
+	if len(header) != 4 || header[0].Type != "inlineStr" || header[1].Inline != "Имя" {
+		t.Errorf("неверная строка заголовков: %+v", header)
+	}
+	first := sheet.Rows[1].Cells
+	if len(first) != 4 {
+		t.Fatalf("ячеек в строке: %d", len(first))
+	}
+	if first[0].Type != "" || first[0].Value != "1" {
+		t.Errorf("ID должен быть числом: %+v", first[0])
+	}
+	if first[1].Type != "inlineStr" || first[1].Inline != `<Анна & "Ко">`+"\uFFFD" {
+		t.Errorf("текст экранирован неверно: %q", first[1].Inline)
+	}
+	if first[2].Value != "1.50" {
+		t.Errorf("сумма: %q, ожидалось 1.50", first[2].Value)
+	}
+	if first[3].Value != "" || first[3].Inline != "" {
+		t.Errorf("пустое значение должно давать пустую ячейку: %+v", first[3])
+	}
+	last := sheet.Rows[len(sheet.Rows)-1].Cells
+	if want := strconv.Itoa(exportFlushRows + 10); last[0].Value != want || last[3].Value != want {
+		t.Errorf("последняя строка: %+v", last)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/importer"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize — максимальный размер загружаемого CSV
+const maxImportSize = 10 << 20
+
+// ImportMembers загружает клиентов и абонементы из CSV.
+// Файл передается полем file формы multipart или телом запроса (text/csv).
+// Параметры: dry_run, batch_size (0 — одна транзакция), start_row, update_existing.
+func ImportMembers(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/import/members - импорт клиентов из CSV")
+
+	opts := importer.Options{
+		DryRun:         r.URL.Query().Get("dry_run") == "true",
+		UpdateExisting: r.URL.Query().Get("update_existing") == "true",
+	}
+	for param, target := range map[string]*int{"batch_size": &opts.BatchSize, "start_row": &opts.StartRow} {
+		if value := r.URL.Query().Get(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "Неверное значение "+param, http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Файл не передан: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		file = part
+	}
+
+	report, err := importer.Import(database.DB, file, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+	log.Printf("Импорт клиентов (dry_run=%v): строк %d, создано %d, обновлено %d, пропущено %d, ошибок %d",
+		report.DryRun, report.TotalRows, report.Created, report.Updated, report.Skipped, report.Failed)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fitness-club/pdf"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Шрифты с кириллицей, которые ищем, если INVOICE_FONT_PATH не задан
+var invoiceFontPaths = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/Library/Fonts/Arial.ttf",
+	"/System/Library/Fonts/Supplemental/Arial.ttf",
+	"C:\\Windows\\Fonts\\arial.ttf",
+}
+
+// invoiceColumns — поля счета без PDF. Реквизиты и отметка об оплате фиксируются
+// при выставлении, поэтому сохраненный документ с тем же номером не меняется.
+const invoiceColumns = `
+	i.id, i.subscription_id, i.year, i.sequence_number, i.number, i.client_name, i.client_email,
+	i.description, i.amount, i.issued_at, i.payment_status, i.paid_at
+`
+
+// scanInvoice читает счет, выбранный по invoiceColumns; extra — дополнительные колонки после них
+func scanInvoice(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Invoice, error) {
+	var inv models.Invoice
+	var subscriptionID sql.NullInt64
+	var paidAt sql.NullTime
+	dest := append([]interface{}{&inv.ID, &subscriptionID, &inv.Year, &inv.SequenceNumber, &inv.Number,
+		&inv.ClientName, &inv.ClientEmail, &inv.Description, &inv.Amount, &inv.IssuedAt,
+		&inv.PaymentStatus, &paidAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return inv, err
+	}
+	if subscriptionID.Valid {
+		id := int(subscriptionID.Int64)
+		inv.SubscriptionID = &id
+	}
+	if paidAt.Valid {
+		inv.PaidAt = &paidAt.Time
+	}
+	return inv, nil
+}
+
+// GetInvoices возвращает список выставленных счетов (без PDF)
+func GetInvoices(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/invoices - получение списка счетов")
+
+	query := `SELECT ` + invoiceColumns + ` FROM invoices i`
+	args := []interface{}{}
+	if year := r.URL.Query().Get("year"); year != "" {
+		query += " WHERE i.year = $1"
+		args = append(args, year)
+	}
+	query += " ORDER BY i.year DESC, i.sequence_number DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	invoices := make([]models.Invoice, 0)
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		invoices = append(invoices, inv)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoices)
+	log.Printf("Возвращено счетов: %d", len(invoices))
+}
+
+// GetSubscriptionInvoice отдает PDF счета по абонементу, выставляя его при первом запросе
+func GetSubscriptionInvoice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/subscriptions/%d/invoice.pdf - получение счета", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	// Клиент может получить только счет по своему абонементу
+	if user.Role != "admin" {
+		ownerID, err := subscriptionOwner(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Абонемент не найден", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка проверки владельца абонемента: %v", err)
+			http.Error(w, "Ошибка проверки абонемента", http.StatusInternalServerError)
+			return
+		}
+		if ownerID != user.ID {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+			return
+		}
+	}
+
+	inv, err := issueInvoice(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Абонемент не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка выставления счета: %v", err)
+		http.Error(w, "Ошибка формирования счета", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%s.pdf"`, inv.Number))
+	w.Header().Set("Content-Length", strconv.Itoa(len(inv.PDF)))
+	w.Write(inv.PDF)
+}
+
+// loadInvoice загружает уже выставленный счет по абонементу вместе с сохраненным PDF
+func loadInvoice(subscriptionID int) (*models.Invoice, error) {
+	var document []byte
+	inv, err := scanInvoice(database.DB.QueryRow(`
+		SELECT `+invoiceColumns+`, i.pdf FROM invoices i WHERE i.subscription_id = $1
+	`, subscriptionID), &document)
+	if err != nil {
+		return nil, err
+	}
+	inv.PDF = document
+	return &inv, nil
+}
+
+// issueInvoice выставляет счет по абонементу, если он еще не выставлен, и сохраняет его PDF.
+// Номер берется из счетчика года в той же транзакции, что и вставка счета,
+// поэтому при любой ошибке номер не расходуется и нумерация идет без пропусков.
+func issueInvoice(subscriptionID int) (*models.Invoice, error) {
+	if inv, err := loadInvoice(subscriptionID); err != sql.ErrNoRows {
+		return inv, err
+	}
+
+	inv := &models.Invoice{SubscriptionID: &subscriptionID, IssuedAt: time.Now()}
+	inv.Year = inv.IssuedAt.Year()
+
+	// Отметка об оплате берется по последнему платежу за абонемент на момент выставления
+	var subType string
+	var startDate, endDate time.Time
+	var paidAt sql.NullTime
+	err := database.DB.QueryRow(`
+		SELECT u.name, u.email, s.type, s.start_date, s.end_date, s.price, COALESCE(p.status, ''), p.paid_at
+		FROM subscriptions s
+		JOIN clients c ON s.client_id = c.id
+		JOIN users u ON c.user_id = u.id
+		LEFT JOIN LATERAL (
+			SELECT status, paid_at FROM payments
+			WHERE subscription_id = s.id AND kind = 'payment'
+			ORDER BY id DESC
+			LIMIT 1
+		) p ON true
+		WHERE s.id = $1
+	`, subscriptionID).Scan(&inv.ClientName, &inv.ClientEmail, &subType, &startDate, &endDate, &inv.Amount,
+		&inv.PaymentStatus, &paidAt)
+	if err != nil {
+		return nil, err
+	}
+	if paidAt.Valid {
+		inv.PaidAt = &paidAt.Time
+	}
+	inv.Description = fmt.Sprintf("Абонемент «%s» с %s по %s",
+		subType, startDate.Format("02.01.2006"), endDate.Format("02.01.2006"))
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Строка счетчика блокируется до конца транзакции, параллельные счета ждут своей очереди
+	err = tx.QueryRow(`
+		INSERT INTO invoice_counters (year, last_number) VALUES ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_counters.last_number + 1
+		RETURNING last_number
+	`, inv.Year).Scan(&inv.SequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	inv.Number = fmt.Sprintf("%d-%06d", inv.Year, inv.SequenceNumber)
+
+	inv.PDF, err = renderInvoicePDF(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO invoices (subscription_id, year, sequence_number, number, client_name, client_email,
+		                      description, amount, issued_at, payment_status, paid_at, pdf)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, subscriptionID, inv.Year, inv.SequenceNumber, inv.Number, inv.ClientName, inv.ClientEmail,
+		inv.Description, inv.Amount, inv.IssuedAt, inv.PaymentStatus, inv.PaidAt, inv.PDF).Scan(&inv.ID)
+	if err != nil {
+		// Счет успел выставить параллельный запрос — отдаем его
+		if strings.Contains(err.Error(), "duplicate key") {
+			tx.Rollback()
+			return loadInvoice(subscriptionID)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.Printf("Выставлен счет %s по абонементу %d", inv.Number, subscriptionID)
+	return inv, nil
+}
+
+// formatMoney форматирует сумму для документов: "18 000,00 руб."
+func formatMoney(m models.Money) string {
+	amount := m.String()
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+	whole, frac, _ := strings.Cut(amount, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + " " + whole[i:]
+	}
+	symbol := m.Currency
+	if symbol == "" || symbol == "RUB" {
+		symbol = "руб."
+	}
+	return sign + whole + "," + frac + " " + symbol
+}
+
+// renderInvoicePDF формирует PDF счета-квитанции
+func renderInvoicePDF(inv *models.Invoice) ([]byte, error) {
+	doc := pdf.New()
+	if err := doc.LoadFirstFont(append([]string{getEnv("INVOICE_FONT_PATH", "")}, invoiceFontPaths...)...); err != nil {
+		log.Printf("Шрифт для счетов не найден (%v), кириллица будет транслитерирована", err)
+	}
+
+	const left, right = 50.0, pdf.PageWidth - 50
+	amount := formatMoney(inv.Amount)
+
+	doc.AddPage()
+	doc.Text(left, 60, 16, getEnv("CLUB_NAME", "Фитнес-клуб"))
+	y := 78.0
+	if address := getEnv("CLUB_ADDRESS", ""); address != "" {
+		doc.Text(left, y, 10, address)
+		y += 14
+	}
+	if inn := getEnv("CLUB_INN", ""); inn != "" {
+		doc.Text(left, y, 10, "ИНН "+inn)
+		y += 14
+	}
+	doc.Line(left, y, right, y, 0.8)
+
+	doc.Text(left, y+40, 18, "Счет-квитанция № "+inv.Number)
+	doc.Text(left, y+60, 11, "от "+inv.IssuedAt.Format("02.01.2006"))
+
+	doc.Text(left, y+95, 11, "Плательщик: "+inv.ClientName)
+	doc.Text(left, y+111, 11, "Email: "+inv.ClientEmail)
+
+	doc.Text(left, y+150, 10, "Наименование")
+	doc.TextRight(right, y+150, 10, "Сумма")
+	doc.Line(left, y+158, right, y+158, 0.5)
+	doc.Text(left, y+176, 11, inv.Description)
+	doc.TextRight(right, y+176, 11, amount)
+	doc.Line(left, y+190, right, y+190, 0.5)
+
+	doc.TextRight(right, y+212, 12, "Итого: "+amount)
+	doc.Text(left, y+245, 10, invoicePaymentNote(inv)+" НДС не облагается.")
+
+	doc.Text(left, pdf.PageHeight-40, 8, "Документ сформирован автоматически")
+	return doc.Bytes()
+}
+
+// invoicePaymentNote возвращает отметку об оплате по статусу платежа на момент выставления счета
+func invoicePaymentNote(inv *models.Invoice) string {
+	switch inv.PaymentStatus {
+	case "paid":
+		if inv.PaidAt != nil {
+			return "Оплачено полностью " + inv.PaidAt.Format("02.01.2006") + "."
+		}
+		return "Оплачено полностью."
+	case "failed":
+		return "Не оплачено."
+	case "pending":
+		return "Ожидает оплаты."
+	default:
+		if inv.Amount.Amount == 0 {
+			return "Оплата не требуется."
+		}
+		return "Ожидает оплаты."
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"fitness-club/models"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		money models.Money
+		want  string
+	}{
+		{models.NewMoney(1800000), "18 000,00 руб."},
+		{models.NewMoney(99), "0,99 руб."},
+		{models.NewMoney(-123456789), "-1 234 567,89 руб."},
+		{models.Money{Amount: 5000, Currency: "EUR"}, "50,00 EUR"},
+	}
+	for _, tt := range tests {
+		if got := formatMoney(tt.money); got != tt.want {
+			t.Errorf("formatMoney(%d) = %q, ожидалось %q", tt.money.Amount, got, tt.want)
+		}
+	}
+}
+
+func TestInvoicePaymentNote(t *testing.T) {
+	paidAt := time.Date(2026, time.March, 5, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		inv  models.Invoice
+		want string
+	}{
+		{"оплачен", models.Invoice{PaymentStatus: "paid", PaidAt: &paidAt, Amount: models.Rubles(100)}, "Оплачено полностью 05.03.2026."},
+		{"оплачен без даты", models.Invoice{PaymentStatus: "paid", Amount: models.Rubles(100)}, "Оплачено полностью."},
+		{"ожидает оплаты", models.Invoice{PaymentStatus: "pending", Amount: models.Rubles(100)}, "Ожидает оплаты."},
+		{"платеж не прошел", models.Invoice{PaymentStatus: "failed", Amount: models.Rubles(100)}, "Не оплачено."},
+		{"бесплатный", models.Invoice{Amount: models.NewMoney(0)}, "Оплата не требуется."},
+	}
+	for _, tt := range tests {
+		if got := invoicePaymentNote(&tt.inv); got != tt.want {
+			t.Errorf("%s: %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderInvoicePDF(t *testing.T) {
+	inv := &models.Invoice{
+		Number:        "2026-000042",
+		ClientName:    "Иванова Анна",
+		ClientEmail:   "anna@example.com",
+		Description:   "Абонемент «Месячный» с 01.03.2026 по 31.03.2026",
+		Amount:        models.Rubles(3500),
+		IssuedAt:      time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC),
+		PaymentStatus: "pending",
+	}
+	document, err := renderInvoicePDF(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(document, []byte("%PDF-")) || !bytes.HasSuffix(document, []byte("%%EOF\n")) {
+		t.Fatalf("результат не похож на PDF: %d байт", len(document))
+	}
+}
@@ -0,0 +1,727 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// leadSources — каналы, из которых приходят потенциальные клиенты
+var leadSources = map[string]bool{
+	"walk_in": true, "website": true, "referral": true, "social": true, "ads": true, "partner": true, "other": true,
+}
+
+// leadStatuses — этапы воронки; converted выставляется только при конверсии в клиента
+var leadStatuses = map[string]bool{"new": true, "contacted": true, "trial_booked": true, "converted": true, "lost": true}
+
+const leadColumns = `
+	l.id, l.name, COALESCE(l.email, ''), COALESCE(l.phone, ''), l.source, l.status, COALESCE(l.notes, ''),
+	COALESCE(l.lost_reason, ''), l.assigned_to, l.converted_user_id, l.converted_at, l.created_by,
+	l.created_at, l.updated_at
+`
+
+func scanLead(row interface{ Scan(...interface{}) error }) (models.Lead, error) {
+	var l models.Lead
+	var assignedTo, convertedUserID, createdBy sql.NullInt64
+	var convertedAt sql.NullTime
+	err := row.Scan(&l.ID, &l.Name, &l.Email, &l.Phone, &l.Source, &l.Status, &l.Notes,
+		&l.LostReason, &assignedTo, &convertedUserID, &convertedAt, &createdBy,
+		&l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return l, err
+	}
+	if assignedTo.Valid {
+		id := int(assignedTo.Int64)
+		l.AssignedTo = &id
+	}
+	if convertedUserID.Valid {
+		id := int(convertedUserID.Int64)
+		l.ConvertedUserID = &id
+	}
+	if convertedAt.Valid {
+		l.ConvertedAt = &convertedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		l.CreatedBy = &id
+	}
+	return l, nil
+}
+
+// loadLeadTrials возвращает пробные тренировки лида
+func loadLeadTrials(q queryer, leadID int) ([]models.LeadTrial, error) {
+	rows, err := q.Query(`
+		SELECT lt.id, lt.lead_id, lt.training_id, t.title, t.start_time, lt.status, lt.created_at
+		FROM lead_trials lt
+		JOIN trainings t ON lt.training_id = t.id
+		WHERE lt.lead_id = $1
+		ORDER BY t.start_time
+	`, leadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trials := make([]models.LeadTrial, 0)
+	for rows.Next() {
+		var t models.LeadTrial
+		if err := rows.Scan(&t.ID, &t.LeadID, &t.TrainingID, &t.TrainingTitle, &t.StartTime, &t.Status, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		trials = append(trials, t)
+	}
+	return trials, rows.Err()
+}
+
+// validateLead проверяет и нормализует данные лида
+func validateLead(l *models.Lead) error {
+	l.Name = strings.TrimSpace(l.Name)
+	l.Email = strings.ToLower(strings.TrimSpace(l.Email))
+	l.Phone = strings.TrimSpace(l.Phone)
+	if l.Name == "" {
+		return validationError("Имя обязательно")
+	}
+	if l.Email == "" && l.Phone == "" {
+		return validationError("Укажите email или телефон")
+	}
+	if l.Email != "" && !strings.Contains(l.Email, "@") {
+		return validationError("Неверный email")
+	}
+	if l.Source == "" {
+		l.Source = "other"
+	}
+	if !leadSources[l.Source] {
+		return validationError("Неизвестный источник. Доступны: walk_in, website, referral, social, ads, partner, other")
+	}
+	if l.Status == "" {
+		l.Status = "new"
+	}
+	if !leadStatuses[l.Status] {
+		return validationError("status должен быть new, contacted, trial_booked или lost")
+	}
+	if l.Status == "converted" {
+		return validationError("Для перевода в клиенты используйте POST /api/leads/{id}/convert")
+	}
+	if l.Status == "lost" && strings.TrimSpace(l.LostReason) == "" {
+		return validationError("Укажите причину потери лида")
+	}
+	return nil
+}
+
+// GetLeads возвращает лидов с фильтрами status, source, assigned_to и поиском q по имени, email и телефону
+func GetLeads(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/leads - получение списка лидов")
+
+	params := r.URL.Query()
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	query := `SELECT ` + leadColumns + ` FROM leads l WHERE 1=1`
+	if status := params.Get("status"); status != "" {
+		query += " AND l.status = " + arg(status)
+	}
+	if source := params.Get("source"); source != "" {
+		query += " AND l.source = " + arg(source)
+	}
+	if assignedTo := params.Get("assigned_to"); assignedTo != "" {
+		query += " AND l.assigned_to = " + arg(assignedTo)
+	}
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		conditions := []string{"l.name ILIKE " + arg(likePattern(q)), "l.email ILIKE " + arg(likePattern(q))}
+		if digits := normalizePhone(q); len(digits) >= 3 {
+			conditions = append(conditions, `regexp_replace(l.phone, '\D', '', 'g') LIKE `+arg(likePattern(digits)))
+		}
+		query += " AND (" + strings.Join(conditions, " OR ") + ")"
+	}
+	query += " ORDER BY l.created_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	leads := make([]models.Lead, 0)
+	for rows.Next() {
+		l, err := scanLead(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		leads = append(leads, l)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leads)
+	log.Printf("Возвращено лидов: %d", len(leads))
+}
+
+// GetLead возвращает лида с пробными тренировками
+func GetLead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/leads/%d - получение лида", id)
+
+	l, err := scanLead(database.DB.QueryRow(`SELECT `+leadColumns+` FROM leads l WHERE l.id = $1`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Лид не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if l.Trials, err = loadLeadTrials(database.DB, id); err != nil {
+		log.Printf("Ошибка загрузки пробных тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l)
+}
+
+// CreateLead добавляет потенциального клиента
+func CreateLead(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/leads - добавление лида")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var l models.Lead
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateLead(&l); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Зарегистрированного пользователя не заводим повторно как лида
+	if l.Email != "" {
+		var exists bool
+		err = database.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = $1)`, l.Email).Scan(&exists)
+		if err != nil {
+			log.Printf("Ошибка проверки email: %v", err)
+			http.Error(w, "Ошибка проверки email", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "Пользователь с таким email уже зарегистрирован", http.StatusConflict)
+			return
+		}
+	}
+
+	created, err := scanLead(database.DB.QueryRow(`
+		INSERT INTO leads AS l (name, email, phone, source, status, notes, lost_reason, assigned_to, created_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9)
+		RETURNING `+leadColumns,
+		l.Name, l.Email, l.Phone, l.Source, l.Status, l.Notes, l.LostReason, l.AssignedTo, user.ID))
+	if err != nil {
+		log.Printf("Ошибка создания лида: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Добавлен лид %s (ID: %d), источник: %s", created.Name, created.ID, created.Source)
+}
+
+// UpdateLead изменяет контакты, источник, статус и ответственного лида
+func UpdateLead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/leads/%d - обновление лида", id)
+
+	var l models.Lead
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateLead(&l); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	err = database.DB.QueryRow(`SELECT status FROM leads WHERE id = $1`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Лид не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == "converted" {
+		http.Error(w, "Лид уже переведен в клиенты", http.StatusConflict)
+		return
+	}
+
+	updated, err := scanLead(database.DB.QueryRow(`
+		UPDATE leads AS l
+		SET name = $1, email = NULLIF($2, ''), phone = NULLIF($3, ''), source = $4, status = $5,
+		    notes = NULLIF($6, ''), lost_reason = CASE WHEN $5 = 'lost' THEN $7 END, assigned_to = $8, updated_at = NOW()
+		WHERE l.id = $9
+		RETURNING `+leadColumns,
+		l.Name, l.Email, l.Phone, l.Source, l.Status, l.Notes, strings.TrimSpace(l.LostReason), l.AssignedTo, id))
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлен лид %d, статус: %s", id, updated.Status)
+}
+
+// BookLeadTrial записывает лида на бесплатную пробную тренировку.
+// Абонемент не нужен; число пробных тренировок ограничено LEAD_TRIAL_LIMIT (по умолчанию 1).
+// При включенной политике REQUIRE_WAIVER пробная запись запрещена.
+func BookLeadTrial(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/leads/%d/trials - запись на пробную тренировку", id)
+
+	var req struct {
+		TrainingID int `json:"training_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TrainingID == 0 {
+		http.Error(w, "Укажите training_id", http.StatusBadRequest)
+		return
+	}
+
+	// Политика REQUIRE_WAIVER действует и для пробных тренировок. Подписать отказ от претензий
+	// может только клиент, поэтому лида сначала нужно оформить клиентом.
+	if waiverRequired() {
+		http.Error(w, "Клуб требует подписанный отказ от претензий и анкету здоровья. Оформите лида клиентом и запишите его на тренировку", http.StatusForbidden)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM leads WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Лид не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == "converted" || status == "lost" {
+		http.Error(w, "Лид закрыт, запись на пробную тренировку невозможна", http.StatusConflict)
+		return
+	}
+
+	var used int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM lead_trials WHERE lead_id = $1 AND status <> 'cancelled'`, id).Scan(&used)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if limit := getEnvInt("LEAD_TRIAL_LIMIT", 1); used >= limit {
+		http.Error(w, "Лимит пробных тренировок исчерпан", http.StatusConflict)
+		return
+	}
+
+	var training models.Training
+	err = tx.QueryRow(`
+		SELECT max_participants, current_participants, status, start_time
+		FROM trainings
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, req.TrainingID).Scan(&training.MaxParticipants, &training.CurrentParticipants, &training.Status, &training.StartTime)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Тренировка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if training.Status != "scheduled" || training.StartTime.Before(time.Now()) {
+		http.Error(w, "Записаться можно только на предстоящую тренировку", http.StatusBadRequest)
+		return
+	}
+	if training.CurrentParticipants >= training.MaxParticipants {
+		http.Error(w, "Нет свободных мест", http.StatusBadRequest)
+		return
+	}
+
+	// Отмененную ранее запись на ту же тренировку восстанавливаем
+	var trialID int
+	err = tx.QueryRow(`
+		INSERT INTO lead_trials (lead_id, training_id) VALUES ($1, $2)
+		ON CONFLICT (lead_id, training_id) DO UPDATE SET status = 'booked'
+		WHERE lead_trials.status = 'cancelled'
+		RETURNING id
+	`, id, req.TrainingID).Scan(&trialID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Лид уже записан на эту тренировку", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка записи на пробную тренировку: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE trainings SET current_participants = current_participants + 1 WHERE id = $1`, req.TrainingID); err != nil {
+		log.Printf("Ошибка обновления счетчика: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE leads SET status = 'trial_booked', updated_at = NOW() WHERE id = $1`, id); err != nil {
+		log.Printf("Ошибка обновления лида: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	trials, err := loadLeadTrials(tx, id)
+	if err != nil {
+		log.Printf("Ошибка загрузки пробных тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(trials)
+	log.Printf("Лид %d записан на пробную тренировку %d", id, req.TrainingID)
+}
+
+// UpdateLeadTrial отмечает итог пробной тренировки: attended, no_show или cancelled (освобождает место)
+func UpdateLeadTrial(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+	trialID, err := strconv.Atoi(vars["trialId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/leads/%d/trials/%d - итог пробной тренировки", id, trialID)
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if req.Status != "attended" && req.Status != "no_show" && req.Status != "cancelled" {
+		http.Error(w, "status должен быть attended, no_show или cancelled", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var current string
+	var trainingID int
+	err = tx.QueryRow(`
+		SELECT status, training_id FROM lead_trials WHERE id = $1 AND lead_id = $2 FOR UPDATE
+	`, trialID, id).Scan(&current, &trainingID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Пробная тренировка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if current == "cancelled" {
+		http.Error(w, "Запись на пробную тренировку отменена", http.StatusConflict)
+		return
+	}
+	if req.Status == "cancelled" && current != "booked" {
+		http.Error(w, "Отменить можно только предстоящую запись", http.StatusConflict)
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE lead_trials SET status = $1 WHERE id = $2`, req.Status, trialID); err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.Status == "cancelled" {
+		_, err = tx.Exec(`
+			UPDATE trainings SET current_participants = GREATEST(current_participants - 1, 0) WHERE id = $1
+		`, trainingID)
+		if err != nil {
+			log.Printf("Ошибка обновления счетчика: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	trials, err := loadLeadTrials(tx, id)
+	if err != nil {
+		log.Printf("Ошибка загрузки пробных тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trials)
+	log.Printf("Пробная тренировка %d лида %d: %s", trialID, id, req.Status)
+}
+
+// convertLeadResponse — результат конверсии лида
+type convertLeadResponse struct {
+	Lead     models.Lead `json:"lead"`
+	User     models.User `json:"user"` // с паролем, если он был сгенерирован
+	ClientID int         `json:"client_id"`
+}
+
+// ConvertLead создает по лиду пользователя и клиента. Клиент сохраняет ссылку на лида для отчетов по
+// источникам, а пробные тренировки переносятся в историю тренировок нового пользователя.
+func ConvertLead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/leads/%d/convert - перевод лида в клиенты", id)
+
+	var req struct {
+		Email    string `json:"email"`    // если у лида нет email
+		Password string `json:"password"` // по умолчанию генерируется
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	lead, err := scanLead(tx.QueryRow(`SELECT `+leadColumns+` FROM leads l WHERE l.id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Лид не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lead.Status == "converted" {
+		http.Error(w, "Лид уже переведен в клиенты", http.StatusConflict)
+		return
+	}
+
+	user := models.User{Name: lead.Name, Email: lead.Email, Password: strings.TrimSpace(req.Password), Role: "user"}
+	if email := strings.ToLower(strings.TrimSpace(req.Email)); email != "" {
+		user.Email = email
+	}
+	if user.Email == "" {
+		http.Error(w, "У лида нет email, укажите его для создания учетной записи", http.StatusBadRequest)
+		return
+	}
+	if user.Password == "" {
+		user.Password = generateRandomPassword()
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`, user.Name, user.Email, user.Password, user.Role).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Пользователь с таким email уже существует", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка создания пользователя: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := convertLeadResponse{User: user}
+	err = tx.QueryRow(`
+		INSERT INTO clients (user_id, phone, lead_id) VALUES ($1, NULLIF($2, ''), $3) RETURNING id
+	`, user.ID, lead.Phone, id).Scan(&resp.ClientID)
+	if err != nil {
+		log.Printf("Ошибка создания клиента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Места на тренировках уже учтены при записи на пробную, счетчики не меняются
+	_, err = tx.Exec(`
+		INSERT INTO training_participants (training_id, user_id, status, registered_at)
+		SELECT lt.training_id, $1, CASE WHEN lt.status = 'attended' THEN 'attended' ELSE 'registered' END, lt.created_at
+		FROM lead_trials lt
+		WHERE lt.lead_id = $2 AND lt.status IN ('booked', 'attended')
+		ON CONFLICT (training_id, user_id) DO NOTHING
+	`, user.ID, id)
+	if err != nil {
+		log.Printf("Ошибка переноса пробных тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp.Lead, err = scanLead(tx.QueryRow(`
+		UPDATE leads AS l
+		SET status = 'converted', converted_user_id = $1, converted_at = NOW(), updated_at = NOW()
+		WHERE l.id = $2
+		RETURNING `+leadColumns, user.ID, id))
+	if err != nil {
+		log.Printf("Ошибка обновления лида: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+	log.Printf("Лид %d переведен в клиенты: пользователь %d, клиент %d", id, user.ID, resp.ClientID)
+}
+
+// leadSourceStats — воронка по одному источнику
+type leadSourceStats struct {
+	Source         string       `json:"source"`
+	Leads          int          `json:"leads"`
+	TrialsBooked   int          `json:"trials_booked"` // лиды хотя бы с одной пробной тренировкой
+	TrialsAttended int          `json:"trials_attended"`
+	Converted      int          `json:"converted"`
+	Lost           int          `json:"lost"`
+	ConversionRate float64      `json:"conversion_rate"` // процент конверсии в клиентов
+	Revenue        models.Money `json:"revenue"`         // оплаты клиентов, пришедших из источника
+}
+
+// GetLeadStats возвращает воронку лидов по источникам за период from–to (YYYY-MM-DD, по дате создания лида)
+func GetLeadStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/leads/stats - воронка лидов по источникам")
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			http.Error(w, "Неверный формат даты, ожидается YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT l.source,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE EXISTS(SELECT 1 FROM lead_trials lt WHERE lt.lead_id = l.id AND lt.status <> 'cancelled')),
+		       COUNT(*) FILTER (WHERE EXISTS(SELECT 1 FROM lead_trials lt WHERE lt.lead_id = l.id AND lt.status = 'attended')),
+		       COUNT(*) FILTER (WHERE l.status = 'converted'),
+		       COUNT(*) FILTER (WHERE l.status = 'lost'),
+		       COALESCE(SUM((
+		           SELECT SUM(CASE WHEN p.kind = 'refund' THEN -p.amount ELSE p.amount END)
+		           FROM clients c JOIN payments p ON p.client_id = c.id
+		           WHERE c.lead_id = l.id AND p.status = 'paid'
+		       )), 0)
+		FROM leads l
+		WHERE ($1 = '' OR l.created_at >= $1::date) AND ($2 = '' OR l.created_at < $2::date + 1)
+		GROUP BY l.source
+		ORDER BY COUNT(*) DESC
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	stats := make([]leadSourceStats, 0)
+	for rows.Next() {
+		var s leadSourceStats
+		err := rows.Scan(&s.Source, &s.Leads, &s.TrialsBooked, &s.TrialsAttended, &s.Converted, &s.Lost, &s.Revenue)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		if s.Leads > 0 {
+			s.ConversionRate = round2(float64(s.Converted) / float64(s.Leads) * 100)
+		}
+		stats = append(stats, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,646 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Заявку на отсутствие подает сам сотрудник или администратор за него. После одобрения
+// администратор получает список тренировок сотрудника в эти дни с возможными заменами —
+// тренерами с нужными сертификатами, у которых нет пересекающихся тренировок и одобренного
+// отсутствия, — и переназначает их одним запросом. Записанные участники получают уведомление.
+
+// leaveTypes — виды отсутствия
+var leaveTypes = map[string]bool{"sick": true, "vacation": true, "other": true}
+
+const leaveColumns = `
+	lr.id, lr.employee_id, u.name, lr.leave_type, lr.start_date, lr.end_date, COALESCE(lr.reason, ''),
+	lr.status, COALESCE(lr.review_comment, ''), lr.reviewed_by, lr.reviewed_at, lr.created_by, lr.created_at
+`
+
+const leaveFrom = `
+	FROM leave_requests lr
+	JOIN employees e ON lr.employee_id = e.id
+	JOIN users u ON e.user_id = u.id
+`
+
+func scanLeaveRequest(row interface{ Scan(...interface{}) error }) (models.LeaveRequest, error) {
+	var l models.LeaveRequest
+	var reviewedBy, createdBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&l.ID, &l.EmployeeID, &l.EmployeeName, &l.LeaveType, &l.StartDate, &l.EndDate, &l.Reason,
+		&l.Status, &l.ReviewComment, &reviewedBy, &reviewedAt, &createdBy, &l.CreatedAt)
+	if err != nil {
+		return l, err
+	}
+	if reviewedBy.Valid {
+		id := int(reviewedBy.Int64)
+		l.ReviewedBy = &id
+	}
+	if reviewedAt.Valid {
+		l.ReviewedAt = &reviewedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		l.CreatedBy = &id
+	}
+	return l, nil
+}
+
+func getLeaveRequest(q queryer, id int) (models.LeaveRequest, error) {
+	return scanLeaveRequest(q.QueryRow(`SELECT `+leaveColumns+leaveFrom+` WHERE lr.id = $1`, id))
+}
+
+// leaveTraining — тренировка, попадающая на отсутствие тренера
+type leaveTraining struct {
+	models.AffectedTraining
+	TrainerID       int
+	DurationMinutes int
+}
+
+// substituteCandidates возвращает тренеров, которые могут провести тренировку вместо текущего:
+// с действующими сертификатами, без пересекающихся тренировок и одобренного отсутствия в этот день
+func substituteCandidates(q queryer, t leaveTraining) ([]models.SubstituteUser, error) {
+	rows, err := q.Query(`
+		SELECT u.id, u.name
+		FROM users u
+		WHERE u.role IN ('trainer', 'admin') AND u.deleted_at IS NULL AND u.id <> $1
+		AND NOT EXISTS (
+			SELECT 1 FROM trainings o
+			WHERE o.trainer_id = u.id AND o.id <> $2 AND o.deleted_at IS NULL AND o.status <> 'cancelled'
+			AND o.start_time < $3::timestamp + $4::int * INTERVAL '1 minute'
+			AND o.start_time + o.duration_minutes * INTERVAL '1 minute' > $3::timestamp
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM leave_requests lr
+			JOIN employees e ON lr.employee_id = e.id
+			WHERE e.user_id = u.id AND lr.status = 'approved'
+			AND $3::date BETWEEN lr.start_date AND lr.end_date
+		)
+		ORDER BY u.name
+	`, t.TrainerID, t.TrainingID, t.StartTime, t.DurationMinutes)
+	if err != nil {
+		return nil, err
+	}
+	var available []models.SubstituteUser
+	for rows.Next() {
+		var s models.SubstituteUser
+		if err := rows.Scan(&s.UserID, &s.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		available = append(available, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]models.SubstituteUser, 0, len(available))
+	for _, s := range available {
+		missing, err := missingQualifications(q, s.UserID, t.HallType, t.Type, t.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) == 0 {
+			candidates = append(candidates, s)
+		}
+	}
+	return candidates, nil
+}
+
+// leaveTrainings возвращает запланированные тренировки сотрудника в дни отсутствия
+func leaveTrainings(q queryer, l models.LeaveRequest) ([]leaveTraining, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.title, t.type, t.hall_type, t.start_time, t.duration_minutes, t.trainer_id,
+		       (SELECT COUNT(*) FROM training_participants tp WHERE tp.training_id = t.id AND tp.status = 'registered')
+		FROM trainings t
+		JOIN employees e ON e.user_id = t.trainer_id
+		WHERE e.id = $1 AND t.deleted_at IS NULL AND t.status = 'scheduled'
+		AND t.start_time >= $2 AND t.start_time < $3::date + 1
+		ORDER BY t.start_time
+	`, l.EmployeeID, l.StartDate, l.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trainings []leaveTraining
+	for rows.Next() {
+		var t leaveTraining
+		err := rows.Scan(&t.TrainingID, &t.Title, &t.Type, &t.HallType, &t.StartTime, &t.DurationMinutes,
+			&t.TrainerID, &t.Participants)
+		if err != nil {
+			return nil, err
+		}
+		trainings = append(trainings, t)
+	}
+	return trainings, rows.Err()
+}
+
+// affectedTrainings возвращает тренировки отсутствующего сотрудника вместе с возможными заменами
+func affectedTrainings(q queryer, l models.LeaveRequest) ([]models.AffectedTraining, error) {
+	trainings, err := leaveTrainings(q, l)
+	if err != nil {
+		return nil, err
+	}
+	affected := make([]models.AffectedTraining, 0, len(trainings))
+	for _, t := range trainings {
+		if t.Substitutes, err = substituteCandidates(q, t); err != nil {
+			return nil, err
+		}
+		affected = append(affected, t.AffectedTraining)
+	}
+	return affected, nil
+}
+
+// GetLeaveRequests возвращает заявки на отсутствие; сотрудник видит только свои.
+// Фильтры: status, employee_id.
+func GetLeaveRequests(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/leave-requests - получение заявок на отсутствие")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	employeeID := 0
+	if value := r.URL.Query().Get("employee_id"); value != "" {
+		if employeeID, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Неверный employee_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if user.Role != "admin" {
+		own, err := employeeForUser(user.ID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Вы не оформлены сотрудником клуба", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка поиска сотрудника: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if employeeID != 0 && employeeID != own {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+			return
+		}
+		employeeID = own
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+leaveColumns+leaveFrom+`
+		WHERE ($1 = 0 OR lr.employee_id = $1) AND ($2 = '' OR lr.status = $2)
+		ORDER BY lr.start_date DESC, lr.id DESC
+	`, employeeID, r.URL.Query().Get("status"))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	requests := make([]models.LeaveRequest, 0)
+	for rows.Next() {
+		l, err := scanLeaveRequest(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		requests = append(requests, l)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// CreateLeaveRequest подает заявку на отсутствие. Сотрудник подает за себя,
+// администратор может указать employee_id.
+func CreateLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/leave-requests - подача заявки на отсутствие")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		EmployeeID int    `json:"employee_id"`
+		LeaveType  string `json:"leave_type"`
+		StartDate  string `json:"start_date"` // YYYY-MM-DD
+		EndDate    string `json:"end_date"`   // YYYY-MM-DD включительно
+		Reason     string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if !leaveTypes[req.LeaveType] {
+		http.Error(w, "leave_type должен быть sick, vacation или other", http.StatusBadRequest)
+		return
+	}
+	start, errStart := time.Parse("2006-01-02", req.StartDate)
+	end, errEnd := time.Parse("2006-01-02", req.EndDate)
+	if errStart != nil || errEnd != nil {
+		http.Error(w, "Неверный формат даты, ожидается YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) {
+		http.Error(w, "end_date не может быть раньше start_date", http.StatusBadRequest)
+		return
+	}
+
+	if user.Role != "admin" || req.EmployeeID == 0 {
+		own, err := employeeForUser(user.ID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Вы не оформлены сотрудником клуба", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка поиска сотрудника: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if req.EmployeeID != 0 && req.EmployeeID != own {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+			return
+		}
+		req.EmployeeID = own
+	} else if _, err := employeeName(database.DB, req.EmployeeID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Сотрудник не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var overlapping bool
+	err = database.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM leave_requests
+			WHERE employee_id = $1 AND status IN ('pending', 'approved')
+			AND start_date <= $3 AND end_date >= $2
+		)
+	`, req.EmployeeID, start, end).Scan(&overlapping)
+	if err != nil {
+		log.Printf("Ошибка проверки пересечений: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if overlapping {
+		http.Error(w, "На эти даты уже есть заявка на отсутствие", http.StatusConflict)
+		return
+	}
+
+	created, err := scanLeaveRequest(database.DB.QueryRow(`
+		WITH lr AS (
+			INSERT INTO leave_requests (employee_id, leave_type, start_date, end_date, reason, created_by)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			RETURNING *
+		)
+		SELECT `+leaveColumns+`
+		FROM lr
+		JOIN employees e ON lr.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+	`, req.EmployeeID, req.LeaveType, start, end, req.Reason, user.ID))
+	if err != nil {
+		log.Printf("Ошибка создания заявки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Заявка на отсутствие %d сотрудника %d: %s–%s", created.ID, req.EmployeeID, req.StartDate, req.EndDate)
+}
+
+// reviewLeaveRequest переводит заявку из pending в одобренную или отклоненную
+func reviewLeaveRequest(w http.ResponseWriter, r *http.Request, status string) (models.LeaveRequest, bool) {
+	var l models.LeaveRequest
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return l, false
+	}
+
+	log.Printf("POST /api/leave-requests/%d - рассмотрение заявки (%s)", id, status)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return l, false
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			return l, false
+		}
+	}
+
+	l, err = scanLeaveRequest(database.DB.QueryRow(`
+		WITH lr AS (
+			UPDATE leave_requests
+			SET status = $1, review_comment = NULLIF($2, ''), reviewed_by = $3, reviewed_at = NOW()
+			WHERE id = $4 AND status = 'pending'
+			RETURNING *
+		)
+		SELECT `+leaveColumns+`
+		FROM lr
+		JOIN employees e ON lr.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+	`, status, req.Comment, user.ID, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Заявка не найдена или уже рассмотрена", http.StatusNotFound)
+		return l, false
+	}
+	if err != nil {
+		log.Printf("Ошибка рассмотрения заявки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return l, false
+	}
+	return l, true
+}
+
+// ApproveLeaveRequest одобряет заявку и возвращает тренировки сотрудника в дни отсутствия
+// с вариантами замены
+func ApproveLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	l, ok := reviewLeaveRequest(w, r, "approved")
+	if !ok {
+		return
+	}
+
+	affected, err := affectedTrainings(database.DB, l)
+	if err != nil {
+		log.Printf("Ошибка подбора замен: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		LeaveRequest      models.LeaveRequest       `json:"leave_request"`
+		AffectedTrainings []models.AffectedTraining `json:"affected_trainings"`
+	}{l, affected})
+	log.Printf("Заявка на отсутствие %d одобрена, затронуто тренировок: %d", l.ID, len(affected))
+}
+
+// RejectLeaveRequest отклоняет заявку на отсутствие
+func RejectLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	l, ok := reviewLeaveRequest(w, r, "rejected")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l)
+	log.Printf("Заявка на отсутствие %d отклонена", l.ID)
+}
+
+// CancelLeaveRequest отзывает заявку, пока она не рассмотрена; администратор может отменить и одобренную
+func CancelLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/leave-requests/%d/cancel - отзыв заявки на отсутствие", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE leave_requests lr SET status = 'cancelled'
+		FROM employees e
+		WHERE lr.id = $1 AND lr.employee_id = e.id
+		AND (lr.status = 'pending' OR $3 AND lr.status = 'approved')
+		AND ($3 OR e.user_id = $2)
+	`, id, user.ID, user.Role == "admin")
+	if err != nil {
+		log.Printf("Ошибка отзыва заявки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Заявка не найдена или уже рассмотрена", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLeaveAffectedTrainings возвращает тренировки отсутствующего сотрудника с вариантами замены
+func GetLeaveAffectedTrainings(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/leave-requests/%d/trainings - тренировки на время отсутствия", id)
+
+	l, err := getLeaveRequest(database.DB, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Заявка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	affected, err := affectedTrainings(database.DB, l)
+	if err != nil {
+		log.Printf("Ошибка подбора замен: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(affected)
+}
+
+// substituteLockSpace — пространство ключей advisory-блокировок заменяющих тренеров
+const substituteLockSpace = 45
+
+// lockSubstitutes берет транзакционные advisory-блокировки на тренеров по возрастанию ID
+func lockSubstitutes(tx *sql.Tx, userIDs []int) error {
+	ids := append([]int(nil), userIDs...)
+	sort.Ints(ids)
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1::int, $2::int)`, substituteLockSpace, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReassignLeaveTrainings передает тренировки отсутствующего сотрудника заменяющим тренерам
+// и уведомляет записанных участников. Все назначения применяются вместе или не применяются вовсе.
+func ReassignLeaveTrainings(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/leave-requests/%d/reassign - замена тренера", id)
+
+	var req struct {
+		Assignments []struct {
+			TrainingID int `json:"training_id"`
+			TrainerID  int `json:"trainer_id"`
+		} `json:"assignments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Assignments) == 0 {
+		http.Error(w, "Укажите assignments: training_id и trainer_id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	l, err := getLeaveRequest(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Заявка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if l.Status != "approved" {
+		http.Error(w, "Заменить тренера можно только по одобренной заявке", http.StatusConflict)
+		return
+	}
+
+	// Блокируем тренировки отсутствующего сотрудника, чтобы их не переназначили параллельно
+	if _, err := tx.Exec(`
+		SELECT 1 FROM trainings t JOIN employees e ON e.user_id = t.trainer_id
+		WHERE e.id = $1 AND t.start_time >= $2 AND t.start_time < $3::date + 1
+		FOR UPDATE OF t
+	`, l.EmployeeID, l.StartDate, l.EndDate); err != nil {
+		log.Printf("Ошибка блокировки тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Замены по разным заявкам могут выбрать одного и того же тренера, поэтому до проверки
+	// занятости берется блокировка на каждого заменяющего до конца транзакции. Блокировки
+	// берутся по возрастанию ID, чтобы параллельные замены не ждали друг друга по кругу.
+	substituteIDs := make([]int, 0, len(req.Assignments))
+	for _, a := range req.Assignments {
+		substituteIDs = append(substituteIDs, a.TrainerID)
+	}
+	if err := lockSubstitutes(tx, substituteIDs); err != nil {
+		log.Printf("Ошибка блокировки заменяющих тренеров: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	trainings, err := leaveTrainings(tx, l)
+	if err != nil {
+		log.Printf("Ошибка загрузки тренировок: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[int]leaveTraining, len(trainings))
+	for _, t := range trainings {
+		byID[t.TrainingID] = t
+	}
+
+	reassigned, notified := 0, 0
+	for _, a := range req.Assignments {
+		t, ok := byID[a.TrainingID]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Тренировка %d не относится к отсутствию сотрудника", a.TrainingID), http.StatusBadRequest)
+			return
+		}
+		locked, err := payrollLocked(tx, t.StartTime)
+		if err != nil {
+			log.Printf("Ошибка проверки закрытого периода: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if locked {
+			http.Error(w, fmt.Sprintf("Тренировка %d: период закрыт для расчета зарплаты", a.TrainingID), http.StatusConflict)
+			return
+		}
+
+		// Кандидаты проверяются после предыдущих назначений, поэтому одного тренера
+		// нельзя поставить на две пересекающиеся тренировки
+		candidates, err := substituteCandidates(tx, t)
+		if err != nil {
+			log.Printf("Ошибка подбора замен: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var substitute *models.SubstituteUser
+		for i := range candidates {
+			if candidates[i].UserID == a.TrainerID {
+				substitute = &candidates[i]
+			}
+		}
+		if substitute == nil {
+			http.Error(w, fmt.Sprintf("Тренер %d не может провести тренировку %d: нет сертификата или занят", a.TrainerID, a.TrainingID), http.StatusConflict)
+			return
+		}
+
+		if _, err := tx.Exec(`UPDATE trainings SET trainer_id = $1 WHERE id = $2`, substitute.UserID, t.TrainingID); err != nil {
+			log.Printf("Ошибка замены тренера: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		n, err := notifyTrainingParticipants(tx, t.TrainingID, "trainer_changed", "Замена тренера",
+			fmt.Sprintf("Тренировку «%s» %s проведет %s вместо %s",
+				t.Title, t.StartTime.Format("02.01.2006 15:04"), substitute.Name, l.EmployeeName))
+		if err != nil {
+			log.Printf("Ошибка отправки уведомлений: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reassigned++
+		notified += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"reassigned": reassigned, "notified": notified})
+	log.Printf("По заявке %d заменен тренер на %d тренировках, уведомлено участников: %d", id, reassigned, notified)
+}
@@ -0,0 +1,625 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Баллы начисляются за посещенные тренировки, отметки входа (не чаще раза в день), оплаченные
+// покупки и приглашенных друзей. Начисления сгорают через LOYALTY_POINTS_TTL_DAYS дней;
+// списания и сгорание расходуют самые старые начисления первыми.
+
+// loyaltyEntry — запись для журнала баллов; Points всегда положительное, знак задает операция
+type loyaltyEntry struct {
+	ClientID         int
+	Reason           string
+	Points           int
+	Description      string
+	TrainingID       *int
+	VisitID          *int
+	PaymentID        *int
+	SubscriptionID   *int
+	ReferralClientID *int
+	CreatedBy        *int
+	// Бесплатные дни, добавленные к абонементу пригласившего (только для referral)
+	ExtendedSubscriptionID *int
+	ExtendedDays           int
+}
+
+// awardPoints начисляет баллы. Повторное начисление за то же событие (тренировку, платеж,
+// приглашенного клиента, отметку входа в тот же день) молча пропускается.
+func awardPoints(q queryer, e loyaltyEntry) error {
+	if e.Points <= 0 {
+		return nil
+	}
+	_, err := q.Exec(`
+		INSERT INTO loyalty_transactions (client_id, reason, points, remaining, expires_at, training_id, visit_id,
+		                                  payment_id, subscription_id, referral_client_id, description, created_by,
+		                                  extended_subscription_id, extended_days)
+		VALUES ($1, $2, $3, $3, CASE WHEN $4 > 0 THEN CURRENT_DATE + $4::int END, $5, $6, $7, $8, $9, NULLIF($10, ''), $11,
+		        $12, $13)
+		ON CONFLICT DO NOTHING
+	`, e.ClientID, e.Reason, e.Points, getEnvInt("LOYALTY_POINTS_TTL_DAYS", 365), e.TrainingID, e.VisitID,
+		e.PaymentID, e.SubscriptionID, e.ReferralClientID, e.Description, e.CreatedBy,
+		e.ExtendedSubscriptionID, e.ExtendedDays)
+	return err
+}
+
+// pointLot — неизрасходованный остаток начисления
+type pointLot struct {
+	ID        int
+	Remaining int
+}
+
+// allocatePoints распределяет списание points по начислениям в порядке lots и возвращает,
+// сколько взять из каждого. Если баллов не хватает, расходуется все доступное.
+func allocatePoints(lots []pointLot, points int) []pointLot {
+	var used []pointLot
+	for _, l := range lots {
+		if points <= 0 {
+			break
+		}
+		take := l.Remaining
+		if take > points {
+			take = points
+		}
+		if take <= 0 {
+			continue
+		}
+		used = append(used, pointLot{ID: l.ID, Remaining: take})
+		points -= take
+	}
+	return used
+}
+
+// lockPointLots блокирует действующие остатки начислений клиента до конца транзакции.
+// Начисления идут в порядке сгорания; начисление first (если задано) — первым.
+func lockPointLots(tx *sql.Tx, clientID, first int) ([]pointLot, error) {
+	rows, err := tx.Query(`
+		SELECT id, remaining FROM loyalty_transactions
+		WHERE client_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at >= CURRENT_DATE)
+		ORDER BY id = $2 DESC, expires_at NULLS LAST, id
+		FOR UPDATE
+	`, clientID, first)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lots []pointLot
+	for rows.Next() {
+		var l pointLot
+		if err := rows.Scan(&l.ID, &l.Remaining); err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
+
+// takePoints уменьшает остатки начислений и записывает списание в журнал
+func takePoints(tx *sql.Tx, e loyaltyEntry, used []pointLot) error {
+	total := 0
+	for _, l := range used {
+		if _, err := tx.Exec(`UPDATE loyalty_transactions SET remaining = remaining - $1 WHERE id = $2`, l.Remaining, l.ID); err != nil {
+			return err
+		}
+		total += l.Remaining
+	}
+	if total == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO loyalty_transactions (client_id, reason, points, subscription_id, description, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+	`, e.ClientID, e.Reason, -total, e.SubscriptionID, e.Description, e.CreatedBy)
+	return err
+}
+
+// spendPoints списывает баллы, начиная с начислений, которые сгорят раньше.
+// Вызывается внутри транзакции: остатки начислений блокируются до ее завершения.
+func spendPoints(tx *sql.Tx, e loyaltyEntry) error {
+	lots, err := lockPointLots(tx, e.ClientID, 0)
+	if err != nil {
+		return err
+	}
+	available := 0
+	for _, l := range lots {
+		available += l.Remaining
+	}
+	if available < e.Points {
+		return validationError(fmt.Sprintf("Недостаточно баллов: доступно %d", available))
+	}
+	return takePoints(tx, e, allocatePoints(lots, e.Points))
+}
+
+// revokePoints отменяет начисление lotID на e.Points баллов: сначала забирается его остаток,
+// затем, если часть уже потрачена, — другие баллы клиента. Сверх остатка на счете ничего
+// не списывается, баланс не уходит в минус.
+func revokePoints(tx *sql.Tx, e loyaltyEntry, lotID int) error {
+	lots, err := lockPointLots(tx, e.ClientID, lotID)
+	if err != nil {
+		return err
+	}
+	return takePoints(tx, e, allocatePoints(lots, e.Points))
+}
+
+// reverseSubscriptionLoyalty откатывает бонусы по отмененному абонементу:
+//   - баллы за покупку снимаются в той доле, в какой оплата возвращена клиенту;
+//   - списанные в оплату баллы возвращаются в доле неиспользованных дней, как и деньги
+//     (целиком, если за абонемент ничего не заплачено);
+//   - бонус пригласившему за эту покупку (баллы и бесплатные дни) отменяется целиком.
+//
+// Вызывается в транзакции отмены абонемента.
+func reverseSubscriptionLoyalty(tx *sql.Tx, s models.Subscription, refund refundQuote) error {
+	type earned struct {
+		id, clientID, points     int
+		reason                   string
+		extendedID, extendedDays int
+	}
+	rows, err := tx.Query(`
+		SELECT id, client_id, points, reason, COALESCE(extended_subscription_id, 0), extended_days
+		FROM loyalty_transactions
+		WHERE subscription_id = $1 AND reason IN ('purchase', 'referral', 'redemption')
+		ORDER BY id
+	`, s.ID)
+	if err != nil {
+		return err
+	}
+	var entries []earned
+	for rows.Next() {
+		var e earned
+		if err := rows.Scan(&e.id, &e.clientID, &e.points, &e.reason, &e.extendedID, &e.extendedDays); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		entry := loyaltyEntry{ClientID: e.clientID, Reason: "reversal", SubscriptionID: &s.ID}
+		switch e.reason {
+		case "purchase":
+			if !refund.Paid.IsPositive() {
+				continue
+			}
+			entry.Points = int(int64(e.points) * refund.Refund.Amount / refund.Paid.Amount)
+			entry.Description = fmt.Sprintf("Возврат оплаты абонемента №%d: отмена баллов за покупку", s.ID)
+			err = revokePoints(tx, entry, e.id)
+		case "referral":
+			entry.Points = e.points
+			entry.Description = fmt.Sprintf("Отмена абонемента №%d приглашенного клиента", s.ID)
+			err = revokePoints(tx, entry, e.id)
+			if err == nil && e.extendedID != 0 && e.extendedDays > 0 {
+				_, err = tx.Exec(`
+					UPDATE subscriptions SET end_date = GREATEST(end_date - $1::int, start_date)
+					WHERE id = $2 AND status <> 'cancelled'
+				`, e.extendedDays, e.extendedID)
+			}
+		case "redemption":
+			entry.Points = -e.points
+			if refund.Paid.IsPositive() && refund.TotalDays > 0 {
+				entry.Points = entry.Points * (refund.TotalDays - refund.UsedDays) / refund.TotalDays
+			}
+			entry.Description = fmt.Sprintf("Возврат баллов, списанных в оплату абонемента №%d", s.ID)
+			err = awardPoints(tx, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loyaltyBalance возвращает доступный остаток баллов клиента
+func loyaltyBalance(q queryer, clientID int) (int, error) {
+	var balance int
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(remaining), 0) FROM loyalty_transactions
+		WHERE client_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at >= CURRENT_DATE)
+	`, clientID).Scan(&balance)
+	return balance, err
+}
+
+// pointsDiscount рассчитывает оплату баллами: не больше запрошенного, остатка на счете
+// и LOYALTY_MAX_REDEEM_PERCENT процентов цены. Один балл стоит LOYALTY_POINT_VALUE копеек.
+func pointsDiscount(q queryer, clientID, requested int, price models.Money) (int, models.Money, error) {
+	if requested < 0 {
+		return 0, models.Money{}, validationError("redeem_points не может быть отрицательным")
+	}
+	if requested == 0 {
+		return 0, models.Money{}, nil
+	}
+	balance, err := loyaltyBalance(q, clientID)
+	if err != nil {
+		return 0, models.Money{}, err
+	}
+	if requested > balance {
+		return 0, models.Money{}, validationError(fmt.Sprintf("Недостаточно баллов: доступно %d", balance))
+	}
+
+	pointValue := models.NewMoney(int64(getEnvInt("LOYALTY_POINT_VALUE", 100)))
+	if !pointValue.IsPositive() {
+		return 0, models.Money{}, validationError("Оплата баллами отключена")
+	}
+	maxDiscount := price.Percent(models.WholePercent(getEnvInt("LOYALTY_MAX_REDEEM_PERCENT", 50)))
+	points := int64(requested)
+	if limit := maxDiscount.Amount / pointValue.Amount; points > limit {
+		points = limit
+	}
+	return int(points), pointValue.Mul(points), nil
+}
+
+// awardPurchasePoints начисляет LOYALTY_PURCHASE_PERCENT процентов от оплаченной суммы (в баллах за рубль)
+func awardPurchasePoints(q queryer, p models.Payment) error {
+	if p.Kind != "payment" || p.Status != "paid" || p.ClientID == nil {
+		return nil
+	}
+	points := p.Amount.Amount * int64(getEnvInt("LOYALTY_PURCHASE_PERCENT", 5)) / 10000
+	return awardPoints(q, loyaltyEntry{
+		ClientID:       *p.ClientID,
+		Reason:         "purchase",
+		Points:         int(points),
+		PaymentID:      &p.ID,
+		SubscriptionID: p.SubscriptionID,
+		Description:    p.Description,
+	})
+}
+
+// linkReferral привязывает нового клиента к пригласившему по реферальному коду. Код принимается
+// только при первой покупке клиента и вызывается до записи абонемента; бонус пригласившему
+// начисляет awardReferral, когда эта покупка оплачена.
+func linkReferral(tx *sql.Tx, clientID int, code string) error {
+	var referrerID int
+	err := tx.QueryRow(`
+		SELECT id FROM clients WHERE referral_code = $1 AND deleted_at IS NULL
+	`, strings.ToUpper(strings.TrimSpace(code))).Scan(&referrerID)
+	if err == sql.ErrNoRows {
+		return validationError("Реферальный код не найден")
+	}
+	if err != nil {
+		return err
+	}
+	if referrerID == clientID {
+		return validationError("Нельзя использовать собственный реферальный код")
+	}
+
+	var firstPurchase bool
+	err = tx.QueryRow(`
+		SELECT c.referred_by IS NULL AND NOT EXISTS(SELECT 1 FROM subscriptions s WHERE s.client_id = c.id)
+		FROM clients c WHERE c.id = $1
+		FOR UPDATE
+	`, clientID).Scan(&firstPurchase)
+	if err != nil {
+		return err
+	}
+	if !firstPurchase {
+		return validationError("Реферальный код действует только при первой покупке")
+	}
+
+	_, err = tx.Exec(`UPDATE clients SET referred_by = $1 WHERE id = $2`, referrerID, clientID)
+	return err
+}
+
+// awardReferral вознаграждает пригласившего баллами и бесплатными днями действующего
+// абонемента, когда оплачена первая покупка приглашенного клиента. Вызывается вместе
+// с awardPurchasePoints в той же транзакции, что и отметка оплаты.
+func awardReferral(q queryer, p models.Payment) error {
+	if p.Kind != "payment" || p.Status != "paid" || p.ClientID == nil || p.SubscriptionID == nil {
+		return nil
+	}
+	var referrerID int
+	err := q.QueryRow(`
+		SELECT c.referred_by FROM clients c
+		WHERE c.id = $1 AND c.referred_by IS NOT NULL
+		  AND $2 = (SELECT MIN(s.id) FROM subscriptions s WHERE s.client_id = c.id)
+		  AND NOT EXISTS(
+			SELECT 1 FROM loyalty_transactions lt WHERE lt.referral_client_id = c.id AND lt.reason = 'referral'
+		  )
+	`, *p.ClientID, *p.SubscriptionID).Scan(&referrerID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := loyaltyEntry{
+		ClientID:         referrerID,
+		Reason:           "referral",
+		Points:           getEnvInt("LOYALTY_REFERRAL_POINTS", 500),
+		SubscriptionID:   p.SubscriptionID,
+		ReferralClientID: p.ClientID,
+		Description:      "Приглашение нового клиента",
+	}
+	if days := getEnvInt("LOYALTY_REFERRAL_DAYS", 7); days > 0 {
+		var extendedID int
+		err := q.QueryRow(`
+			UPDATE subscriptions SET end_date = end_date + $1::int
+			WHERE id = (
+				SELECT id FROM subscriptions
+				WHERE client_id = $2 AND status = 'active' AND deleted_at IS NULL AND end_date >= CURRENT_DATE
+				ORDER BY end_date DESC
+				LIMIT 1
+			)
+			RETURNING id
+		`, days, referrerID).Scan(&extendedID)
+		switch {
+		case err == nil:
+			entry.ExtendedSubscriptionID = &extendedID
+			entry.ExtendedDays = days
+			entry.Description += fmt.Sprintf(", абонемент продлен на %d дн.", days)
+		case err != sql.ErrNoRows:
+			return err
+		}
+	}
+
+	return awardPoints(q, entry)
+}
+
+// referralAlphabet не содержит похожих символов (0/O, 1/I), чтобы код было удобно диктовать
+const referralAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func generateReferralCode() (string, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(referralAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = referralAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// ensureReferralCode возвращает реферальный код клиента, создавая его при первом обращении
+func ensureReferralCode(clientID int) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		var code sql.NullString
+		err := database.DB.QueryRow(`SELECT referral_code FROM clients WHERE id = $1`, clientID).Scan(&code)
+		if err != nil || code.Valid {
+			return code.String, err
+		}
+
+		candidate, err := generateReferralCode()
+		if err != nil {
+			return "", err
+		}
+		_, err = database.DB.Exec(`
+			UPDATE clients SET referral_code = $1 WHERE id = $2 AND referral_code IS NULL
+		`, candidate, clientID)
+		if err != nil && !strings.Contains(err.Error(), "duplicate key") {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("не удалось создать реферальный код")
+}
+
+// StartLoyaltyExpiry запускает периодическое сгорание просроченных баллов
+func StartLoyaltyExpiry(interval time.Duration) {
+	go func() {
+		expireLoyaltyPoints()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			expireLoyaltyPoints()
+		}
+	}()
+	log.Printf("Сгорание бонусных баллов запущено (интервал %s)", interval)
+}
+
+// expireLoyaltyPoints обнуляет остатки просроченных начислений и записывает их сгорание в журнал
+func expireLoyaltyPoints() {
+	result, err := database.DB.Exec(`
+		WITH expired AS (
+			UPDATE loyalty_transactions lt SET remaining = 0
+			FROM (
+				SELECT id, remaining FROM loyalty_transactions
+				WHERE remaining > 0 AND expires_at < CURRENT_DATE
+				FOR UPDATE
+			) old
+			WHERE lt.id = old.id
+			RETURNING lt.id, lt.client_id, old.remaining
+		)
+		INSERT INTO loyalty_transactions (client_id, reason, points, description)
+		SELECT client_id, 'expiry', -remaining, 'Сгорание начисления #' || id FROM expired
+	`)
+	if err != nil {
+		log.Printf("Ошибка сгорания баллов: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Сгорело начислений баллов: %d", n)
+	}
+}
+
+const loyaltyTransactionColumns = `
+	id, client_id, reason, points, remaining, expires_at, training_id, subscription_id,
+	referral_client_id, COALESCE(description, ''), created_at
+`
+
+func scanLoyaltyTransaction(row interface{ Scan(...interface{}) error }) (models.LoyaltyTransaction, error) {
+	var t models.LoyaltyTransaction
+	var expiresAt sql.NullTime
+	var trainingID, subscriptionID, referralClientID sql.NullInt64
+	err := row.Scan(&t.ID, &t.ClientID, &t.Reason, &t.Points, &t.Remaining, &expiresAt, &trainingID,
+		&subscriptionID, &referralClientID, &t.Description, &t.CreatedAt)
+	if err != nil {
+		return t, err
+	}
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	if trainingID.Valid {
+		id := int(trainingID.Int64)
+		t.TrainingID = &id
+	}
+	if subscriptionID.Valid {
+		id := int(subscriptionID.Int64)
+		t.SubscriptionID = &id
+	}
+	if referralClientID.Valid {
+		id := int(referralClientID.Int64)
+		t.ReferralClientID = &id
+	}
+	return t, nil
+}
+
+// GetLoyalty возвращает бонусный счет клиента: остаток, ближайшее сгорание, реферальный код и журнал
+func GetLoyalty(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/loyalty - получение бонусного счета", clientID)
+
+	account := models.LoyaltyAccount{ClientID: clientID, Transactions: []models.LoyaltyTransaction{}}
+	var referredBy sql.NullInt64
+	err := database.DB.QueryRow(`
+		SELECT c.referred_by, (SELECT COUNT(*) FROM loyalty_transactions lt WHERE lt.client_id = c.id AND lt.reason = 'referral')
+		FROM clients c WHERE c.id = $1 AND c.deleted_at IS NULL
+	`, clientID).Scan(&referredBy, &account.Referrals)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Клиент не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if referredBy.Valid {
+		id := int(referredBy.Int64)
+		account.ReferredBy = &id
+	}
+
+	var nextExpiry sql.NullTime
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(SUM(remaining), 0),
+		       COALESCE(SUM(remaining) FILTER (WHERE expires_at < CURRENT_DATE + 30), 0),
+		       MIN(expires_at)
+		FROM loyalty_transactions
+		WHERE client_id = $1 AND remaining > 0 AND (expires_at IS NULL OR expires_at >= CURRENT_DATE)
+	`, clientID).Scan(&account.Balance, &account.ExpiringPoints, &nextExpiry)
+	if err != nil {
+		log.Printf("Ошибка расчета баланса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nextExpiry.Valid {
+		account.NextExpiry = &nextExpiry.Time
+	}
+
+	if account.ReferralCode, err = ensureReferralCode(clientID); err != nil {
+		log.Printf("Ошибка создания реферального кода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+loyaltyTransactionColumns+` FROM loyalty_transactions
+		WHERE client_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, clientID)
+	if err != nil {
+		log.Printf("Ошибка запроса журнала баллов: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanLoyaltyTransaction(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		account.Transactions = append(account.Transactions, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// AdjustLoyalty вручную начисляет (points > 0) или списывает (points < 0) баллы с обязательным комментарием
+func AdjustLoyalty(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("POST /api/clients/%d/loyalty/adjust - корректировка баллов", clientID)
+
+	var req struct {
+		Points      int    `json:"points"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Points == 0 {
+		http.Error(w, "points не может быть равен 0", http.StatusBadRequest)
+		return
+	}
+	if req.Description == "" {
+		http.Error(w, "Укажите причину корректировки", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	entry := loyaltyEntry{ClientID: clientID, Reason: "adjustment", Description: req.Description, CreatedBy: &user.ID}
+	if req.Points > 0 {
+		entry.Points = req.Points
+		err = awardPoints(tx, entry)
+	} else {
+		entry.Points = -req.Points
+		err = spendPoints(tx, entry)
+	}
+	if err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка корректировки баллов: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := loyaltyBalance(tx, clientID)
+	if err != nil {
+		log.Printf("Ошибка расчета баланса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"balance": balance})
+	log.Printf("Баллы клиента %d скорректированы на %d пользователем %d", clientID, req.Points, user.ID)
+}
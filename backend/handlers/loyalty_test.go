@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocatePoints(t *testing.T) {
+	lots := []pointLot{{ID: 1, Remaining: 100}, {ID: 2, Remaining: 0}, {ID: 3, Remaining: 50}, {ID: 4, Remaining: 200}}
+	tests := []struct {
+		name   string
+		lots   []pointLot
+		points int
+		want   []pointLot
+	}{
+		{"из первого начисления", lots, 30, []pointLot{{ID: 1, Remaining: 30}}},
+		{"ровно первое начисление", lots, 100, []pointLot{{ID: 1, Remaining: 100}}},
+		{"пустой остаток пропускается", lots, 120, []pointLot{{ID: 1, Remaining: 100}, {ID: 3, Remaining: 20}}},
+		{"все начисления", lots, 350, []pointLot{{ID: 1, Remaining: 100}, {ID: 3, Remaining: 50}, {ID: 4, Remaining: 200}}},
+		{"больше доступного", lots, 500, []pointLot{{ID: 1, Remaining: 100}, {ID: 3, Remaining: 50}, {ID: 4, Remaining: 200}}},
+		{"ноль баллов", lots, 0, nil},
+		{"нет начислений", nil, 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allocatePoints(tt.lots, tt.points); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocatePoints(%d) = %v, ожидалось %v", tt.points, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// bodyMetrics — показатели замеров тела в порядке колонок client_measurements
+var bodyMetrics = []string{"weight_kg", "body_fat_percent", "chest_cm", "waist_cm", "hips_cm", "arm_cm", "thigh_cm"}
+
+// metricTrend — динамика показателя за выбранный период
+type metricTrend struct {
+	Metric        string   `json:"metric"`
+	Points        int      `json:"points"`
+	First         float64  `json:"first"`
+	Last          float64  `json:"last"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	PerWeek       float64  `json:"per_week"`  // наклон линейной регрессии
+	Direction     string   `json:"direction"` // up, down, flat
+}
+
+// measurementsResponse — временной ряд замеров клиента с трендами
+type measurementsResponse struct {
+	Measurements []models.Measurement `json:"measurements"`
+	Trends       []metricTrend        `json:"trends"`
+}
+
+// metricValue возвращает значение показателя из замера: колонку тела или контрольное упражнение
+func metricValue(m models.Measurement, metric string) *float64 {
+	switch metric {
+	case "weight_kg":
+		return m.WeightKg
+	case "body_fat_percent":
+		return m.BodyFatPercent
+	case "chest_cm":
+		return m.ChestCm
+	case "waist_cm":
+		return m.WaistCm
+	case "hips_cm":
+		return m.HipsCm
+	case "arm_cm":
+		return m.ArmCm
+	case "thigh_cm":
+		return m.ThighCm
+	}
+	if v, ok := m.Benchmarks[metric]; ok {
+		return &v
+	}
+	return nil
+}
+
+// calculateTrend считает изменение показателя и скорость изменения в неделю методом наименьших квадратов.
+// Замеры должны быть упорядочены по дате.
+func calculateTrend(metric string, measurements []models.Measurement) *metricTrend {
+	var xs, ys []float64
+	for _, m := range measurements {
+		if v := metricValue(m, metric); v != nil {
+			xs = append(xs, m.MeasuredAt.Sub(measurements[0].MeasuredAt).Hours()/24/7)
+			ys = append(ys, *v)
+		}
+	}
+	if len(ys) == 0 {
+		return nil
+	}
+
+	t := &metricTrend{Metric: metric, Points: len(ys), First: ys[0], Last: ys[len(ys)-1], Direction: "flat"}
+	t.Change = round2(t.Last - t.First)
+	if t.First != 0 {
+		percent := round2(t.Change / t.First * 100)
+		t.ChangePercent = &percent
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+	var num, den float64
+	for i := range xs {
+		num += (xs[i] - meanX) * (ys[i] - meanY)
+		den += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if den > 0 {
+		t.PerWeek = round2(num / den)
+	}
+	switch {
+	case t.PerWeek > 0:
+		t.Direction = "up"
+	case t.PerWeek < 0:
+		t.Direction = "down"
+	}
+	return t
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// authorizeClient разбирает ID клиента из пути и проверяет доступ к его данным.
+// Изменять данные могут только тренеры и администраторы.
+func authorizeClient(w http.ResponseWriter, r *http.Request, write bool) (int, models.User, bool) {
+	clientID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return 0, models.User{}, false
+	}
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return 0, user, false
+	}
+	if write && user.Role != "trainer" && user.Role != "admin" {
+		http.Error(w, "Доступ запрещен. Требуются права тренера или администратора", http.StatusForbidden)
+		return 0, user, false
+	}
+
+	allowed, err := canAccessClient(user, clientID)
+	if err != nil {
+		log.Printf("Ошибка проверки доступа к клиенту: %v", err)
+		http.Error(w, "Ошибка проверки доступа", http.StatusInternalServerError)
+		return 0, user, false
+	}
+	if !allowed {
+		http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		return 0, user, false
+	}
+	return clientID, user, true
+}
+
+const measurementColumns = `
+	id, client_id, measured_at, weight_kg, body_fat_percent, chest_cm, waist_cm, hips_cm, arm_cm, thigh_cm,
+	benchmarks, COALESCE(notes, ''), recorded_by, created_at
+`
+
+func scanMeasurement(row interface{ Scan(...interface{}) error }) (models.Measurement, error) {
+	var m models.Measurement
+	var benchmarks []byte
+	var recordedBy sql.NullInt64
+	err := row.Scan(&m.ID, &m.ClientID, &m.MeasuredAt, &m.WeightKg, &m.BodyFatPercent, &m.ChestCm,
+		&m.WaistCm, &m.HipsCm, &m.ArmCm, &m.ThighCm, &benchmarks, &m.Notes, &recordedBy, &m.CreatedAt)
+	if err != nil {
+		return m, err
+	}
+	m.Benchmarks = map[string]float64{}
+	if len(benchmarks) > 0 {
+		if err := json.Unmarshal(benchmarks, &m.Benchmarks); err != nil {
+			return m, err
+		}
+	}
+	if recordedBy.Valid {
+		id := int(recordedBy.Int64)
+		m.RecordedBy = &id
+	}
+	return m, nil
+}
+
+// loadMeasurements возвращает замеры клиента по дате, опционально за период [from, to]
+func loadMeasurements(clientID int, from, to string) ([]models.Measurement, error) {
+	query := `SELECT ` + measurementColumns + ` FROM client_measurements WHERE client_id = $1`
+	args := []interface{}{clientID}
+	if from != "" {
+		args = append(args, from)
+		query += " AND measured_at >= $" + strconv.Itoa(len(args))
+	}
+	if to != "" {
+		args = append(args, to)
+		query += " AND measured_at <= $" + strconv.Itoa(len(args))
+	}
+	query += " ORDER BY measured_at, id"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurements := make([]models.Measurement, 0)
+	for rows.Next() {
+		m, err := scanMeasurement(rows)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, m)
+	}
+	return measurements, rows.Err()
+}
+
+// GetMeasurements возвращает замеры клиента и тренды по каждому показателю.
+// Фильтры: from, to (YYYY-MM-DD) и metric (один показатель для трендов).
+func GetMeasurements(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/measurements - получение замеров клиента", clientID)
+
+	measurements, err := loadMeasurements(clientID, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metrics := []string{}
+	if metric := r.URL.Query().Get("metric"); metric != "" {
+		metrics = append(metrics, metric)
+	} else {
+		metrics = append(metrics, bodyMetrics...)
+		benchmarks := map[string]bool{}
+		for _, m := range measurements {
+			for name := range m.Benchmarks {
+				benchmarks[name] = true
+			}
+		}
+		names := make([]string, 0, len(benchmarks))
+		for name := range benchmarks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		metrics = append(metrics, names...)
+	}
+
+	resp := measurementsResponse{Measurements: measurements, Trends: []metricTrend{}}
+	for _, metric := range metrics {
+		if t := calculateTrend(metric, measurements); t != nil {
+			resp.Trends = append(resp.Trends, *t)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	log.Printf("Возвращено замеров: %d", len(measurements))
+}
+
+// CreateMeasurement добавляет замер клиента
+func CreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("POST /api/clients/%d/measurements - добавление замера", clientID)
+
+	var m models.Measurement
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.MeasuredAt.IsZero() {
+		m.MeasuredAt = currentDate()
+	}
+	if m.Benchmarks == nil {
+		m.Benchmarks = map[string]float64{}
+	}
+
+	hasValue := len(m.Benchmarks) > 0
+	for _, metric := range bodyMetrics {
+		if v := metricValue(m, metric); v != nil {
+			if *v <= 0 {
+				http.Error(w, "Значение "+metric+" должно быть больше нуля", http.StatusBadRequest)
+				return
+			}
+			hasValue = true
+		}
+	}
+	if !hasValue {
+		http.Error(w, "Укажите хотя бы один показатель", http.StatusBadRequest)
+		return
+	}
+	for name := range m.Benchmarks {
+		if strings.TrimSpace(name) == "" {
+			http.Error(w, "Пустое название контрольного упражнения", http.StatusBadRequest)
+			return
+		}
+	}
+	benchmarks, _ := json.Marshal(m.Benchmarks)
+
+	created, err := scanMeasurement(database.DB.QueryRow(`
+		INSERT INTO client_measurements (client_id, measured_at, weight_kg, body_fat_percent, chest_cm, waist_cm,
+		                                 hips_cm, arm_cm, thigh_cm, benchmarks, notes, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+		RETURNING `+measurementColumns,
+		clientID, m.MeasuredAt, m.WeightKg, m.BodyFatPercent, m.ChestCm, m.WaistCm,
+		m.HipsCm, m.ArmCm, m.ThighCm, benchmarks, m.Notes, user.ID))
+	if err != nil {
+		if strings.Contains(err.Error(), "violates check constraint") {
+			http.Error(w, "Недопустимое значение показателя", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка создания замера: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := updateGoalsAchieved(clientID); err != nil {
+		log.Printf("Ошибка обновления целей клиента %d: %v", clientID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Добавлен замер %d клиента %d", created.ID, clientID)
+}
+
+// DeleteMeasurement удаляет ошибочный замер
+func DeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+	measurementID, err := strconv.Atoi(mux.Vars(r)["measurementId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/clients/%d/measurements/%d - удаление замера", clientID, measurementID)
+
+	result, err := database.DB.Exec(`DELETE FROM client_measurements WHERE id = $1 AND client_id = $2`, measurementID, clientID)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Замер не найден", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Удален замер %d клиента %d", measurementID, clientID)
+}
+
+const goalColumns = `
+	id, client_id, metric, start_value, target_value, target_date, status, COALESCE(notes, ''),
+	created_by, created_at, achieved_at
+`
+
+func scanGoal(row interface{ Scan(...interface{}) error }) (models.Goal, error) {
+	var g models.Goal
+	var targetDate, achievedAt sql.NullTime
+	var createdBy sql.NullInt64
+	err := row.Scan(&g.ID, &g.ClientID, &g.Metric, &g.StartValue, &g.TargetValue, &targetDate, &g.Status,
+		&g.Notes, &createdBy, &g.CreatedAt, &achievedAt)
+	if err != nil {
+		return g, err
+	}
+	if targetDate.Valid {
+		g.TargetDate = &targetDate.Time
+	}
+	if achievedAt.Valid {
+		g.AchievedAt = &achievedAt.Time
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		g.CreatedBy = &id
+	}
+	return g, nil
+}
+
+// latestMetricValue возвращает последнее записанное значение показателя клиента
+func latestMetricValue(measurements []models.Measurement, metric string) *float64 {
+	for i := len(measurements) - 1; i >= 0; i-- {
+		if v := metricValue(measurements[i], metric); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// fillGoalProgress вычисляет текущее значение и процент выполнения цели
+func fillGoalProgress(g *models.Goal, measurements []models.Measurement) {
+	g.CurrentValue = latestMetricValue(measurements, g.Metric)
+	if g.CurrentValue == nil || g.StartValue == nil || *g.StartValue == g.TargetValue {
+		return
+	}
+	progress := (*g.CurrentValue - *g.StartValue) / (g.TargetValue - *g.StartValue) * 100
+	progress = round2(math.Max(0, math.Min(100, progress)))
+	g.Progress = &progress
+}
+
+// goalReached проверяет, достигнуто ли целевое значение (цель может быть на снижение или на рост)
+func goalReached(g models.Goal, current float64) bool {
+	if g.StartValue != nil && *g.StartValue > g.TargetValue {
+		return current <= g.TargetValue
+	}
+	return current >= g.TargetValue
+}
+
+// updateGoalsAchieved отмечает достигнутыми активные цели клиента после нового замера
+func updateGoalsAchieved(clientID int) error {
+	measurements, err := loadMeasurements(clientID, "", "")
+	if err != nil {
+		return err
+	}
+	rows, err := database.DB.Query(`SELECT `+goalColumns+` FROM client_goals WHERE client_id = $1 AND status = 'active'`, clientID)
+	if err != nil {
+		return err
+	}
+	var achieved []int
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if current := latestMetricValue(measurements, g.Metric); current != nil && goalReached(g, *current) {
+			achieved = append(achieved, g.ID)
+		}
+	}
+	rows.Close()
+
+	for _, id := range achieved {
+		if _, err := database.DB.Exec(`UPDATE client_goals SET status = 'achieved', achieved_at = NOW() WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetGoals возвращает цели клиента с текущим прогрессом
+func GetGoals(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/goals - получение целей клиента", clientID)
+
+	measurements, err := loadMeasurements(clientID, "", "")
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := database.DB.Query(`SELECT `+goalColumns+` FROM client_goals WHERE client_id = $1 ORDER BY created_at DESC`, clientID)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	goals := make([]models.Goal, 0)
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		fillGoalProgress(&g, measurements)
+		goals = append(goals, g)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(goals)
+	log.Printf("Возвращено целей: %d", len(goals))
+}
+
+// CreateGoal ставит клиенту цель. Начальное значение по умолчанию берется из последнего замера.
+func CreateGoal(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("POST /api/clients/%d/goals - создание цели", clientID)
+
+	var g models.Goal
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	g.Metric = strings.TrimSpace(g.Metric)
+	if g.Metric == "" {
+		http.Error(w, "metric обязателен", http.StatusBadRequest)
+		return
+	}
+	if g.TargetDate != nil && g.TargetDate.Before(currentDate()) {
+		http.Error(w, "target_date не может быть в прошлом", http.StatusBadRequest)
+		return
+	}
+
+	measurements, err := loadMeasurements(clientID, "", "")
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if g.StartValue == nil {
+		g.StartValue = latestMetricValue(measurements, g.Metric)
+	}
+
+	created, err := scanGoal(database.DB.QueryRow(`
+		INSERT INTO client_goals (client_id, metric, start_value, target_value, target_date, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING `+goalColumns,
+		clientID, g.Metric, g.StartValue, g.TargetValue, g.TargetDate, g.Notes, user.ID))
+	if err != nil {
+		log.Printf("Ошибка создания цели: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fillGoalProgress(&created, measurements)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Клиенту %d поставлена цель %s = %v", clientID, created.Metric, created.TargetValue)
+}
+
+// UpdateGoal изменяет цель клиента (целевое значение, срок, статус)
+func UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+	goalID, err := strconv.Atoi(mux.Vars(r)["goalId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/clients/%d/goals/%d - обновление цели", clientID, goalID)
+
+	var g models.Goal
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if g.Status == "" {
+		g.Status = "active"
+	}
+	if g.Status != "active" && g.Status != "achieved" && g.Status != "abandoned" {
+		http.Error(w, "status должен быть active, achieved или abandoned", http.StatusBadRequest)
+		return
+	}
+
+	var achievedAt interface{}
+	if g.Status == "achieved" {
+		achievedAt = time.Now()
+	}
+
+	updated, err := scanGoal(database.DB.QueryRow(`
+		UPDATE client_goals
+		SET target_value = $1, target_date = $2, status = $3, notes = NULLIF($4, ''),
+		    achieved_at = CASE WHEN $3 = 'achieved' THEN COALESCE(achieved_at, $5) ELSE NULL END
+		WHERE id = $6 AND client_id = $7
+		RETURNING `+goalColumns,
+		g.TargetValue, g.TargetDate, g.Status, g.Notes, achievedAt, goalID, clientID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Цель не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	measurements, err := loadMeasurements(clientID, "", "")
+	if err == nil {
+		fillGoalProgress(&updated, measurements)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлена цель %d клиента %d", goalID, clientID)
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// noteKinds — типы записей о клиенте: заметка и виды контактов
+var noteKinds = map[string]bool{"note": true, "call": true, "visit": true, "email": true, "message": true}
+
+const noteSelect = `
+	SELECT n.id, n.client_id, COALESCE(cu.name, ''), n.author_id, COALESCE(a.name, ''), n.kind, n.visibility,
+	       n.body, n.tags, n.follow_up_at, n.assigned_to, n.follow_up_done_at, n.created_at, n.updated_at
+	FROM client_notes n
+	JOIN clients c ON n.client_id = c.id
+	LEFT JOIN users cu ON c.user_id = cu.id
+	LEFT JOIN users a ON n.author_id = a.id
+`
+
+func scanNote(row interface{ Scan(...interface{}) error }) (models.ClientNote, error) {
+	var n models.ClientNote
+	var authorID, assignedTo sql.NullInt64
+	var followUpAt, doneAt sql.NullTime
+	err := row.Scan(&n.ID, &n.ClientID, &n.ClientName, &authorID, &n.AuthorName, &n.Kind, &n.Visibility,
+		&n.Body, pq.Array(&n.Tags), &followUpAt, &assignedTo, &doneAt, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return n, err
+	}
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		n.AuthorID = &id
+	}
+	if assignedTo.Valid {
+		id := int(assignedTo.Int64)
+		n.AssignedTo = &id
+	}
+	if followUpAt.Valid {
+		n.FollowUpAt = &followUpAt.Time
+	}
+	if doneAt.Valid {
+		n.FollowUpDoneAt = &doneAt.Time
+	}
+	return n, nil
+}
+
+// visibleNotesCondition ограничивает заметки тем, что видит сотрудник:
+// администратор — все, тренер — открытые тренерам, свои и назначенные ему
+func visibleNotesCondition(user models.User, arg func(interface{}) string) string {
+	if user.Role == "admin" {
+		return ""
+	}
+	id := arg(user.ID)
+	return " AND (n.visibility = 'trainers' OR n.author_id = " + id + " OR n.assigned_to = " + id + ")"
+}
+
+// normalizeTags приводит теги к нижнему регистру и убирает пустые и повторяющиеся
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// loadClientNotes возвращает заметки клиента, видимые сотруднику, новые первыми.
+// Фильтры: kind, tag, follow_up=pending — только с невыполненным напоминанием.
+func loadClientNotes(clientID int, user models.User, filters map[string]string) ([]models.ClientNote, error) {
+	args := []interface{}{clientID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	query := noteSelect + ` WHERE n.client_id = $1` + visibleNotesCondition(user, arg)
+	if kind := filters["kind"]; kind != "" {
+		query += " AND n.kind = " + arg(kind)
+	}
+	if tag := strings.ToLower(strings.TrimSpace(filters["tag"])); tag != "" {
+		query += " AND " + arg(tag) + " = ANY(n.tags)"
+	}
+	if filters["follow_up"] == "pending" {
+		query += " AND n.follow_up_at IS NOT NULL AND n.follow_up_done_at IS NULL"
+	}
+	query += " ORDER BY n.created_at DESC, n.id DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make([]models.ClientNote, 0)
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// noteRequest — создание или изменение заметки
+type noteRequest struct {
+	Kind         string     `json:"kind"`
+	Visibility   string     `json:"visibility"`
+	Body         string     `json:"body"`
+	Tags         []string   `json:"tags"`
+	FollowUpAt   *time.Time `json:"follow_up_at"`
+	AssignedTo   *int       `json:"assigned_to"`
+	FollowUpDone *bool      `json:"follow_up_done"` // напоминание выполнено; не передано — без изменений
+}
+
+// validate проверяет заметку и подставляет значения по умолчанию
+func (req *noteRequest) validate() error {
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		return validationError("Текст заметки обязателен")
+	}
+	if req.Kind == "" {
+		req.Kind = "note"
+	}
+	if !noteKinds[req.Kind] {
+		return validationError("kind должен быть note, call, visit, email или message")
+	}
+	if req.Visibility == "" {
+		req.Visibility = "staff"
+	}
+	if req.Visibility != "staff" && req.Visibility != "trainers" {
+		return validationError("visibility должен быть staff или trainers")
+	}
+	req.Tags = normalizeTags(req.Tags)
+	if req.AssignedTo != nil {
+		if req.FollowUpAt == nil {
+			return validationError("Исполнителя можно назначить только для напоминания")
+		}
+		var role string
+		err := database.DB.QueryRow(`SELECT role FROM users WHERE id = $1 AND deleted_at IS NULL`, *req.AssignedTo).Scan(&role)
+		if err == sql.ErrNoRows || (err == nil && role != "trainer" && role != "admin") {
+			return validationError("Напоминание можно назначить только сотруднику")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetClientNotes возвращает заметки и историю контактов с клиентом
+func GetClientNotes(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/notes - заметки о клиенте", clientID)
+
+	query := r.URL.Query()
+	notes, err := loadClientNotes(clientID, user, map[string]string{
+		"kind":      query.Get("kind"),
+		"tag":       query.Get("tag"),
+		"follow_up": query.Get("follow_up"),
+	})
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+	log.Printf("Возвращено заметок: %d", len(notes))
+}
+
+// CreateClientNote добавляет заметку или запись о контакте с клиентом
+func CreateClientNote(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("POST /api/clients/%d/notes - добавление заметки", clientID)
+
+	var req noteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка проверки заметки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.FollowUpAt != nil && req.AssignedTo == nil {
+		req.AssignedTo = &user.ID
+	}
+
+	var id int
+	err := database.DB.QueryRow(`
+		INSERT INTO client_notes (client_id, author_id, kind, visibility, body, tags, follow_up_at, assigned_to)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, clientID, user.ID, req.Kind, req.Visibility, req.Body, pq.Array(req.Tags), req.FollowUpAt, req.AssignedTo).Scan(&id)
+	if err != nil {
+		log.Printf("Ошибка создания заметки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanNote(database.DB.QueryRow(noteSelect+` WHERE n.id = $1`, id))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Добавлена заметка %d (%s) о клиенте %d", id, created.Kind, clientID)
+}
+
+// authorizeNote проверяет, что заметка принадлежит клиенту и изменять ее может текущий сотрудник:
+// автор или администратор. С allowAssignee допускается и исполнитель напоминания —
+// тогда assignee = true, и ему разрешено только отметить выполнение.
+func authorizeNote(w http.ResponseWriter, r *http.Request, allowAssignee bool) (clientID, noteID int, assignee, ok bool) {
+	clientID, user, ok := authorizeClient(w, r, true)
+	if !ok {
+		return 0, 0, false, false
+	}
+	noteID, err := strconv.Atoi(mux.Vars(r)["noteId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return 0, 0, false, false
+	}
+
+	var authorID, assignedTo sql.NullInt64
+	err = database.DB.QueryRow(`SELECT author_id, assigned_to FROM client_notes WHERE id = $1 AND client_id = $2`,
+		noteID, clientID).Scan(&authorID, &assignedTo)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Заметка не найдена", http.StatusNotFound)
+		return 0, 0, false, false
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, 0, false, false
+	}
+	if user.Role == "admin" || (authorID.Valid && int(authorID.Int64) == user.ID) {
+		return clientID, noteID, false, true
+	}
+	if allowAssignee && assignedTo.Valid && int(assignedTo.Int64) == user.ID {
+		return clientID, noteID, true, true
+	}
+	http.Error(w, "Изменять заметку может только автор или администратор", http.StatusForbidden)
+	return 0, 0, false, false
+}
+
+// UpdateClientNote изменяет заметку; follow_up_done=true отмечает напоминание выполненным,
+// false — снимает отметку, без поля отметка не меняется. Исполнитель напоминания, не являющийся
+// автором, может менять только follow_up_done.
+func UpdateClientNote(w http.ResponseWriter, r *http.Request) {
+	clientID, noteID, assignee, ok := authorizeNote(w, r, true)
+	if !ok {
+		return
+	}
+
+	log.Printf("PUT /api/clients/%d/notes/%d - обновление заметки", clientID, noteID)
+
+	var req noteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if assignee {
+		if req.FollowUpDone == nil {
+			http.Error(w, "Исполнитель может только отметить напоминание выполненным (follow_up_done)", http.StatusForbidden)
+			return
+		}
+		_, err := database.DB.Exec(`
+			UPDATE client_notes
+			SET follow_up_done_at = CASE WHEN $1 THEN COALESCE(follow_up_done_at, NOW()) END, updated_at = NOW()
+			WHERE id = $2
+		`, *req.FollowUpDone, noteID)
+		if err != nil {
+			log.Printf("Ошибка обновления: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeUpdatedNote(w, clientID, noteID)
+		return
+	}
+	if err := req.validate(); err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка проверки заметки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE client_notes
+		SET kind = $1, visibility = $2, body = $3, tags = $4, follow_up_at = $5,
+		    assigned_to = CASE WHEN $5::timestamp IS NULL THEN NULL ELSE COALESCE($6, assigned_to, author_id) END,
+		    follow_up_done_at = CASE
+		        WHEN $5::timestamp IS NULL THEN NULL
+		        WHEN $7::boolean IS NULL THEN follow_up_done_at
+		        WHEN $7 THEN COALESCE(follow_up_done_at, NOW())
+		    END,
+		    updated_at = NOW()
+		WHERE id = $8
+	`, req.Kind, req.Visibility, req.Body, pq.Array(req.Tags), req.FollowUpAt, req.AssignedTo, req.FollowUpDone, noteID)
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeUpdatedNote(w, clientID, noteID)
+}
+
+// writeUpdatedNote отдает заметку после изменения
+func writeUpdatedNote(w http.ResponseWriter, clientID, noteID int) {
+	updated, err := scanNote(database.DB.QueryRow(noteSelect+` WHERE n.id = $1`, noteID))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлена заметка %d клиента %d", noteID, clientID)
+}
+
+// DeleteClientNote удаляет заметку
+func DeleteClientNote(w http.ResponseWriter, r *http.Request) {
+	clientID, noteID, _, ok := authorizeNote(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("DELETE /api/clients/%d/notes/%d - удаление заметки", clientID, noteID)
+
+	if _, err := database.DB.Exec(`DELETE FROM client_notes WHERE id = $1`, noteID); err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Удалена заметка %d клиента %d", noteID, clientID)
+}
+
+// GetFollowUps возвращает невыполненные напоминания текущего сотрудника со сроком до until
+// (YYYY-MM-DD, по умолчанию сегодня включительно). Администратор с all=true видит напоминания всех.
+func GetFollowUps(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/follow-ups - напоминания по клиентам")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	until := currentDate()
+	if value := r.URL.Query().Get("until"); value != "" {
+		until, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Неверный формат даты until, ожидается YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	args := []interface{}{until}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	query := noteSelect + `
+		WHERE n.follow_up_at IS NOT NULL AND n.follow_up_done_at IS NULL
+		AND n.follow_up_at < $1::date + 1 AND c.deleted_at IS NULL`
+	if !(user.Role == "admin" && r.URL.Query().Get("all") == "true") {
+		query += " AND COALESCE(n.assigned_to, n.author_id) = " + arg(user.ID)
+	}
+	query += " ORDER BY n.follow_up_at"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	notes := make([]models.ClientNote, 0)
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		notes = append(notes, n)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notes)
+	log.Printf("Возвращено напоминаний: %d", len(notes))
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// notifyTrainingParticipants отправляет уведомление всем записанным на тренировку и возвращает
+// число получателей
+func notifyTrainingParticipants(q queryer, trainingID int, kind, title, message string) (int, error) {
+	result, err := q.Exec(`
+		INSERT INTO notifications (user_id, kind, title, message, training_id)
+		SELECT user_id, $2, $3, $4, training_id
+		FROM training_participants
+		WHERE training_id = $1 AND status = 'registered'
+	`, trainingID, kind, title, message)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetNotifications возвращает уведомления текущего пользователя (unread=true — только непрочитанные)
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/notifications - получение уведомлений")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, kind, title, message, training_id, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT 100
+	`, user.ID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	notifications := make([]models.Notification, 0)
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Title, &n.Message, &n.TrainingID, &n.ReadAt, &n.CreatedAt)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// MarkNotificationRead отмечает уведомление текущего пользователя прочитанным
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/notifications/%d/read - отметка уведомления прочитанным", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2
+	`, id, user.ID)
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Уведомление не найдено", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
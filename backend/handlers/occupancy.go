@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Occupancy — текущая загруженность клуба
+type Occupancy struct {
+	Total     int            `json:"total"`
+	Halls     map[string]int `json:"halls"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TypicalOccupancy — средняя загруженность в указанный час дня недели
+type TypicalOccupancy struct {
+	Weekday         int     `json:"weekday"` // 1 — понедельник, 7 — воскресенье
+	Hour            int     `json:"hour"`
+	AverageVisitors float64 `json:"average_visitors"`
+}
+
+// maxStayInterval — через сколько часов незакрытое посещение перестает учитываться:
+// клиенты не всегда отмечаются на выходе
+func maxStayInterval() string {
+	return fmt.Sprintf("%d hours", getEnvInt("OCCUPANCY_MAX_STAY_HOURS", 4))
+}
+
+// currentOccupancy считает клиентов в клубе по незакрытым посещениям
+func currentOccupancy() (Occupancy, error) {
+	occupancy := Occupancy{Halls: make(map[string]int), UpdatedAt: time.Now()}
+	for _, hall := range models.Halls {
+		occupancy.Halls[hall] = 0
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT hall, COUNT(*)
+		FROM visits
+		WHERE checked_out_at IS NULL AND checked_in_at > NOW() - $1::interval
+		GROUP BY hall
+	`, maxStayInterval())
+	if err != nil {
+		return occupancy, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hall string
+		var count int
+		if err := rows.Scan(&hall, &count); err != nil {
+			return occupancy, err
+		}
+		occupancy.Halls[hall] = count
+		occupancy.Total += count
+	}
+	return occupancy, rows.Err()
+}
+
+// occupancyBroker рассылает изменения загруженности подписчикам SSE
+type occupancyBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+var occupancyEvents = &occupancyBroker{subscribers: make(map[chan []byte]struct{})}
+
+func (b *occupancyBroker) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *occupancyBroker) unsubscribe(ch chan []byte) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// publish отправляет событие всем подписчикам; медленный подписчик получит только последнее
+func (b *occupancyBroker) publish(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- data:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- data
+		}
+	}
+}
+
+// publishOccupancy рассылает текущую загруженность после входа или выхода клиента
+func publishOccupancy() {
+	occupancy, err := currentOccupancy()
+	if err != nil {
+		log.Printf("Ошибка расчета загруженности: %v", err)
+		return
+	}
+	data, err := json.Marshal(occupancy)
+	if err != nil {
+		log.Printf("Ошибка кодирования загруженности: %v", err)
+		return
+	}
+	occupancyEvents.publish(data)
+}
+
+// GetOccupancy возвращает текущее количество клиентов по залам
+func GetOccupancy(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/occupancy - текущая загруженность клуба")
+
+	occupancy, err := currentOccupancy()
+	if err != nil {
+		log.Printf("Ошибка расчета загруженности: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occupancy)
+}
+
+// StreamOccupancy отправляет загруженность клуба потоком Server-Sent Events:
+// сразу текущее значение, затем каждое изменение
+func StreamOccupancy(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/occupancy/stream - подписка на загруженность клуба")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+
+	occupancy, err := currentOccupancy()
+	if err != nil {
+		log.Printf("Ошибка расчета загруженности: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, _ := json.Marshal(occupancy)
+
+	events := occupancyEvents.subscribe()
+	defer occupancyEvents.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "event: occupancy\ndata: %s\n\n", data)
+	flusher.Flush()
+
+	// Комментарий раз в полминуты не дает прокси закрыть соединение
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-events:
+			fmt.Fprintf(w, "event: occupancy\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// GetTypicalOccupancy возвращает типичную загруженность по часам дней недели
+// за последние weeks недель (по умолчанию 8), можно указать зал через hall
+func GetTypicalOccupancy(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/occupancy/typical - типичная загруженность клуба")
+
+	weeks := 8
+	if value := r.URL.Query().Get("weeks"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 52 {
+			http.Error(w, "weeks должен быть от 1 до 52", http.StatusBadRequest)
+			return
+		}
+		weeks = parsed
+	}
+	hall := r.URL.Query().Get("hall")
+	if hall != "" && !models.ValidHall(hall) {
+		http.Error(w, "Неизвестный зал", http.StatusBadRequest)
+		return
+	}
+
+	// Для каждого часа за период считаем, сколько посещений его пересекало,
+	// затем усредняем по дню недели и часу
+	rows, err := database.DB.Query(`
+		WITH slots AS (
+			SELECT generate_series(
+				date_trunc('hour', NOW()) - $1::int * INTERVAL '1 week',
+				date_trunc('hour', NOW()) - INTERVAL '1 hour',
+				INTERVAL '1 hour'
+			) AS slot
+		),
+		counts AS (
+			SELECT s.slot, COUNT(v.id) AS visitors
+			FROM slots s
+			LEFT JOIN visits v ON v.checked_in_at < s.slot + INTERVAL '1 hour'
+				AND LEAST(COALESCE(v.checked_out_at, v.checked_in_at + $2::interval), v.checked_in_at + $2::interval) > s.slot
+				AND ($3 = '' OR v.hall = $3)
+			GROUP BY s.slot
+		)
+		SELECT EXTRACT(ISODOW FROM slot)::int, EXTRACT(HOUR FROM slot)::int, AVG(visitors)
+		FROM counts
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, weeks, maxStayInterval(), hall)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]TypicalOccupancy, 0, 7*24)
+	for rows.Next() {
+		var t TypicalOccupancy
+		if err := rows.Scan(&t.Weekday, &t.Hour, &t.AverageVisitors); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		t.AverageVisitors = math.Round(t.AverageVisitors*10) / 10
+		result = append(result, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
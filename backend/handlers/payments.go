@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// PaymentProvider списывает оплату с сохраненного способа оплаты клиента (используется при автопродлении)
+type PaymentProvider interface {
+	Name() string
+	Charge(clientID int, amount models.Money, description string) (reference string, err error)
+}
+
+// errManualPayment означает, что провайдер не умеет списывать деньги сам и оплату нужно принять на ресепшене
+var errManualPayment = errors.New("автоматическое списание не настроено")
+
+// manualProvider — провайдер по умолчанию: счета ждут оплаты на ресепшене
+type manualProvider struct{}
+
+func (manualProvider) Name() string { return "manual" }
+
+func (manualProvider) Charge(int, models.Money, string) (string, error) {
+	return "", errManualPayment
+}
+
+// sandboxProvider успешно проводит любые списания (для разработки и демонстрации)
+type sandboxProvider struct{}
+
+func (sandboxProvider) Name() string { return "sandbox" }
+
+func (sandboxProvider) Charge(clientID int, amount models.Money, _ string) (string, error) {
+	return fmt.Sprintf("sandbox-%d-%d", clientID, time.Now().UnixNano()), nil
+}
+
+var paymentProvider PaymentProvider
+
+// SetPaymentProvider подключает провайдера платежей для автопродления
+func SetPaymentProvider(p PaymentProvider) {
+	paymentProvider = p
+}
+
+// currentPaymentProvider возвращает подключенного провайдера или выбранного в PAYMENT_PROVIDER
+func currentPaymentProvider() PaymentProvider {
+	if paymentProvider != nil {
+		return paymentProvider
+	}
+	if getEnv("PAYMENT_PROVIDER", "manual") == "sandbox" {
+		return sandboxProvider{}
+	}
+	return manualProvider{}
+}
+
+// recordPayment добавляет запись в журнал платежей
+func recordPayment(q queryer, p *models.Payment) error {
+	return q.QueryRow(`
+		INSERT INTO payments (subscription_id, client_id, kind, amount, method, status,
+		                      provider, provider_reference, description, paid_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''),
+		        CASE WHEN $6 = 'paid' THEN NOW() END)
+		RETURNING id, created_at, paid_at
+	`, p.SubscriptionID, p.ClientID, p.Kind, p.Amount, p.Method, p.Status,
+		p.Provider, p.ProviderReference, p.Description).Scan(&p.ID, &p.CreatedAt, &p.PaidAt)
+}
+
+const paymentColumns = `
+	id, subscription_id, client_id, kind, amount, method, status,
+	COALESCE(provider, ''), COALESCE(provider_reference, ''), COALESCE(description, ''), created_at, paid_at
+`
+
+func scanPayment(row interface{ Scan(...interface{}) error }) (models.Payment, error) {
+	var p models.Payment
+	var subscriptionID, clientID sql.NullInt64
+	var paidAt sql.NullTime
+	err := row.Scan(&p.ID, &subscriptionID, &clientID, &p.Kind, &p.Amount, &p.Method, &p.Status,
+		&p.Provider, &p.ProviderReference, &p.Description, &p.CreatedAt, &paidAt)
+	if err != nil {
+		return p, err
+	}
+	if paidAt.Valid {
+		p.PaidAt = &paidAt.Time
+	}
+	if subscriptionID.Valid {
+		id := int(subscriptionID.Int64)
+		p.SubscriptionID = &id
+	}
+	if clientID.Valid {
+		id := int(clientID.Int64)
+		p.ClientID = &id
+	}
+	return p, nil
+}
+
+// validPaymentMethod проверяет способ оплаты, указанный сотрудником
+func validPaymentMethod(method string) bool {
+	return method == "cash" || method == "card" || method == "online"
+}
+
+// GetPayments возвращает журнал платежей с фильтрами client_id, subscription_id, kind и status
+func GetPayments(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/payments - получение журнала платежей")
+
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE 1=1`
+	args := []interface{}{}
+	argNum := 1
+	for _, filter := range []string{"client_id", "subscription_id", "kind", "status"} {
+		if value := r.URL.Query().Get(filter); value != "" {
+			query += " AND " + filter + " = $" + strconv.Itoa(argNum)
+			args = append(args, value)
+			argNum++
+		}
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	payments := make([]models.Payment, 0)
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		payments = append(payments, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payments)
+	log.Printf("Возвращено платежей: %d", len(payments))
+}
+
+// ConfirmPayment отмечает ожидающий платеж как оплаченный
+func ConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/payments/%d/confirm - подтверждение оплаты", id)
+
+	var req struct {
+		Method string `json:"method"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Method != "" && !validPaymentMethod(req.Method) {
+		http.Error(w, "Неизвестный способ оплаты. Доступны: cash, card, online", http.StatusBadRequest)
+		return
+	}
+
+	// Оплата и бонусы за нее (баллы за покупку, награда пригласившему) фиксируются вместе
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	p, err := scanPayment(tx.QueryRow(`
+		UPDATE payments
+		SET status = 'paid', paid_at = NOW(), method = COALESCE(NULLIF($1, ''), method)
+		WHERE id = $2 AND status = 'pending'
+		RETURNING `+paymentColumns, req.Method, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Ожидающий оплаты платеж не найден", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = awardPurchasePoints(tx, p)
+	}
+	if err == nil {
+		err = awardReferral(tx, p)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Ошибка подтверждения оплаты: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+	log.Printf("Платеж %d подтвержден", id)
+}
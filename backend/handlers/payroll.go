@@ -0,0 +1,768 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Зарплата считается по схемам оплаты, действовавшим в периоде. Учитываются только завершенные
+// тренировки, на которых отмечен хотя бы один посетивший участник. Оклад начисляется
+// пропорционально дням периода в каждом месяце. После закрытия периода расчет не меняется,
+// а тренировки внутри периода нельзя изменить.
+
+// schemeTypes — доступные схемы оплаты
+var schemeTypes = map[string]bool{
+	"fixed_monthly": true, "per_training": true, "per_participant": true, "personal_revenue_percent": true,
+}
+
+const compensationColumns = `id, employee_id, scheme_type, rate, valid_from, valid_to, created_at`
+
+// splitRate раскладывает значение колонки rate по типу схемы: процент выручки
+// для personal_revenue_percent, сумма для остальных
+func splitRate(schemeType string, value models.Money) (*models.Money, models.Percent) {
+	if schemeType == "personal_revenue_percent" {
+		return nil, models.Percent(value.Amount)
+	}
+	return &value, 0
+}
+
+// rateValue возвращает значение колонки rate для схемы или строки расчета
+func rateValue(amount *models.Money, percent models.Percent) interface{} {
+	if amount != nil {
+		return *amount
+	}
+	return percent
+}
+
+func scanCompensationScheme(row interface{ Scan(...interface{}) error }) (models.CompensationScheme, error) {
+	var c models.CompensationScheme
+	var validTo sql.NullTime
+	var rate models.Money
+	err := row.Scan(&c.ID, &c.EmployeeID, &c.SchemeType, &rate, &c.ValidFrom, &validTo, &c.CreatedAt)
+	if err != nil {
+		return c, err
+	}
+	c.RateAmount, c.RatePercent = splitRate(c.SchemeType, rate)
+	if validTo.Valid {
+		c.ValidTo = &validTo.Time
+	}
+	return c, nil
+}
+
+// payrollLocked сообщает, попадает ли момент в закрытый период расчета зарплаты
+func payrollLocked(q queryer, at time.Time) (bool, error) {
+	var locked bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM payroll_runs
+			WHERE status = 'locked' AND period_start <= $1::date AND period_end >= $1::date
+		)
+	`, at).Scan(&locked)
+	return locked, err
+}
+
+// trainingPayrollLocked сообщает, что тренировка относится к закрытому периоду расчета зарплаты
+func trainingPayrollLocked(q queryer, trainingID int) (bool, error) {
+	var locked bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM trainings t
+			JOIN payroll_runs pr ON pr.status = 'locked'
+			AND pr.period_start <= t.start_time::date AND pr.period_end >= t.start_time::date
+			WHERE t.id = $1
+		)
+	`, trainingID).Scan(&locked)
+	return locked, err
+}
+
+// GetCompensationSchemes возвращает схемы оплаты сотрудника
+func GetCompensationSchemes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/employees/%d/compensation - получение схем оплаты", id)
+
+	rows, err := database.DB.Query(`
+		SELECT `+compensationColumns+` FROM compensation_schemes
+		WHERE employee_id = $1
+		ORDER BY valid_from DESC, id DESC
+	`, id)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	schemes := make([]models.CompensationScheme, 0)
+	for rows.Next() {
+		c, err := scanCompensationScheme(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		schemes = append(schemes, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schemes)
+}
+
+// CreateCompensationScheme добавляет сотруднику схему оплаты
+func CreateCompensationScheme(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/employees/%d/compensation - добавление схемы оплаты", id)
+
+	var req struct {
+		SchemeType  string         `json:"scheme_type"`
+		RateAmount  *models.Money  `json:"rate_amount"`  // для fixed_monthly, per_training, per_participant
+		RatePercent models.Percent `json:"rate_percent"` // для personal_revenue_percent
+		ValidFrom   string         `json:"valid_from"`   // YYYY-MM-DD
+		ValidTo     string         `json:"valid_to"`     // YYYY-MM-DD, необязательно
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !schemeTypes[req.SchemeType] {
+		http.Error(w, "Неизвестная схема. Доступны: fixed_monthly, per_training, per_participant, personal_revenue_percent", http.StatusBadRequest)
+		return
+	}
+	if req.SchemeType == "personal_revenue_percent" {
+		req.RateAmount = nil
+		if req.RatePercent <= 0 || req.RatePercent > models.WholePercent(100) {
+			http.Error(w, "rate_percent должен быть от 0 до 100", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.RatePercent = 0
+		if req.RateAmount == nil || !req.RateAmount.IsPositive() {
+			http.Error(w, "rate_amount должен быть больше нуля", http.StatusBadRequest)
+			return
+		}
+	}
+	validFrom, err := time.Parse("2006-01-02", req.ValidFrom)
+	if err != nil {
+		http.Error(w, "Неверный формат valid_from, ожидается YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	var validTo *time.Time
+	if req.ValidTo != "" {
+		parsed, err := time.Parse("2006-01-02", req.ValidTo)
+		if err != nil || parsed.Before(validFrom) {
+			http.Error(w, "Неверный valid_to", http.StatusBadRequest)
+			return
+		}
+		validTo = &parsed
+	}
+
+	// Схема не должна менять уже закрытые расчеты
+	locked, err := payrollLocked(database.DB, validFrom)
+	if err != nil {
+		log.Printf("Ошибка проверки закрытого периода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "Дата начала попадает в закрытый период расчета зарплаты", http.StatusConflict)
+		return
+	}
+
+	if _, err := employeeName(database.DB, id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Сотрудник не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanCompensationScheme(database.DB.QueryRow(`
+		INSERT INTO compensation_schemes (employee_id, scheme_type, rate, valid_from, valid_to)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+compensationColumns, id, req.SchemeType, rateValue(req.RateAmount, req.RatePercent), validFrom, validTo))
+	if err != nil {
+		log.Printf("Ошибка создания схемы оплаты: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Сотруднику %d добавлена схема оплаты %s", id, created.SchemeType)
+}
+
+// DeleteCompensationScheme удаляет схему оплаты; закрытые расчеты сохраняют начисления по ней
+func DeleteCompensationScheme(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+	schemeID, err := strconv.Atoi(vars["schemeId"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/employees/%d/compensation/%d - удаление схемы оплаты", id, schemeID)
+
+	result, err := database.DB.Exec(`DELETE FROM compensation_schemes WHERE id = $1 AND employee_id = $2`, schemeID, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Схема оплаты не найдена", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// payrollScheme — схема оплаты сотрудника, действующая в периоде расчета
+type payrollScheme struct {
+	models.CompensationScheme
+	EmployeeName string
+	UserID       int
+}
+
+// trainingEarningsBase — завершенные тренировки тренера за период, на которых были посетители
+type trainingEarningsBase struct {
+	Trainings       int
+	Participants    int
+	PersonalRevenue models.Money
+}
+
+func loadTrainingEarningsBase(q queryer, trainerID int, from, to time.Time) (trainingEarningsBase, error) {
+	var b trainingEarningsBase
+	err := q.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(a.attended), 0),
+		       COALESCE(SUM(t.price) FILTER (WHERE t.type = 'personal'), 0)
+		FROM trainings t
+		JOIN LATERAL (
+			SELECT COUNT(*) AS attended FROM training_participants tp
+			WHERE tp.training_id = t.id AND tp.status = 'attended'
+		) a ON TRUE
+		WHERE t.trainer_id = $1 AND t.status = 'completed' AND t.deleted_at IS NULL
+		AND t.start_time >= $2 AND t.start_time < $3::date + 1 AND a.attended > 0
+	`, trainerID, from, to).Scan(&b.Trainings, &b.Participants, &b.PersonalRevenue)
+	return b, err
+}
+
+// monthFraction возвращает суммарную долю месяцев, покрытую днями from–to включительно,
+// и оклад за эти дни
+func monthFraction(rate models.Money, from, to time.Time) (float64, models.Money) {
+	var fraction float64
+	amount := models.NewMoney(0)
+	for day := from; !day.After(to); {
+		monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		monthEnd := monthStart.AddDate(0, 1, -1)
+		last := monthEnd
+		if to.Before(last) {
+			last = to
+		}
+		days := int64(last.Sub(day).Hours()/24) + 1
+		daysInMonth := int64(monthEnd.Day())
+		fraction += float64(days) / float64(daysInMonth)
+		amount = amount.Add(rate.MulRatio(days, daysInMonth))
+		day = monthEnd.AddDate(0, 0, 1)
+	}
+	return fraction, amount
+}
+
+// calculatePayroll пересчитывает строки черновика расчета за его период
+func calculatePayroll(tx *sql.Tx, run *models.PayrollRun) error {
+	if _, err := tx.Exec(`DELETE FROM payroll_lines WHERE run_id = $1`, run.ID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT cs.id, cs.employee_id, cs.scheme_type, cs.rate, cs.valid_from, cs.valid_to, cs.created_at,
+		       u.name, u.id
+		FROM compensation_schemes cs
+		JOIN employees e ON cs.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE cs.valid_from <= $2 AND (cs.valid_to IS NULL OR cs.valid_to >= $1)
+		ORDER BY u.name, cs.scheme_type, cs.valid_from
+	`, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	var schemes []payrollScheme
+	for rows.Next() {
+		var s payrollScheme
+		var validTo sql.NullTime
+		var rate models.Money
+		err := rows.Scan(&s.ID, &s.EmployeeID, &s.SchemeType, &rate, &s.ValidFrom, &validTo, &s.CreatedAt,
+			&s.EmployeeName, &s.UserID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		s.RateAmount, s.RatePercent = splitRate(s.SchemeType, rate)
+		if validTo.Valid {
+			s.ValidTo = &validTo.Time
+		}
+		schemes = append(schemes, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	run.Total = models.NewMoney(0)
+	run.Lines = []models.PayrollLine{}
+	for _, s := range schemes {
+		from, to := run.PeriodStart, run.PeriodEnd
+		if s.ValidFrom.After(from) {
+			from = s.ValidFrom
+		}
+		if s.ValidTo != nil && s.ValidTo.Before(to) {
+			to = *s.ValidTo
+		}
+
+		employeeID, schemeID := s.EmployeeID, s.ID
+		line := models.PayrollLine{
+			EmployeeID:   &employeeID,
+			EmployeeName: s.EmployeeName,
+			SchemeID:     &schemeID,
+			SchemeType:   s.SchemeType,
+			RateAmount:   s.RateAmount,
+			RatePercent:  s.RatePercent,
+		}
+		rate := models.NewMoney(0)
+		if s.RateAmount != nil {
+			rate = *s.RateAmount
+		}
+		period := from.Format("02.01.2006") + "–" + to.Format("02.01.2006")
+
+		if s.SchemeType == "fixed_monthly" {
+			line.Quantity, line.Amount = monthFraction(rate, from, to)
+			line.Quantity = round2(line.Quantity)
+			line.Description = fmt.Sprintf("Оклад %s за %s", rate, period)
+		} else {
+			base, err := loadTrainingEarningsBase(tx, s.UserID, from, to)
+			if err != nil {
+				return err
+			}
+			switch s.SchemeType {
+			case "per_training":
+				line.Quantity = float64(base.Trainings)
+				line.Amount = rate.Mul(int64(base.Trainings))
+				line.Description = fmt.Sprintf("Проведенные тренировки за %s: %d × %s", period, base.Trainings, rate)
+			case "per_participant":
+				line.Quantity = float64(base.Participants)
+				line.Amount = rate.Mul(int64(base.Participants))
+				line.Description = fmt.Sprintf("Посетившие участники за %s: %d × %s", period, base.Participants, rate)
+			case "personal_revenue_percent":
+				line.Quantity = base.PersonalRevenue.Float64()
+				line.Amount = base.PersonalRevenue.Percent(s.RatePercent)
+				line.Description = fmt.Sprintf("%s%% выручки персональных тренировок за %s (%s)", s.RatePercent, period, base.PersonalRevenue)
+			}
+		}
+		if line.Quantity == 0 {
+			continue
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO payroll_lines (run_id, employee_id, employee_name, scheme_id, scheme_type, description,
+			                           quantity, rate, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+		`, run.ID, line.EmployeeID, line.EmployeeName, line.SchemeID, line.SchemeType, line.Description,
+			line.Quantity, rateValue(line.RateAmount, line.RatePercent), line.Amount).Scan(&line.ID)
+		if err != nil {
+			return err
+		}
+		run.Lines = append(run.Lines, line)
+		run.Total = run.Total.Add(line.Amount)
+	}
+
+	return tx.QueryRow(`
+		UPDATE payroll_runs SET total = $1, calculated_at = NOW() WHERE id = $2 RETURNING calculated_at
+	`, run.Total, run.ID).Scan(&run.CalculatedAt)
+}
+
+const payrollRunColumns = `
+	id, period_start, period_end, status, total, created_by, created_at, calculated_at, locked_by, locked_at
+`
+
+func scanPayrollRun(row interface{ Scan(...interface{}) error }) (models.PayrollRun, error) {
+	var p models.PayrollRun
+	var createdBy, lockedBy sql.NullInt64
+	var lockedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.PeriodStart, &p.PeriodEnd, &p.Status, &p.Total, &createdBy, &p.CreatedAt,
+		&p.CalculatedAt, &lockedBy, &lockedAt)
+	if err != nil {
+		return p, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		p.CreatedBy = &id
+	}
+	if lockedBy.Valid {
+		id := int(lockedBy.Int64)
+		p.LockedBy = &id
+	}
+	if lockedAt.Valid {
+		p.LockedAt = &lockedAt.Time
+	}
+	return p, nil
+}
+
+// loadPayrollLines возвращает строки расчета
+func loadPayrollLines(q queryer, runID int) ([]models.PayrollLine, error) {
+	rows, err := q.Query(`
+		SELECT id, employee_id, employee_name, scheme_id, scheme_type, description, quantity, rate, amount
+		FROM payroll_lines
+		WHERE run_id = $1
+		ORDER BY employee_name, id
+	`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]models.PayrollLine, 0)
+	for rows.Next() {
+		var l models.PayrollLine
+		var employeeID, schemeID sql.NullInt64
+		var rate models.Money
+		err := rows.Scan(&l.ID, &employeeID, &l.EmployeeName, &schemeID, &l.SchemeType, &l.Description,
+			&l.Quantity, &rate, &l.Amount)
+		if err != nil {
+			return nil, err
+		}
+		l.RateAmount, l.RatePercent = splitRate(l.SchemeType, rate)
+		if employeeID.Valid {
+			id := int(employeeID.Int64)
+			l.EmployeeID = &id
+		}
+		if schemeID.Valid {
+			id := int(schemeID.Int64)
+			l.SchemeID = &id
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// lockPayrollRun блокирует расчет до конца транзакции
+func lockPayrollRun(tx *sql.Tx, id int) (models.PayrollRun, error) {
+	return scanPayrollRun(tx.QueryRow(`SELECT `+payrollRunColumns+` FROM payroll_runs WHERE id = $1 FOR UPDATE`, id))
+}
+
+// GetPayrollRuns возвращает расчеты зарплаты без строк
+func GetPayrollRuns(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/payroll/runs - получение расчетов зарплаты")
+
+	rows, err := database.DB.Query(`SELECT ` + payrollRunColumns + ` FROM payroll_runs ORDER BY period_start DESC`)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	runs := make([]models.PayrollRun, 0)
+	for rows.Next() {
+		p, err := scanPayrollRun(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		runs = append(runs, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// GetPayrollRun возвращает расчет зарплаты со строками начислений
+func GetPayrollRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/payroll/runs/%d - получение расчета зарплаты", id)
+
+	run, err := scanPayrollRun(database.DB.QueryRow(`SELECT `+payrollRunColumns+` FROM payroll_runs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Расчет не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if run.Lines, err = loadPayrollLines(database.DB, id); err != nil {
+		log.Printf("Ошибка загрузки строк расчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+// CreatePayrollRun рассчитывает зарплату за период period_start–period_end (YYYY-MM-DD включительно).
+// Период не должен пересекаться с другими расчетами.
+func CreatePayrollRun(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/payroll/runs - расчет зарплаты")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		PeriodStart string `json:"period_start"`
+		PeriodEnd   string `json:"period_end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	start, errStart := time.Parse("2006-01-02", req.PeriodStart)
+	end, errEnd := time.Parse("2006-01-02", req.PeriodEnd)
+	if errStart != nil || errEnd != nil {
+		http.Error(w, "Неверный формат даты, ожидается YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) {
+		http.Error(w, "period_end не может быть раньше period_start", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Параллельные расчеты сериализуются, чтобы не создать пересекающиеся периоды
+	if _, err := tx.Exec(`LOCK TABLE payroll_runs IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		log.Printf("Ошибка блокировки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var overlapID int
+	err = tx.QueryRow(`
+		SELECT id FROM payroll_runs WHERE period_start <= $2 AND period_end >= $1 LIMIT 1
+	`, start, end).Scan(&overlapID)
+	if err == nil {
+		http.Error(w, fmt.Sprintf("Период пересекается с расчетом #%d", overlapID), http.StatusConflict)
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Ошибка проверки периода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	run, err := scanPayrollRun(tx.QueryRow(`
+		INSERT INTO payroll_runs (period_start, period_end, created_by) VALUES ($1, $2, $3)
+		RETURNING `+payrollRunColumns, start, end, user.ID))
+	if err != nil {
+		log.Printf("Ошибка создания расчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := calculatePayroll(tx, &run); err != nil {
+		log.Printf("Ошибка расчета зарплаты: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(run)
+	log.Printf("Рассчитана зарплата за %s–%s: %s (расчет %d)", req.PeriodStart, req.PeriodEnd, run.Total, run.ID)
+}
+
+// RecalculatePayrollRun пересчитывает черновик расчета по текущим данным
+func RecalculatePayrollRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/payroll/runs/%d/recalculate - пересчет зарплаты", id)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	run, err := lockPayrollRun(tx, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Расчет не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if run.Status == "locked" {
+		http.Error(w, "Период закрыт, пересчет невозможен", http.StatusConflict)
+		return
+	}
+	if err := calculatePayroll(tx, &run); err != nil {
+		log.Printf("Ошибка расчета зарплаты: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+	log.Printf("Расчет %d пересчитан: %s", id, run.Total)
+}
+
+// LockPayrollRun закрывает период: расчет больше не пересчитывается, тренировки периода не меняются
+func LockPayrollRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/payroll/runs/%d/lock - закрытие периода", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	run, err := scanPayrollRun(database.DB.QueryRow(`
+		UPDATE payroll_runs SET status = 'locked', locked_by = $1, locked_at = NOW()
+		WHERE id = $2 AND status = 'draft'
+		RETURNING `+payrollRunColumns, user.ID, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Черновик расчета не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка закрытия периода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+	log.Printf("Период расчета %d закрыт пользователем %d", id, user.ID)
+}
+
+// DeletePayrollRun удаляет черновик расчета; закрытый расчет удалить нельзя
+func DeletePayrollRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/payroll/runs/%d - удаление расчета", id)
+
+	result, err := database.DB.Exec(`DELETE FROM payroll_runs WHERE id = $1 AND status = 'draft'`, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Черновик расчета не найден", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportPayrollRun выгружает расчет зарплаты в CSV
+func ExportPayrollRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/payroll/runs/%d/export - выгрузка расчета зарплаты", id)
+
+	run, err := scanPayrollRun(database.DB.QueryRow(`SELECT `+payrollRunColumns+` FROM payroll_runs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Расчет не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lines, err := loadPayrollLines(database.DB, id)
+	if err != nil {
+		log.Printf("Ошибка загрузки строк расчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	records := make([][]string, 0, len(lines)+1)
+	for _, l := range lines {
+		rate := l.RatePercent.String()
+		if l.RateAmount != nil {
+			rate = l.RateAmount.String()
+		}
+		records = append(records, []string{
+			l.EmployeeName, l.SchemeType, l.Description,
+			strconv.FormatFloat(l.Quantity, 'f', 2, 64), rate, l.Amount.String(),
+		})
+	}
+	records = append(records, []string{"Итого", "", "", "", "", run.Total.String()})
+
+	filename := fmt.Sprintf("payroll-%s-%s.csv", run.PeriodStart.Format("2006-01-02"), run.PeriodEnd.Format("2006-01-02"))
+	header := []string{"Сотрудник", "Схема", "Описание", "Количество", "Ставка", "Сумма"}
+	if err := writeCSV(w, filename, header, records); err != nil {
+		log.Printf("Ошибка выгрузки расчета %d: %v", id, err)
+	}
+}
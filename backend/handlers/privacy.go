@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// exportSection — часть выгрузки персональных данных; запрос получает ID пользователя в $1
+type exportSection struct {
+	Name   string
+	Single bool // одна запись вместо списка
+	Query  string
+}
+
+// exportSections — все данные клиента, которые хранит клуб
+var exportSections = []exportSection{
+	{"profile", true, `SELECT id, name, email, role, created_at FROM users WHERE id = $1`},
+	{"client", true, `
+		SELECT id, phone, address, birth_date, is_student, family_id, emergency_contact_name, emergency_contact_phone,
+		       emergency_contact_relation, medical_notes, referral_code, created_at
+		FROM clients WHERE user_id = $1`},
+	// Лид, из которого получен клиент, и его пробные тренировки
+	{"leads", false, `
+		SELECT l.id, l.name, l.email, l.phone, l.source, l.status, l.notes, l.created_at, l.converted_at
+		FROM leads l
+		WHERE l.id IN (SELECT lead_id FROM clients WHERE user_id = $1) OR l.converted_user_id = $1
+		ORDER BY l.created_at`},
+	{"lead_trials", false, `
+		SELECT lt.lead_id, t.title, t.start_time, lt.status, lt.created_at
+		FROM lead_trials lt
+		JOIN leads l ON lt.lead_id = l.id
+		JOIN trainings t ON lt.training_id = t.id
+		WHERE l.id IN (SELECT lead_id FROM clients WHERE user_id = $1) OR l.converted_user_id = $1
+		ORDER BY t.start_time`},
+	{"subscriptions", false, `
+		SELECT s.id, s.type, s.start_date, s.end_date, s.price, s.base_price, s.discount_amount, s.discount_reason,
+		       s.status, s.auto_renew, s.cancelled_at, s.refund_amount, s.created_at
+		FROM subscriptions s JOIN clients c ON s.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY s.start_date`},
+	{"payments", false, `
+		SELECT p.id, p.subscription_id, p.kind, p.amount, p.method, p.status, p.description, p.created_at
+		FROM payments p JOIN clients c ON p.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY p.created_at`},
+	{"invoices", false, `
+		SELECT i.id, i.number, i.subscription_id, i.client_name, i.client_email, i.description, i.amount, i.issued_at
+		FROM invoices i JOIN subscriptions s ON i.subscription_id = s.id JOIN clients c ON s.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY i.issued_at`},
+	{"promo_code_redemptions", false, `
+		SELECT pc.code, r.subscription_id, r.redeemed_at
+		FROM promo_code_redemptions r
+		JOIN promo_codes pc ON r.promo_code_id = pc.id
+		JOIN clients c ON r.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY r.redeemed_at`},
+	{"visits", false, `
+		SELECT v.id, v.hall, v.checked_in_at, v.checked_out_at
+		FROM visits v JOIN clients c ON v.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY v.checked_in_at`},
+	{"trainings", false, `
+		SELECT t.id, t.title, t.type, t.hall_type, t.start_time, t.duration_minutes, tp.status, tp.registered_at
+		FROM training_participants tp JOIN trainings t ON tp.training_id = t.id
+		WHERE tp.user_id = $1 ORDER BY t.start_time`},
+	{"measurements", false, `
+		SELECT m.measured_at, m.weight_kg, m.body_fat_percent, m.chest_cm, m.waist_cm, m.hips_cm, m.arm_cm,
+		       m.thigh_cm, m.benchmarks, m.notes
+		FROM client_measurements m JOIN clients c ON m.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY m.measured_at`},
+	{"waivers", false, `
+		SELECT wv.version, cw.signed_name, cw.health_answers, cw.signed_at
+		FROM client_waivers cw
+		JOIN waiver_versions wv ON cw.waiver_version_id = wv.id
+		JOIN clients c ON cw.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY cw.signed_at`},
+	{"loyalty", false, `
+		SELECT lt.reason, lt.points, lt.expires_at, lt.description, lt.created_at
+		FROM loyalty_transactions lt JOIN clients c ON lt.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY lt.created_at`},
+	{"notes", false, `
+		SELECT n.kind, n.body, n.tags, n.created_at
+		FROM client_notes n JOIN clients c ON n.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY n.created_at`},
+	{"goals", false, `
+		SELECT g.metric, g.start_value, g.target_value, g.target_date, g.status, g.notes, g.created_at, g.achieved_at
+		FROM client_goals g JOIN clients c ON g.client_id = c.id
+		WHERE c.user_id = $1 ORDER BY g.created_at`},
+}
+
+// collectUserData собирает выгрузку по разделам в виде JSON
+func collectUserData(userID int) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(exportSections))
+	for _, section := range exportSections {
+		query := `SELECT COALESCE(json_agg(t), '[]') FROM (` + section.Query + `) t`
+		if section.Single {
+			query = `SELECT row_to_json(t) FROM (` + section.Query + `) t`
+		}
+		var raw []byte
+		err := database.DB.QueryRow(query, userID).Scan(&raw)
+		if err == sql.ErrNoRows {
+			raw = []byte("null")
+		} else if err != nil {
+			return nil, fmt.Errorf("раздел %s: %v", section.Name, err)
+		}
+		data[section.Name] = raw
+	}
+	return data, nil
+}
+
+// ExportUserData выгружает все персональные данные пользователя.
+// format=json — один JSON, по умолчанию ZIP-архив с разделами и PDF счетов.
+// Доступно самому пользователю и администратору.
+func ExportUserData(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/users/%d/export - выгрузка персональных данных", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	if user.Role != "admin" && user.ID != id {
+		http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		return
+	}
+
+	data, err := collectUserData(id)
+	if err != nil {
+		log.Printf("Ошибка выгрузки данных: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if string(data["profile"]) == "null" {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+
+	exportedAt := time.Now()
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-data.json"`, id))
+		json.NewEncoder(w).Encode(struct {
+			ExportedAt time.Time                  `json:"exported_at"`
+			Data       map[string]json.RawMessage `json:"data"`
+		}{exportedAt, data})
+		return
+	}
+
+	archive, err := buildExportArchive(id, data, exportedAt)
+	if err != nil {
+		log.Printf("Ошибка формирования архива: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-data.zip"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Write(archive)
+	log.Printf("Выгружены данные пользователя %d (%d байт)", id, len(archive))
+}
+
+// buildExportArchive собирает ZIP: по файлу JSON на раздел и PDF всех счетов
+func buildExportArchive(userID int, data map[string]json.RawMessage, exportedAt time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, section := range exportSections {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: section.Name + ".json", Method: zip.Deflate, Modified: exportedAt})
+		if err != nil {
+			return nil, err
+		}
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, data[section.Name], "", "  "); err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(pretty.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT i.number, i.pdf
+		FROM invoices i JOIN subscriptions s ON i.subscription_id = s.id JOIN clients c ON s.client_id = c.id
+		WHERE c.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var number string
+		var pdf []byte
+		if err := rows.Scan(&number, &pdf); err != nil {
+			return nil, err
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: "invoices/invoice-" + number + ".pdf", Method: zip.Store, Modified: exportedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(pdf); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EraseUser обезличивает персональные данные клиента. Абонементы, платежи, счета и посещения
+// сохраняются для отчетности, но больше не связаны с именем, контактами и датой рождения.
+// Замеры тела, цели, заметки сотрудников и ответы анкеты здоровья удаляются полностью.
+// Связанный лид обезличивается вместе с клиентом.
+func EraseUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/users/%d/erase - обезличивание персональных данных", id)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var role string
+	var anonymizedAt sql.NullTime
+	err = tx.QueryRow(`SELECT role, anonymized_at FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&role, &anonymizedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if anonymizedAt.Valid {
+		http.Error(w, "Данные пользователя уже обезличены", http.StatusConflict)
+		return
+	}
+	if role != "user" {
+		http.Error(w, "Обезличить можно только клиента. Сотрудника сначала переведите в клиенты", http.StatusBadRequest)
+		return
+	}
+
+	anonymousName := "Удаленный пользователь"
+	anonymousEmail := fmt.Sprintf("deleted-%d@anonymized.invalid", id)
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE users SET name = $1, email = $2, password = $3, anonymized_at = NOW() WHERE id = $4`,
+			[]interface{}{anonymousName, anonymousEmail, generateRandomPassword() + generateRandomPassword(), id}},
+		{`UPDATE clients SET phone = NULL, address = NULL, birth_date = NULL, is_student = FALSE, family_id = NULL,
+		         emergency_contact_name = NULL, emergency_contact_phone = NULL, emergency_contact_relation = NULL,
+		         medical_notes = NULL, referral_code = NULL, anonymized_at = NOW() WHERE user_id = $1`, []interface{}{id}},
+		// Лид остается в воронке продаж для статистики конверсии, но без имени и контактов
+		{`UPDATE leads SET name = $1, email = $2, phone = NULL, notes = NULL, lost_reason = NULL, updated_at = NOW()
+		  WHERE id IN (SELECT lead_id FROM clients WHERE user_id = $3) OR converted_user_id = $3`,
+			[]interface{}{anonymousName, anonymousEmail, id}},
+		// Факт подписи отказа от претензий сохраняется, ответы анкеты здоровья удаляются
+		{`UPDATE client_waivers SET signed_name = $1, health_answers = '[]'
+		  WHERE client_id IN (SELECT id FROM clients WHERE user_id = $2)`, []interface{}{anonymousName, id}},
+		{`DELETE FROM sessions WHERE user_id = $1`, []interface{}{id}},
+		{`DELETE FROM client_measurements WHERE client_id IN (SELECT id FROM clients WHERE user_id = $1)`, []interface{}{id}},
+		{`DELETE FROM client_goals WHERE client_id IN (SELECT id FROM clients WHERE user_id = $1)`, []interface{}{id}},
+		{`DELETE FROM client_notes WHERE client_id IN (SELECT id FROM clients WHERE user_id = $1)`, []interface{}{id}},
+		{`UPDATE subscriptions SET auto_renew = FALSE WHERE client_id IN (SELECT id FROM clients WHERE user_id = $1)`, []interface{}{id}},
+	}
+	for _, st := range statements {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			log.Printf("Ошибка обезличивания пользователя %d: %v", id, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Счета хранятся для бухгалтерии, поэтому не удаляются, а перевыпускаются с обезличенным плательщиком
+	if err := anonymizeInvoices(tx, id, anonymousName, anonymousEmail); err != nil {
+		log.Printf("Ошибка обезличивания счетов пользователя %d: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Персональные данные пользователя %d обезличены", id)
+}
+
+// anonymizeInvoices заменяет плательщика в счетах пользователя и перевыпускает их PDF.
+// Номер, дата, сумма и отметка об оплате берутся из сохраненного счета и не меняются.
+func anonymizeInvoices(tx *sql.Tx, userID int, name, email string) error {
+	rows, err := tx.Query(`SELECT `+invoiceColumns+`
+		FROM invoices i JOIN subscriptions s ON i.subscription_id = s.id JOIN clients c ON s.client_id = c.id
+		WHERE c.user_id = $1
+	`, userID)
+	if err != nil {
+		return err
+	}
+	var invoices []models.Invoice
+	for rows.Next() {
+		inv, err := scanInvoice(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		inv.ClientName, inv.ClientEmail = name, email
+		invoices = append(invoices, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, inv := range invoices {
+		pdf, err := renderInvoicePDF(&inv)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE invoices SET client_name = $1, client_email = $2, pdf = $3 WHERE id = $4`,
+			name, email, pdf, inv.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// GetPromoCodes возвращает список промокодов
+func GetPromoCodes(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/promo-codes - получение списка промокодов")
+
+	rows, err := database.DB.Query(`SELECT ` + promoCodeColumns + ` FROM promo_codes ORDER BY created_at DESC`)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	promoCodes := make([]models.PromoCode, 0)
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		promoCodes = append(promoCodes, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promoCodes)
+	log.Printf("Возвращено промокодов: %d", len(promoCodes))
+}
+
+// validatePromoCode проверяет и нормализует поля промокода
+func validatePromoCode(p *models.PromoCode) error {
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if p.Code == "" {
+		return validationError("code обязателен")
+	}
+	if p.DiscountType != "percent" && p.DiscountType != "fixed" {
+		return validationError("discount_type должен быть percent или fixed")
+	}
+	switch p.DiscountType {
+	case "percent":
+		p.DiscountAmount = nil
+		if p.DiscountPercent <= 0 || p.DiscountPercent > models.WholePercent(100) {
+			return validationError("discount_percent должен быть от 0 до 100")
+		}
+	case "fixed":
+		p.DiscountPercent = 0
+		if p.DiscountAmount == nil || !p.DiscountAmount.IsPositive() {
+			return validationError("discount_amount должен быть больше нуля")
+		}
+	}
+	if p.ValidFrom != nil && p.ValidTo != nil && p.ValidTo.Before(*p.ValidFrom) {
+		return validationError("valid_to не может быть раньше valid_from")
+	}
+	for _, code := range p.Plans {
+		if _, ok := models.FindPlan(code); !ok {
+			return validationError("Неизвестный тариф: " + code)
+		}
+	}
+	return nil
+}
+
+// promoDiscountValue возвращает значение колонки discount_value: процент или сумму
+func promoDiscountValue(p models.PromoCode) interface{} {
+	if p.DiscountType == "fixed" {
+		return *p.DiscountAmount
+	}
+	return p.DiscountPercent
+}
+
+// CreatePromoCode создает промокод
+func CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/promo-codes - создание промокода")
+
+	p := models.PromoCode{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePromoCode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := database.DB.QueryRow(`
+		INSERT INTO promo_codes (code, description, discount_type, discount_value, valid_from, valid_to,
+		                         usage_limit, per_client_limit, plans, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, p.Code, p.Description, p.DiscountType, promoDiscountValue(p), p.ValidFrom, p.ValidTo,
+		p.UsageLimit, p.PerClientLimit, pq.Array(p.Plans), p.Active).Scan(&p.ID, &p.CreatedAt)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Промокод с таким кодом уже существует", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка создания промокода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+	log.Printf("Создан промокод %s (ID: %d)", p.Code, p.ID)
+}
+
+// UpdatePromoCode обновляет промокод
+func UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/promo-codes/%d - обновление промокода", id)
+
+	var p models.PromoCode
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validatePromoCode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE promo_codes
+		SET code = $1, description = $2, discount_type = $3, discount_value = $4, valid_from = $5,
+		    valid_to = $6, usage_limit = $7, per_client_limit = $8, plans = $9, active = $10
+		WHERE id = $11
+	`, p.Code, p.Description, p.DiscountType, promoDiscountValue(p), p.ValidFrom, p.ValidTo,
+		p.UsageLimit, p.PerClientLimit, pq.Array(p.Plans), p.Active, id)
+
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Промокод с таким кодом уже существует", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Промокод не найден", http.StatusNotFound)
+		return
+	}
+
+	updated, err := scanPromoCode(database.DB.QueryRow(`SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Ошибка получения обновленного промокода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлен промокод с ID: %d", id)
+}
+
+// DeletePromoCode удаляет промокод
+func DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/promo-codes/%d - удаление промокода", id)
+
+	result, err := database.DB.Exec("DELETE FROM promo_codes WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Промокод не найден", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Удален промокод с ID: %d", id)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// refundQuote — расчет возврата при отмене абонемента
+type refundQuote struct {
+	Paid        models.Money `json:"paid"`         // оплачено по абонементу за вычетом прошлых возвратов
+	TotalDays   int          `json:"total_days"`   // длительность абонемента
+	UsedDays    int          `json:"used_days"`    // прошло дней на дату отмены
+	UnusedValue models.Money `json:"unused_value"` // стоимость неиспользованных дней
+	Visits      int          `json:"visits"`       // посещений за период абонемента
+	VisitsCost  models.Money `json:"visits_cost"`  // стоимость посещений по REFUND_VISIT_COST
+	Penalty     models.Money `json:"penalty"`      // штраф за досрочное расторжение
+	Refund      models.Money `json:"refund"`       // сумма к возврату
+}
+
+// cancelRequest — параметры отмены абонемента
+type cancelRequest struct {
+	Reason string `json:"reason"`
+	Method string `json:"method"` // способ возврата, по умолчанию cash
+	DryRun bool   `json:"dry_run"`
+}
+
+// paidAmount возвращает сумму, фактически оплаченную по абонементу, за вычетом возвратов
+func paidAmount(q queryer, subscriptionID int) (models.Money, error) {
+	paid := models.NewMoney(0)
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN kind = 'refund' THEN -amount ELSE amount END), 0)
+		FROM payments
+		WHERE subscription_id = $1 AND status = 'paid'
+	`, subscriptionID).Scan(&paid)
+	return paid, err
+}
+
+// calculateRefund считает возврат по абонементу на указанную дату
+func calculateRefund(q queryer, s models.Subscription, at time.Time) (refundQuote, error) {
+	paid, err := paidAmount(q, s.ID)
+	if err != nil || !paid.IsPositive() {
+		return refundQuote{Paid: models.NewMoney(0)}, err
+	}
+
+	// Посещения — дни со входом в клуб за период абонемента. Если входы не отмечались,
+	// считаются прошедшие и не отмененные тренировки клиента.
+	var visits int
+	err = q.QueryRow(`
+		SELECT GREATEST(
+			(SELECT COUNT(DISTINCT v.checked_in_at::date)
+			 FROM visits v
+			 WHERE v.client_id = $1 AND v.checked_in_at >= $2 AND v.checked_in_at < $3),
+			(SELECT COUNT(*)
+			 FROM training_participants tp
+			 JOIN trainings t ON tp.training_id = t.id
+			 JOIN clients c ON c.user_id = tp.user_id
+			 WHERE c.id = $1 AND tp.status <> 'cancelled' AND t.status <> 'cancelled'
+			 AND t.start_time >= $2 AND t.start_time < $3 AND t.start_time < NOW())
+		)
+	`, s.ClientID, s.StartDate, s.EndDate.AddDate(0, 0, 1)).Scan(&visits)
+	if err != nil {
+		return refundQuote{Paid: paid}, err
+	}
+
+	visitCost, err := models.ParseMoney(getEnv("REFUND_VISIT_COST", "0"))
+	if err != nil {
+		visitCost = models.NewMoney(0)
+	}
+	penalty := models.WholePercent(getEnvInt("REFUND_PENALTY_PERCENT", 10))
+	return prorateRefund(s, at, paid, visits, visitCost, penalty), nil
+}
+
+// prorateRefund возвращает стоимость неиспользованных дней абонемента на дату at.
+// Если задана стоимость посещения, возврат дополнительно ограничивается оплаченной суммой
+// за вычетом посещений. Из результата удерживается штраф penalty от оплаченной суммы.
+func prorateRefund(s models.Subscription, at time.Time, paid models.Money, visits int, visitCost models.Money, penalty models.Percent) refundQuote {
+	quote := refundQuote{Paid: paid, Visits: visits}
+	quote.TotalDays = int(s.EndDate.Sub(s.StartDate).Hours() / 24)
+	quote.UsedDays = int(at.Sub(s.StartDate).Hours() / 24)
+	if quote.UsedDays < 0 {
+		quote.UsedDays = 0
+	}
+	if quote.UsedDays > quote.TotalDays {
+		quote.UsedDays = quote.TotalDays
+	}
+	if quote.TotalDays > 0 {
+		quote.UnusedValue = quote.Paid.MulRatio(int64(quote.TotalDays-quote.UsedDays), int64(quote.TotalDays))
+	}
+
+	base := quote.UnusedValue
+	if visitCost.IsPositive() {
+		quote.VisitsCost = visitCost.Mul(int64(quote.Visits))
+		base = models.MinMoney(base, quote.Paid.Sub(quote.VisitsCost))
+	}
+
+	quote.Penalty = quote.Paid.Percent(penalty)
+	quote.Refund = models.MaxMoney(base.Sub(quote.Penalty), models.NewMoney(0))
+	return quote
+}
+
+// CancelSubscription отменяет абонемент и оформляет частичный возврат.
+// С dry_run=true только возвращает расчет возврата.
+func CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/subscriptions/%d/cancel - отмена абонемента", id)
+
+	var req cancelRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+	if req.Method == "" {
+		req.Method = "cash"
+	}
+	if !validPaymentMethod(req.Method) {
+		http.Error(w, "Неизвестный способ оплаты. Доступны: cash, card, online", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	s, err := scanSubscription(tx.QueryRow(subscriptionSelect+` WHERE s.id = $1 AND s.deleted_at IS NULL FOR UPDATE OF s`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Абонемент не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Status == "cancelled" {
+		http.Error(w, "Абонемент уже отменен", http.StatusConflict)
+		return
+	}
+
+	quote, err := calculateRefund(tx, s, currentDate())
+	if err != nil {
+		log.Printf("Ошибка расчета возврата: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.DryRun {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(quote)
+		return
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE subscriptions
+		SET status = 'cancelled', cancelled_at = $1, cancellation_reason = NULLIF($2, ''),
+		    refund_amount = $3, auto_renew = FALSE
+		WHERE id = $4
+	`, now, req.Reason, quote.Refund, id)
+	if err != nil {
+		log.Printf("Ошибка отмены абонемента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Неоплаченные счета по отмененному абонементу больше не ждут оплаты
+	_, err = tx.Exec(`UPDATE payments SET status = 'failed' WHERE subscription_id = $1 AND status = 'pending'`, id)
+	if err != nil {
+		log.Printf("Ошибка закрытия ожидающих платежей: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := reverseSubscriptionLoyalty(tx, s, quote); err != nil {
+		log.Printf("Ошибка отмены бонусных баллов: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if quote.Refund.IsPositive() {
+		err = recordPayment(tx, &models.Payment{
+			SubscriptionID: &s.ID, ClientID: &s.ClientID, Kind: "refund", Amount: quote.Refund,
+			Method: req.Method, Status: "paid",
+			Description: fmt.Sprintf("Возврат за абонемент «%s» с %s", s.Type, s.StartDate.Format("02.01.2006")),
+		})
+		if err != nil {
+			log.Printf("Ошибка записи возврата: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения отмены: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.Status = "cancelled"
+	s.CancelledAt = &now
+	s.RefundAmount = quote.Refund
+	s.AutoRenew = false
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Subscription models.Subscription `json:"subscription"`
+		Refund       refundQuote         `json:"refund"`
+	}{s, quote})
+	log.Printf("Абонемент %d отменен, возврат %s", id, quote.Refund)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fitness-club/models"
+	"testing"
+	"time"
+)
+
+func TestProrateRefund(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	// Абонемент на 30 дней
+	s := models.Subscription{StartDate: date(time.January, 1), EndDate: date(time.January, 31)}
+	tests := []struct {
+		name      string
+		s         models.Subscription
+		at        time.Time
+		paid      int64
+		visits    int
+		visitCost int64
+		penalty   models.Percent
+		usedDays  int
+		unused    int64
+		withheld  int64
+		refund    int64
+	}{
+		{"до начала", s, date(time.January, 1).AddDate(0, 0, -5), 300000, 0, 0, 0, 0, 300000, 0, 300000},
+		{"треть срока", s, date(time.January, 11), 300000, 0, 0, 0, 10, 200000, 0, 200000},
+		{"округление до копейки", s, date(time.January, 11), 100000, 0, 0, 0, 10, 66667, 0, 66667},
+		{"после окончания", s, date(time.February, 10), 300000, 0, 0, 0, 30, 0, 0, 0},
+		{"штраф", s, date(time.January, 11), 300000, 0, 0, models.WholePercent(10), 10, 200000, 30000, 170000},
+		{"штраф больше остатка", s, date(time.January, 28), 300000, 0, 0, models.WholePercent(20), 27, 30000, 60000, 0},
+		{"посещения ограничивают возврат", s, date(time.January, 11), 300000, 3, 50000, 0, 10, 200000, 0, 150000},
+		{"посещения меньше неиспользованных дней", s, date(time.January, 11), 300000, 1, 50000, 0, 10, 200000, 0, 200000},
+		{"посещения и штраф", s, date(time.January, 11), 300000, 3, 50000, models.WholePercent(10), 10, 200000, 30000, 120000},
+		{"посещения дороже оплаты", s, date(time.January, 11), 300000, 8, 50000, 0, 10, 200000, 0, 0},
+		{"стоимость посещения не задана", s, date(time.January, 11), 300000, 8, 0, 0, 10, 200000, 0, 200000},
+		{"нулевая длительность", models.Subscription{StartDate: date(time.January, 1), EndDate: date(time.January, 1)},
+			date(time.January, 1), 300000, 0, 0, 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prorateRefund(tt.s, tt.at, models.NewMoney(tt.paid), tt.visits, models.NewMoney(tt.visitCost), tt.penalty)
+			if got.UsedDays != tt.usedDays || got.UnusedValue.Amount != tt.unused ||
+				got.Penalty.Amount != tt.withheld || got.Refund.Amount != tt.refund {
+				t.Errorf("использовано %d дн., остаток %d, штраф %d, возврат %d; ожидалось %d дн., %d, %d, %d",
+					got.UsedDays, got.UnusedValue.Amount, got.Penalty.Amount, got.Refund.Amount,
+					tt.usedDays, tt.unused, tt.withheld, tt.refund)
+			}
+			if want := tt.visitCost * int64(tt.visits); got.VisitsCost.Amount != want {
+				t.Errorf("стоимость посещений %d, ожидалось %d", got.VisitsCost.Amount, want)
+			}
+		})
+	}
+}
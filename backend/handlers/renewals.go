@@ -0,0 +1,559 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var errAlreadyRenewed = errors.New("Абонемент уже продлен")
+
+// currentDate возвращает сегодняшнюю дату в том же виде, в каком приходят колонки DATE (полночь UTC)
+func currentDate() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// unusedValue возвращает долю суммы paid, приходящуюся на неиспользованные дни абонемента
+// на указанную дату
+func unusedValue(s models.Subscription, paid models.Money, at time.Time) models.Money {
+	totalDays := int(s.EndDate.Sub(s.StartDate).Hours() / 24)
+	if totalDays <= 0 {
+		return models.NewMoney(0)
+	}
+	usedDays := int(at.Sub(s.StartDate).Hours() / 24)
+	if usedDays < 0 {
+		usedDays = 0
+	}
+	if usedDays > totalDays {
+		usedDays = totalDays
+	}
+	return paid.MulRatio(int64(totalDays-usedDays), int64(totalDays))
+}
+
+// renewalRequest — параметры продления или смены тарифа
+type renewalRequest struct {
+	Type          string `json:"type"` // тариф нового периода; для продления по умолчанию текущий
+	PromoCode     string `json:"promo_code"`
+	PaymentMethod string `json:"payment_method"`
+	Paid          *bool  `json:"paid"`
+	AutoRenew     *bool  `json:"auto_renew"`
+}
+
+// renewSubscription создает следующий период абонемента, начинающийся в день окончания текущего.
+// Если абонемент уже истек, новый период начинается сегодня.
+func renewSubscription(tx *sql.Tx, prev models.Subscription, plan models.SubscriptionPlan, promoCode string, autoRenew bool, payment *models.Payment) (models.Subscription, error) {
+	if prev.Status == "cancelled" {
+		return models.Subscription{}, validationError("Отмененный абонемент нельзя продлить")
+	}
+
+	// Блокируем продлеваемый абонемент, чтобы параллельно не создать два продления
+	var renewed bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM subscriptions
+			WHERE previous_subscription_id = $1 AND change_kind = 'renewal'
+			AND deleted_at IS NULL AND status <> 'cancelled'
+		)
+		FROM subscriptions
+		WHERE id = $1
+		FOR UPDATE
+	`, prev.ID).Scan(&renewed)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	if renewed {
+		return models.Subscription{}, errAlreadyRenewed
+	}
+
+	startDate := prev.EndDate
+	if today := currentDate(); startDate.Before(today) {
+		startDate = today
+	}
+
+	quote, err := quoteSubscription(tx, prev.ClientID, plan, promoCode, 0)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	s := newSubscription(prev.ClientID, plan, startDate, quote)
+	s.PreviousID = &prev.ID
+	s.ChangeKind = "renewal"
+	s.AutoRenew = autoRenew
+	if err := sellSubscription(tx, &s, payment); err != nil {
+		return models.Subscription{}, err
+	}
+	return s, nil
+}
+
+// RenewSubscription продлевает абонемент на следующий период
+func RenewSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/subscriptions/%d/renew - продление абонемента", id)
+
+	var req renewalRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	prev, err := scanSubscription(database.DB.QueryRow(subscriptionSelect+` WHERE s.id = $1 AND s.deleted_at IS NULL`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Абонемент не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	planType := req.Type
+	if planType == "" {
+		planType = prev.Type
+	}
+	plan, ok := models.FindPlan(planType)
+	if !ok {
+		http.Error(w, "Неизвестный тип абонемента. Доступны: monthly, quarterly, yearly", http.StatusBadRequest)
+		return
+	}
+
+	// Маршрут доступен только администратору
+	payment, err := paymentFromRequest(req.PaymentMethod, req.Paid, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	autoRenew := prev.AutoRenew
+	if req.AutoRenew != nil {
+		autoRenew = *req.AutoRenew
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	s, err := renewSubscription(tx, prev, plan, req.PromoCode, autoRenew, payment)
+	if err != nil {
+		writeRenewalError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения продления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := issueInvoice(s.ID); err != nil {
+		log.Printf("Ошибка выставления счета по абонементу %d: %v", s.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+	log.Printf("Абонемент %d продлен, новый абонемент %d с %s", id, s.ID, s.StartDate.Format("2006-01-02"))
+}
+
+// UpgradeSubscription переводит действующий абонемент на более длительный тариф,
+// засчитывая оплаченную стоимость неиспользованных дней в цену нового. Если следующий период старого
+// абонемента уже продлен, продление переносится: оно начнется после окончания нового абонемента.
+func UpgradeSubscription(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/subscriptions/%d/upgrade - смена тарифа абонемента", id)
+
+	var req renewalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	newPlan, ok := models.FindPlan(req.Type)
+	if !ok {
+		http.Error(w, "Неизвестный тип абонемента. Доступны: monthly, quarterly, yearly", http.StatusBadRequest)
+		return
+	}
+	// Маршрут доступен только администратору
+	payment, err := paymentFromRequest(req.PaymentMethod, req.Paid, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	prev, err := scanSubscription(tx.QueryRow(subscriptionSelect+` WHERE s.id = $1 AND s.deleted_at IS NULL FOR UPDATE OF s`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Абонемент не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	today := currentDate()
+	if prev.Status != "active" || prev.EndDate.Before(today) {
+		http.Error(w, "Сменить тариф можно только у действующего абонемента", http.StatusBadRequest)
+		return
+	}
+	oldPlan, ok := models.FindPlan(prev.Type)
+	if ok && newPlan.Months <= oldPlan.Months {
+		http.Error(w, "Перейти можно только на более длительный тариф", http.StatusBadRequest)
+		return
+	}
+
+	quote, err := quoteSubscription(tx, prev.ClientID, newPlan, req.PromoCode, 0)
+	if err != nil {
+		writeRenewalError(w, err)
+		return
+	}
+
+	// Остаток текущего абонемента засчитывается как скидка на новый — в доле от фактически
+	// оплаченной суммы: неоплаченный абонемент ничего не дает в зачет
+	paid, err := paidAmount(tx, prev.ID)
+	if err != nil {
+		log.Printf("Ошибка расчета оплаты абонемента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	credit := models.MinMoney(unusedValue(prev, paid, today), quote.Total)
+	if credit.IsPositive() {
+		quote.Discounts = append(quote.Discounts, appliedDiscount{
+			Reason: fmt.Sprintf("Зачет остатка абонемента №%d", prev.ID),
+			Amount: credit,
+		})
+		quote.Total = quote.Total.Sub(credit)
+		quote.DiscountAmount = quote.BasePrice.Sub(quote.Total)
+	}
+
+	startDate := today
+	if prev.StartDate.After(today) {
+		startDate = prev.StartDate
+	}
+	s := newSubscription(prev.ClientID, newPlan, startDate, quote)
+	s.PreviousID = &prev.ID
+	s.ChangeKind = "upgrade"
+	s.AutoRenew = prev.AutoRenew
+	if req.AutoRenew != nil {
+		s.AutoRenew = *req.AutoRenew
+	}
+	if err := sellSubscription(tx, &s, payment); err != nil {
+		log.Printf("Ошибка создания абонемента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Старый абонемент закрывается в день перехода, а его неоплаченные платежи аннулируются,
+	// чтобы не числиться в задолженности по отмененному абонементу
+	_, err = tx.Exec(`
+		UPDATE subscriptions SET status = 'cancelled', end_date = $1, auto_renew = FALSE WHERE id = $2
+	`, startDate, prev.ID)
+	if err == nil {
+		_, err = tx.Exec(`
+			UPDATE payments SET status = 'failed',
+			       description = CONCAT_WS('. ', NULLIF(description, ''), $1::text)
+			WHERE subscription_id = $2 AND status = 'pending'
+		`, fmt.Sprintf("Аннулирован при переходе на абонемент №%d", s.ID), prev.ID)
+	}
+	if err != nil {
+		log.Printf("Ошибка закрытия абонемента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Уже оформленное продление старого абонемента пересекалось бы с новым —
+	// сдвигаем его на конец нового абонемента с той же длительностью
+	var movedRenewalID int
+	err = tx.QueryRow(`
+		UPDATE subscriptions
+		SET previous_subscription_id = $1, start_date = $2, end_date = $2::date + (end_date - start_date)
+		WHERE previous_subscription_id = $3 AND change_kind = 'renewal'
+		AND deleted_at IS NULL AND status <> 'cancelled'
+		RETURNING id
+	`, s.ID, s.EndDate, prev.ID).Scan(&movedRenewalID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Ошибка переноса продления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		log.Printf("Продление %d перенесено на %s после нового абонемента %d",
+			movedRenewalID, s.EndDate.Format("2006-01-02"), s.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения смены тарифа: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := issueInvoice(s.ID); err != nil {
+		log.Printf("Ошибка выставления счета по абонементу %d: %v", s.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+	log.Printf("Абонемент %d переведен на тариф %s (новый абонемент %d, зачтено %s)", id, newPlan.Code, s.ID, credit)
+}
+
+// SetSubscriptionAutoRenew включает или выключает автопродление абонемента
+func SetSubscriptionAutoRenew(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/subscriptions/%d/auto-renew - настройка автопродления", id)
+
+	var req struct {
+		AutoRenew bool `json:"auto_renew"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	ownerID, err := subscriptionOwner(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Абонемент не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка проверки владельца абонемента: %v", err)
+		http.Error(w, "Ошибка проверки абонемента", http.StatusInternalServerError)
+		return
+	}
+	if user.Role != "admin" && ownerID != user.ID {
+		http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		return
+	}
+
+	result, err := database.DB.Exec(`UPDATE subscriptions SET auto_renew = $1 WHERE id = $2 AND deleted_at IS NULL`, req.AutoRenew, id)
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Абонемент не найден", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	log.Printf("Автопродление абонемента %d: %v", id, req.AutoRenew)
+}
+
+func writeRenewalError(w http.ResponseWriter, err error) {
+	if _, ok := err.(validationError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == errAlreadyRenewed {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Ошибка продления абонемента: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// StartAutoRenewal запускает периодическое автопродление абонементов
+func StartAutoRenewal(interval time.Duration) {
+	go func() {
+		processAutoRenewals()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processAutoRenewals()
+		}
+	}()
+	log.Printf("Автопродление абонементов запущено (интервал %s)", interval)
+}
+
+// processAutoRenewals продлевает абонементы с автопродлением, которые заканчиваются в ближайшие дни.
+// Абонементы удаленных клиентов и пользователей не продлеваются.
+func processAutoRenewals() {
+	defer InvalidateStatsCache()
+	rows, err := database.DB.Query(subscriptionSelect+`
+		JOIN users u ON c.user_id = u.id
+		WHERE s.auto_renew AND s.status = 'active' AND s.deleted_at IS NULL
+		AND c.deleted_at IS NULL AND u.deleted_at IS NULL
+		AND s.end_date <= CURRENT_DATE + $1::int
+		AND NOT EXISTS (
+			SELECT 1 FROM subscriptions n
+			WHERE n.previous_subscription_id = s.id AND n.change_kind = 'renewal'
+			AND n.deleted_at IS NULL AND n.status <> 'cancelled'
+		)
+	`, getEnvInt("AUTO_RENEW_DAYS_BEFORE", 1))
+	if err != nil {
+		log.Printf("Автопродление: ошибка запроса: %v", err)
+		return
+	}
+	var due []models.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			log.Printf("Автопродление: ошибка сканирования: %v", err)
+			continue
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+
+	for _, s := range due {
+		autoRenewSubscription(s)
+	}
+}
+
+// autoRenewSubscription продлевает абонемент и списывает оплату через платежного провайдера.
+// Продление сначала сохраняется с ожидающим платежом, и только потом вызывается провайдер:
+// во время списания блокировки не держатся, а успешное списание всегда есть в журнале платежей.
+// Если провайдер не умеет списывать сам, платеж остается ожидающим.
+// При отказе в списании продление отменяется, а автопродление выключается.
+func autoRenewSubscription(prev models.Subscription) {
+	plan, ok := models.FindPlan(prev.Type)
+	if !ok {
+		log.Printf("Автопродление: у абонемента %d неизвестный тариф '%s'", prev.ID, prev.Type)
+		return
+	}
+	provider := currentPaymentProvider()
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Автопродление: ошибка начала транзакции: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	payment := &models.Payment{Method: "auto", Status: "pending", Provider: provider.Name()}
+	s, err := renewSubscription(tx, prev, plan, "", true, payment)
+	if err != nil {
+		if err != errAlreadyRenewed {
+			log.Printf("Автопродление абонемента %d: %v", prev.ID, err)
+		}
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Автопродление абонемента %d: ошибка сохранения: %v", prev.ID, err)
+		return
+	}
+
+	if s.Price.IsPositive() {
+		reference, err := provider.Charge(prev.ClientID, s.Price, payment.Description)
+		switch {
+		case err == errManualPayment:
+			log.Printf("Автопродление абонемента %d: ожидается оплата %s на ресепшене", prev.ID, s.Price)
+		case err != nil:
+			log.Printf("Автопродление абонемента %d: списание отклонено: %v", prev.ID, err)
+			if err := failAutoRenewal(prev, s, payment, err); err != nil {
+				log.Printf("Автопродление абонемента %d: ошибка отмены продления %d: %v", prev.ID, s.ID, err)
+			}
+			return
+		default:
+			if err := settleAutoRenewal(payment, reference); err != nil {
+				log.Printf("Автопродление абонемента %d: списание %s прошло, но платеж %d не отмечен оплаченным (требует сверки): %v",
+					prev.ID, reference, payment.ID, err)
+			}
+		}
+	}
+
+	if _, err := issueInvoice(s.ID); err != nil {
+		log.Printf("Ошибка выставления счета по абонементу %d: %v", s.ID, err)
+	}
+	log.Printf("Абонемент %d автоматически продлен, новый абонемент %d", prev.ID, s.ID)
+}
+
+// settleAutoRenewal отмечает платеж за автопродление оплаченным после успешного списания
+func settleAutoRenewal(payment *models.Payment, reference string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE payments SET status = 'paid', paid_at = NOW(), provider_reference = $1
+		WHERE id = $2 AND status = 'pending'
+	`, reference, payment.ID)
+	if err != nil {
+		return err
+	}
+	// Платеж успели подтвердить вручную — баллы за него уже начислены
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("платеж уже не ожидает оплаты")
+	}
+	payment.Status = "paid"
+	payment.ProviderReference = reference
+	if err := awardPurchasePoints(tx, *payment); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// failAutoRenewal отменяет продление, за которое не удалось списать оплату:
+// платеж помечается неуспешным, новый период отменяется, автопродление выключается
+func failAutoRenewal(prev, s models.Subscription, payment *models.Payment, chargeErr error) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE payments SET status = 'failed', description = $1 WHERE id = $2 AND status = 'pending'`,
+			[]interface{}{"Отказ в автопродлении: " + chargeErr.Error(), payment.ID}},
+		{`UPDATE subscriptions SET status = 'cancelled', cancelled_at = NOW(), cancellation_reason = $1 WHERE id = $2`,
+			[]interface{}{"Не удалось списать оплату автопродления", s.ID}},
+		{`UPDATE subscriptions SET auto_renew = FALSE WHERE id = $1`, []interface{}{prev.ID}},
+	}
+	for _, st := range statements {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
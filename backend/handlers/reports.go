@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Финансовые отчеты строятся по журналу платежей за период from–to (YYYY-MM-DD включительно,
+// по умолчанию — текущий месяц). Выручка — проведенные оплаты, возвраты — проведенные возвраты;
+// платеж относится к периоду по дате получения денег (paid_at), а не выставления.
+// Каждый отчет отдается в JSON или, с format=csv, в CSV для Excel.
+
+// reportResponse — общий формат JSON-ответа отчетов
+type reportResponse struct {
+	Report  string      `json:"report"`
+	From    string      `json:"from"`
+	To      string      `json:"to"` // включительно
+	Rows    interface{} `json:"rows"`
+	Summary interface{} `json:"summary,omitempty"`
+}
+
+// reportPeriod возвращает период отчета как полуинтервал [from, to+1); по умолчанию — с начала
+// текущего месяца по сегодня
+func reportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	today := currentDate()
+	return parsePeriod(r, time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC), today.AddDate(0, 0, 1))
+}
+
+// writeCSV отдает таблицу файлом CSV (разделитель «;», как ожидает Excel в русской локали)
+func writeCSV(w http.ResponseWriter, filename string, header []string, records [][]string) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	out := csv.NewWriter(w)
+	out.Comma = ';'
+	out.Write(header)
+	for _, record := range records {
+		safe := make([]string, len(record))
+		for i, cell := range record {
+			safe[i] = csvSafe(cell)
+		}
+		out.Write(safe)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvSafe не дает Excel выполнить ячейку как формулу: текст, начинающийся с =, +, -, @,
+// табуляции или перевода строки, получает префикс '. Числа (в том числе отрицательные) не меняются.
+func csvSafe(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// writeReport отдает отчет в формате из параметра format (json по умолчанию или csv)
+func writeReport(w http.ResponseWriter, r *http.Request, report reportResponse, header []string, records [][]string) {
+	if r.URL.Query().Get("format") == "csv" {
+		filename := fmt.Sprintf("%s-%s-%s.csv", report.Report, report.From, report.To)
+		if err := writeCSV(w, filename, header, records); err != nil {
+			log.Printf("Ошибка выгрузки отчета %s: %v", report.Report, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// newReport заполняет период отчета
+func newReport(name string, from, to time.Time) reportResponse {
+	return reportResponse{Report: name, From: from.Format("2006-01-02"), To: to.AddDate(0, 0, -1).Format("2006-01-02")}
+}
+
+// RevenueRow — выручка за период или по группе (тариф, способ оплаты)
+type RevenueRow struct {
+	Period   string       `json:"period,omitempty"` // начало дня, недели или месяца
+	Group    string       `json:"group,omitempty"`
+	Payments int          `json:"payments"`
+	Revenue  models.Money `json:"revenue"`
+	Refunds  models.Money `json:"refunds"`
+	Net      models.Money `json:"net"`
+}
+
+func (row RevenueRow) record(key string) []string {
+	return []string{key, strconv.Itoa(row.Payments), row.Revenue.String(), row.Refunds.String(), row.Net.String()}
+}
+
+// revenueTotal суммирует строки выручки
+func revenueTotal(rows []RevenueRow) RevenueRow {
+	total := RevenueRow{Revenue: models.NewMoney(0), Refunds: models.NewMoney(0), Net: models.NewMoney(0)}
+	for _, row := range rows {
+		total.Payments += row.Payments
+		total.Revenue = total.Revenue.Add(row.Revenue)
+		total.Refunds = total.Refunds.Add(row.Refunds)
+		total.Net = total.Net.Add(row.Net)
+	}
+	return total
+}
+
+// queryRevenueRows выполняет запрос, возвращающий группу, число оплат, выручку и возвраты
+func queryRevenueRows(query string, args ...interface{}) ([]RevenueRow, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]RevenueRow, 0)
+	for rows.Next() {
+		var row RevenueRow
+		if err := rows.Scan(&row.Group, &row.Payments, &row.Revenue, &row.Refunds); err != nil {
+			return nil, err
+		}
+		row.Net = row.Revenue.Sub(row.Refunds)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// GetRevenueReport возвращает выручку по дням, неделям или месяцам (group_by=day|week|month).
+// Периоды без платежей включаются с нулями.
+func GetRevenueReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/revenue - отчет о выручке")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if groupBy != "day" && groupBy != "week" && groupBy != "month" {
+		http.Error(w, "group_by должен быть day, week или month", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := queryRevenueRows(`
+		WITH periods AS (
+			SELECT generate_series(date_trunc($3, $1::timestamp), $2::timestamp - INTERVAL '1 day',
+			                       ('1 ' || $3)::interval)::date AS period
+		)
+		SELECT to_char(p.period, 'YYYY-MM-DD'),
+		       COUNT(pay.id) FILTER (WHERE pay.kind = 'payment'),
+		       COALESCE(SUM(pay.amount) FILTER (WHERE pay.kind = 'payment'), 0),
+		       COALESCE(SUM(pay.amount) FILTER (WHERE pay.kind = 'refund'), 0)
+		FROM periods p
+		LEFT JOIN payments pay ON pay.status = 'paid'
+			AND pay.paid_at >= $1 AND pay.paid_at < $2
+			AND date_trunc($3, pay.paid_at)::date = p.period
+		GROUP BY p.period
+		ORDER BY p.period
+	`, from, to, groupBy)
+	if err != nil {
+		log.Printf("Ошибка построения отчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range rows {
+		rows[i].Period, rows[i].Group = rows[i].Group, ""
+	}
+
+	report := newReport("revenue", from, to)
+	report.Rows = rows
+	report.Summary = revenueTotal(rows)
+
+	records := make([][]string, 0, len(rows)+1)
+	for _, row := range rows {
+		records = append(records, row.record(row.Period))
+	}
+	records = append(records, revenueTotal(rows).record("Итого"))
+	writeReport(w, r, report, []string{"Период", "Оплат", "Выручка", "Возвраты", "Итого"}, records)
+}
+
+// getRevenueBreakdown строит отчет о выручке с группировкой по выражению groupExpr
+func getRevenueBreakdown(w http.ResponseWriter, r *http.Request, name, groupTitle, groupExpr string) {
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := queryRevenueRows(`
+		SELECT `+groupExpr+`,
+		       COUNT(*) FILTER (WHERE pay.kind = 'payment'),
+		       COALESCE(SUM(pay.amount) FILTER (WHERE pay.kind = 'payment'), 0),
+		       COALESCE(SUM(pay.amount) FILTER (WHERE pay.kind = 'refund'), 0)
+		FROM payments pay
+		LEFT JOIN subscriptions s ON pay.subscription_id = s.id
+		WHERE pay.status = 'paid' AND pay.paid_at >= $1 AND pay.paid_at < $2
+		GROUP BY 1
+		ORDER BY 3 DESC
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка построения отчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := newReport(name, from, to)
+	report.Rows = rows
+	report.Summary = revenueTotal(rows)
+
+	records := make([][]string, 0, len(rows)+1)
+	for _, row := range rows {
+		records = append(records, row.record(row.Group))
+	}
+	records = append(records, revenueTotal(rows).record("Итого"))
+	writeReport(w, r, report, []string{groupTitle, "Оплат", "Выручка", "Возвраты", "Итого"}, records)
+}
+
+// GetRevenueByPlanReport возвращает выручку по тарифам абонементов
+func GetRevenueByPlanReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/revenue-by-plan - выручка по тарифам")
+	getRevenueBreakdown(w, r, "revenue-by-plan", "Тариф", `COALESCE(s.type, 'Без абонемента')`)
+}
+
+// GetRevenueByMethodReport возвращает выручку по способам оплаты
+func GetRevenueByMethodReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/revenue-by-method - выручка по способам оплаты")
+	getRevenueBreakdown(w, r, "revenue-by-method", "Способ оплаты", `pay.method`)
+}
+
+// MemberRevenueSummary — средняя выручка на клиента за период
+type MemberRevenueSummary struct {
+	NetRevenue      models.Money `json:"net_revenue"`
+	PayingMembers   int          `json:"paying_members"` // клиенты с оплатой в периоде
+	ActiveMembers   int          `json:"active_members"` // клиенты с абонементом, действовавшим в периоде
+	PerPayingMember models.Money `json:"per_paying_member"`
+	PerActiveMember models.Money `json:"per_active_member"`
+}
+
+// GetMemberRevenueReport возвращает среднюю выручку на клиента: на заплативших в периоде
+// и на всех, у кого в периоде действовал абонемент
+func GetMemberRevenueReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/member-revenue - средняя выручка на клиента")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var s MemberRevenueSummary
+	var revenue, refunds models.Money
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(SUM(amount) FILTER (WHERE kind = 'payment'), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE kind = 'refund'), 0),
+		       COUNT(DISTINCT client_id) FILTER (WHERE kind = 'payment'),
+		       (SELECT COUNT(DISTINCT s.client_id) FROM subscriptions s
+		        JOIN clients c ON s.client_id = c.id
+		        WHERE s.start_date < $2 AND s.end_date >= $1 AND s.status <> 'cancelled'
+		        AND s.deleted_at IS NULL AND c.deleted_at IS NULL)
+		FROM payments
+		WHERE status = 'paid' AND paid_at >= $1 AND paid_at < $2
+	`, from, to).Scan(&revenue, &refunds, &s.PayingMembers, &s.ActiveMembers)
+	if err != nil {
+		log.Printf("Ошибка построения отчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.NetRevenue = revenue.Sub(refunds)
+	s.PerPayingMember = s.NetRevenue.MulRatio(1, int64(s.PayingMembers))
+	s.PerActiveMember = s.NetRevenue.MulRatio(1, int64(s.ActiveMembers))
+
+	report := newReport("member-revenue", from, to)
+	report.Rows = []MemberRevenueSummary{s}
+	writeReport(w, r, report,
+		[]string{"Чистая выручка", "Заплативших клиентов", "Активных клиентов", "На заплатившего", "На активного"},
+		[][]string{{s.NetRevenue.String(), strconv.Itoa(s.PayingMembers), strconv.Itoa(s.ActiveMembers),
+			s.PerPayingMember.String(), s.PerActiveMember.String()}})
+}
+
+// PaymentReportRow — платеж в отчетах о задолженностях и возвратах
+type PaymentReportRow struct {
+	PaymentID    int          `json:"payment_id"`
+	ClientID     *int         `json:"client_id,omitempty"`
+	ClientName   string       `json:"client_name"`
+	Subscription string       `json:"subscription,omitempty"`
+	Amount       models.Money `json:"amount"`
+	Method       string       `json:"method"`
+	CreatedAt    time.Time    `json:"created_at"`
+	AgeDays      int          `json:"age_days,omitempty"` // для задолженностей: дней с выставления до конца периода
+	Reason       string       `json:"reason,omitempty"`   // для возвратов: причина отмены абонемента
+}
+
+// PaymentReportSummary — итог отчета по платежам
+type PaymentReportSummary struct {
+	Count int          `json:"count"`
+	Total models.Money `json:"total"`
+}
+
+// getPaymentsReport строит отчет по платежам вида kind со статусом status. Проведенные платежи
+// отбираются по дате оплаты в периоде. Для ожидающих (status = pending) отчет строится на конец
+// периода: все выставленные до to и еще не оплаченные к этому моменту, независимо от from.
+func getPaymentsReport(w http.ResponseWriter, r *http.Request, name, kind, status string) {
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := `pay.status = 'paid' AND pay.paid_at >= $2 AND pay.paid_at < $3`
+	args := []interface{}{kind, from, to}
+	if status == "pending" {
+		filter = `pay.created_at < $2
+			AND (pay.status = 'pending' OR (pay.status = 'paid' AND pay.paid_at >= $2))`
+		args = []interface{}{kind, to}
+	}
+	rows, err := database.DB.Query(`
+		SELECT pay.id, pay.client_id, COALESCE(u.name, ''), COALESCE(s.type, ''), pay.amount, pay.method,
+		       pay.created_at, COALESCE(s.cancellation_reason, '')
+		FROM payments pay
+		LEFT JOIN clients c ON pay.client_id = c.id
+		LEFT JOIN users u ON c.user_id = u.id
+		LEFT JOIN subscriptions s ON pay.subscription_id = s.id
+		WHERE pay.kind = $1 AND `+filter+`
+		ORDER BY pay.created_at
+	`, args...)
+	if err != nil {
+		log.Printf("Ошибка построения отчета: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Возраст задолженности считается на конец периода, для текущего периода — на сегодня
+	asOf := time.Now()
+	if to.Before(asOf) {
+		asOf = to
+	}
+	payments := make([]PaymentReportRow, 0)
+	summary := PaymentReportSummary{Total: models.NewMoney(0)}
+	records := [][]string{}
+	for rows.Next() {
+		var p PaymentReportRow
+		err := rows.Scan(&p.PaymentID, &p.ClientID, &p.ClientName, &p.Subscription, &p.Amount, &p.Method,
+			&p.CreatedAt, &p.Reason)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		if status == "pending" {
+			p.AgeDays = int(asOf.Sub(p.CreatedAt).Hours() / 24)
+			p.Reason = ""
+		}
+		payments = append(payments, p)
+		summary.Count++
+		summary.Total = summary.Total.Add(p.Amount)
+		records = append(records, []string{strconv.Itoa(p.PaymentID), p.ClientName, p.Subscription, p.Amount.String(),
+			p.Method, p.CreatedAt.Format("2006-01-02 15:04"), strconv.Itoa(p.AgeDays), p.Reason})
+	}
+	records = append(records, []string{"Итого", "", "", summary.Total.String(), "", "", "", ""})
+
+	report := newReport(name, from, to)
+	report.Rows = payments
+	report.Summary = summary
+	writeReport(w, r, report,
+		[]string{"Платеж", "Клиент", "Абонемент", "Сумма", "Способ", "Дата", "Дней", "Причина"}, records)
+}
+
+// GetOutstandingPaymentsReport возвращает платежи, не оплаченные на конец периода
+func GetOutstandingPaymentsReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/outstanding - неоплаченные платежи")
+	getPaymentsReport(w, r, "outstanding", "payment", "pending")
+}
+
+// GetRefundsReport возвращает проведенные в периоде возвраты
+func GetRefundsReport(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/reports/refunds - возвраты")
+	getPaymentsReport(w, r, "refunds", "refund", "paid")
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"Иванов", "Иванов"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+7 999 123-45-67", "'+7 999 123-45-67"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tтекст", "'\tтекст"},
+		{"\rтекст", "'\rтекст"},
+		{"-1500.00", "-1500.00"},
+		{"+15", "+15"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvSafe(tt.in); got != tt.want {
+			t.Errorf("csvSafe(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"database/sql"
+	"fitness-club/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Удержание клиентов считается по абонементам: клиент — активный член клуба в дни, покрытые
+// хотя бы одним оплаченным абонементом. Бесплатные абонементы и проданные до появления журнала
+// платежей (без записей в нем) считаются оплаченными; выставленный, но не оплаченный абонемент
+// членства не дает. Отмененный абонемент покрывает дни до даты отмены. Перерыв
+// не длиннее RETENTION_GRACE_DAYS (по умолчанию 7) между абонементами оттоком не считается.
+
+// membershipCoverage — дни, покрытые оплаченными абонементами неудаленных клиентов
+const membershipCoverage = `
+	SELECT s.client_id, s.type, s.start_date,
+	       CASE WHEN s.status = 'cancelled' AND s.cancelled_at IS NOT NULL
+	            THEN LEAST(s.end_date, s.cancelled_at::date) ELSE s.end_date END AS end_date
+	FROM subscriptions s
+	JOIN clients c ON s.client_id = c.id
+	WHERE s.deleted_at IS NULL AND c.deleted_at IS NULL
+	AND (s.price = 0
+	     OR EXISTS(SELECT 1 FROM payments p WHERE p.subscription_id = s.id AND p.kind = 'payment' AND p.status = 'paid')
+	     OR NOT EXISTS(SELECT 1 FROM payments p WHERE p.subscription_id = s.id AND p.kind = 'payment'))
+`
+
+// ChurnRow — отток за месяц
+type ChurnRow struct {
+	Month       string   `json:"month"` // YYYY-MM
+	ActiveStart int      `json:"active_start"`
+	NewMembers  int      `json:"new_members"` // первый абонемент в этом месяце
+	Churned     int      `json:"churned"`     // были активны на начало месяца и не продлили
+	ChurnRate   *float64 `json:"churn_rate"`
+	ActiveEnd   int      `json:"active_end"`
+	Complete    bool     `json:"complete"` // месяц и период ожидания продления закончились
+}
+
+// GetChurnStats возвращает отток по месяцам: долю клиентов, активных на начало месяца,
+// у которых нет абонемента на начало следующего месяца и которые не вернулись в период ожидания
+func GetChurnStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/churn - отток клиентов по месяцам")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	grace := getEnvInt("RETENTION_GRACE_DAYS", 7)
+
+	rows, err := database.DB.Query(`
+		WITH cover AS (`+membershipCoverage+`),
+		months AS (
+			SELECT m::date AS month, (m + INTERVAL '1 month')::date AS next
+			FROM generate_series(date_trunc('month', $1::timestamp), $2::timestamp - INTERVAL '1 day', INTERVAL '1 month') m
+		)
+		SELECT to_char(month, 'YYYY-MM'),
+		       (SELECT COUNT(DISTINCT client_id) FROM cover WHERE start_date <= month AND end_date >= month),
+		       (SELECT COUNT(*) FROM (
+		            SELECT client_id FROM cover GROUP BY client_id
+		            HAVING MIN(start_date) >= month AND MIN(start_date) < next
+		        ) n),
+		       (SELECT COUNT(DISTINCT a.client_id) FROM cover a
+		        WHERE a.start_date <= month AND a.end_date >= month
+		        AND NOT EXISTS (
+		            SELECT 1 FROM cover b
+		            WHERE b.client_id = a.client_id AND b.start_date < next + $3::int AND b.end_date >= next
+		        )),
+		       (SELECT COUNT(DISTINCT client_id) FROM cover WHERE start_date < next AND end_date >= next - 1),
+		       next + $3::int <= CURRENT_DATE
+		FROM months
+		ORDER BY month
+	`, from, to, grace)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]ChurnRow, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var c ChurnRow
+		if err := rows.Scan(&c.Month, &c.ActiveStart, &c.NewMembers, &c.Churned, &c.ActiveEnd, &c.Complete); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		c.ChurnRate = percent(c.Churned, c.ActiveStart)
+		result = append(result, c)
+		records = append(records, []string{c.Month, strconv.Itoa(c.ActiveStart), strconv.Itoa(c.NewMembers),
+			strconv.Itoa(c.Churned), formatPercent(c.ChurnRate), strconv.Itoa(c.ActiveEnd), strconv.FormatBool(c.Complete)})
+	}
+
+	report := newReport("churn", from, to)
+	report.Rows = result
+	writeReport(w, r, report, []string{"Месяц", "Активных на начало", "Новых", "Ушли", "Отток, %",
+		"Активных на конец", "Месяц завершен"}, records)
+}
+
+// RenewalRow — продления абонементов тарифа
+type RenewalRow struct {
+	Plan        string   `json:"plan"`
+	Ended       int      `json:"ended"` // абонементы, закончившиеся в периоде
+	Renewed     int      `json:"renewed"`
+	RenewalRate *float64 `json:"renewal_rate"`
+}
+
+// GetRenewalStats возвращает долю продлений по тарифам среди абонементов, закончившихся в периоде.
+// Продлением считается следующий абонемент клиента, начатый не позже RETENTION_GRACE_DAYS
+// после окончания; абонементы, у которых период ожидания еще идет, не учитываются.
+func GetRenewalStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/renewals - продления по тарифам")
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	grace := getEnvInt("RETENTION_GRACE_DAYS", 7)
+
+	rows, err := database.DB.Query(`
+		WITH cover AS (`+membershipCoverage+`)
+		SELECT a.type, COUNT(*),
+		       COUNT(*) FILTER (WHERE EXISTS (
+		           SELECT 1 FROM cover b
+		           WHERE b.client_id = a.client_id AND b.start_date > a.start_date
+		           AND b.start_date <= a.end_date + $3::int
+		       ))
+		FROM cover a
+		WHERE a.end_date >= $1 AND a.end_date < $2 AND a.end_date + $3::int < CURRENT_DATE
+		GROUP BY a.type
+		ORDER BY COUNT(*) DESC
+	`, from, to, grace)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := make([]RenewalRow, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var row RenewalRow
+		if err := rows.Scan(&row.Plan, &row.Ended, &row.Renewed); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		row.RenewalRate = percent(row.Renewed, row.Ended)
+		result = append(result, row)
+		records = append(records, []string{row.Plan, strconv.Itoa(row.Ended), strconv.Itoa(row.Renewed),
+			formatPercent(row.RenewalRate)})
+	}
+
+	report := newReport("renewals", from, to)
+	report.Rows = result
+	writeReport(w, r, report, []string{"Тариф", "Закончилось", "Продлено", "Продления, %"}, records)
+}
+
+// CohortRow — удержание клиентов, зарегистрировавшихся в одном месяце
+type CohortRow struct {
+	Cohort    string     `json:"cohort"` // месяц регистрации, YYYY-MM
+	Size      int        `json:"size"`
+	Active    []int      `json:"active"`    // активных в месяц 0, 1, 2... после регистрации
+	Retention []*float64 `json:"retention"` // то же в процентах от размера когорты
+}
+
+// monthIndex возвращает порядковый номер месяца для вычисления разницы между месяцами
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// GetCohortStats возвращает таблицу удержания по месяцу регистрации (users.created_at) клиентов,
+// зарегистрированных в периоде, и сколько из них были активны в каждый следующий месяц.
+// Без from берутся 12 месяцев, последний из которых — месяц to (по умолчанию текущий);
+// без to период продолжается до сегодняшнего дня.
+func GetCohortStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/cohorts - когорты удержания")
+
+	from, to, err := parsePeriod(r, time.Time{}, currentDate().AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.IsZero() {
+		last := to.AddDate(0, 0, -1)
+		from = time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -11, 0)
+	}
+
+	rows, err := database.DB.Query(`
+		WITH cover AS (`+membershipCoverage+`),
+		members AS (
+			SELECT c.id AS client_id, date_trunc('month', u.created_at)::date AS cohort
+			FROM clients c
+			JOIN users u ON c.user_id = u.id
+			WHERE c.deleted_at IS NULL AND u.created_at >= $1 AND u.created_at < $2
+		)
+		SELECT m.cohort, m.client_id, active.month
+		FROM members m
+		LEFT JOIN LATERAL (
+			SELECT DISTINCT date_trunc('month', d)::date AS month
+			FROM cover s,
+			     generate_series(date_trunc('month', s.start_date::timestamp), s.end_date::timestamp, INTERVAL '1 month') d
+			WHERE s.client_id = m.client_id AND d <= CURRENT_DATE
+		) active ON TRUE
+		ORDER BY m.cohort
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	current := monthIndex(currentDate())
+	cohorts := []*CohortRow{}
+	byMonth := map[string]*CohortRow{}
+	counted := map[int]bool{}
+	for rows.Next() {
+		var cohortMonth time.Time
+		var clientID int
+		var activeMonth sql.NullTime
+		if err := rows.Scan(&cohortMonth, &clientID, &activeMonth); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		key := cohortMonth.Format("2006-01")
+		c, ok := byMonth[key]
+		if !ok {
+			c = &CohortRow{Cohort: key, Active: make([]int, current-monthIndex(cohortMonth)+1)}
+			byMonth[key] = c
+			cohorts = append(cohorts, c)
+		}
+		if !counted[clientID] {
+			counted[clientID] = true
+			c.Size++
+		}
+		if activeMonth.Valid {
+			if offset := monthIndex(activeMonth.Time) - monthIndex(cohortMonth); offset >= 0 && offset < len(c.Active) {
+				c.Active[offset]++
+			}
+		}
+	}
+
+	result := make([]CohortRow, 0, len(cohorts))
+	maxOffsets := 0
+	for _, c := range cohorts {
+		c.Retention = make([]*float64, len(c.Active))
+		for i, n := range c.Active {
+			c.Retention[i] = percent(n, c.Size)
+		}
+		if len(c.Active) > maxOffsets {
+			maxOffsets = len(c.Active)
+		}
+		result = append(result, *c)
+	}
+
+	header := []string{"Когорта", "Клиентов"}
+	for i := 0; i < maxOffsets; i++ {
+		header = append(header, "Месяц "+strconv.Itoa(i)+", %")
+	}
+	records := make([][]string, 0, len(result))
+	for _, c := range result {
+		record := []string{c.Cohort, strconv.Itoa(c.Size)}
+		for _, p := range c.Retention {
+			record = append(record, formatPercent(p))
+		}
+		records = append(records, record)
+	}
+
+	report := newReport("cohorts", from, to)
+	report.Rows = result
+	writeReport(w, r, report, header, records)
+}
+
+// AtRiskMember — клиент с действующим абонементом, которого клуб может потерять
+type AtRiskMember struct {
+	ClientID       int        `json:"client_id"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	Phone          string     `json:"phone,omitempty"`
+	Plan           string     `json:"plan"`
+	EndDate        time.Time  `json:"end_date"`
+	DaysLeft       int        `json:"days_left"`
+	LastVisit      *time.Time `json:"last_visit,omitempty"`
+	DaysSinceVisit *int       `json:"days_since_visit,omitempty"` // без посещений — не заполняется
+	Reasons        []string   `json:"reasons"`                    // no_visits, expiring
+}
+
+// GetAtRiskMembers возвращает клиентов с действующим абонементом, которые не приходили
+// no_visit_days дней (по умолчанию RISK_NO_VISIT_DAYS, 14) или у которых абонемент заканчивается
+// в ближайшие expiring_days дней (RISK_EXPIRING_DAYS, 14) без продления и автопродления
+func GetAtRiskMembers(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats/at-risk - клиенты в зоне риска")
+
+	params := map[string]int{
+		"no_visit_days": getEnvInt("RISK_NO_VISIT_DAYS", 14),
+		"expiring_days": getEnvInt("RISK_EXPIRING_DAYS", 14),
+	}
+	for name := range params {
+		if value := r.URL.Query().Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "Неверное значение "+name, http.StatusBadRequest)
+				return
+			}
+			params[name] = n
+		}
+	}
+
+	rows, err := database.DB.Query(`
+		WITH active_sub AS (
+			SELECT DISTINCT ON (s.client_id) s.client_id, s.type, s.start_date, s.end_date, s.auto_renew
+			FROM subscriptions s
+			WHERE s.status = 'active' AND s.deleted_at IS NULL
+			AND s.start_date <= CURRENT_DATE AND s.end_date >= CURRENT_DATE
+			ORDER BY s.client_id, s.end_date DESC
+		),
+		member AS (
+			SELECT c.id, u.name, u.email, COALESCE(c.phone, '') AS phone, cur.type, cur.end_date,
+			       (SELECT MAX(v.checked_in_at) FROM visits v WHERE v.client_id = c.id) AS last_visit,
+			       cur.end_date <= CURRENT_DATE + $2::int AND NOT cur.auto_renew AND NOT EXISTS (
+			           SELECT 1 FROM subscriptions n
+			           WHERE n.client_id = c.id AND n.deleted_at IS NULL AND n.status = 'active'
+			           AND n.start_date > CURRENT_DATE
+			       ) AS expiring,
+			       GREATEST(cur.start_date, c.created_at::date) AS member_since
+			FROM clients c
+			JOIN users u ON c.user_id = u.id
+			JOIN active_sub cur ON cur.client_id = c.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT id, name, email, phone, type, end_date, last_visit, expiring,
+		       COALESCE(last_visit::date, member_since) <= CURRENT_DATE - $1::int AS no_visits
+		FROM member
+		WHERE expiring OR COALESCE(last_visit::date, member_since) <= CURRENT_DATE - $1::int
+		ORDER BY end_date, name
+	`, params["no_visit_days"], params["expiring_days"])
+	if err != nil {
+		log.Printf("Ошибка построения статистики: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	today := currentDate()
+	members := make([]AtRiskMember, 0)
+	records := [][]string{}
+	for rows.Next() {
+		var m AtRiskMember
+		var lastVisit sql.NullTime
+		var expiring, noVisits bool
+		err := rows.Scan(&m.ClientID, &m.Name, &m.Email, &m.Phone, &m.Plan, &m.EndDate, &lastVisit, &expiring, &noVisits)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		m.DaysLeft = int(m.EndDate.Sub(today).Hours() / 24)
+		lastVisitText := ""
+		if lastVisit.Valid {
+			m.LastVisit = &lastVisit.Time
+			days := int(time.Since(lastVisit.Time).Hours() / 24)
+			m.DaysSinceVisit = &days
+			lastVisitText = lastVisit.Time.Format("2006-01-02")
+		}
+		m.Reasons = []string{}
+		if noVisits {
+			m.Reasons = append(m.Reasons, "no_visits")
+		}
+		if expiring {
+			m.Reasons = append(m.Reasons, "expiring")
+		}
+		members = append(members, m)
+		records = append(records, []string{strconv.Itoa(m.ClientID), m.Name, m.Email, m.Phone, m.Plan,
+			m.EndDate.Format("2006-01-02"), strconv.Itoa(m.DaysLeft), lastVisitText, strings.Join(m.Reasons, ", ")})
+	}
+
+	report := newReport("at-risk", today, today.AddDate(0, 0, 1))
+	report.Rows = members
+	writeReport(w, r, report, []string{"Клиент", "Имя", "Email", "Телефон", "Тариф", "Окончание", "Дней осталось",
+		"Последнее посещение", "Причины"}, records)
+}
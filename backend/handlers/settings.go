@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Настройки клуба читаются из переменных окружения (.env загружается в database.InitDB)
+
+func getEnv(key, defaultValue string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
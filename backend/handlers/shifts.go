@@ -0,0 +1,620 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// shiftRoles — роли сотрудника на смене
+var shiftRoles = map[string]bool{"reception": true, "trainer": true, "cleaner": true, "manager": true, "other": true}
+
+const shiftRolesHint = "Неизвестная роль. Доступны: reception, trainer, cleaner, manager, other"
+
+// employeeName возвращает имя сотрудника; sql.ErrNoRows — сотрудника нет
+func employeeName(q queryer, employeeID int) (string, error) {
+	var name string
+	err := q.QueryRow(`
+		SELECT u.name FROM employees e JOIN users u ON e.user_id = u.id WHERE e.id = $1
+	`, employeeID).Scan(&name)
+	return name, err
+}
+
+// shiftOverlaps проверяет, есть ли у сотрудника другая смена, пересекающаяся с интервалом
+func shiftOverlaps(q queryer, employeeID int, start, end time.Time, excludeID int) (bool, error) {
+	var overlaps bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM shifts
+			WHERE employee_id = $1 AND id <> $2 AND start_time < $4 AND end_time > $3
+		)
+	`, employeeID, excludeID, start, end).Scan(&overlaps)
+	return overlaps, err
+}
+
+// parseWeekStart разбирает начало недели (понедельник) в формате YYYY-MM-DD
+func parseWeekStart(value string) (time.Time, error) {
+	weekStart, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return weekStart, validationError("Неверный формат даты, ожидается YYYY-MM-DD")
+	}
+	if weekStart.Weekday() != time.Monday {
+		return weekStart, validationError("Неделя должна начинаться с понедельника")
+	}
+	return weekStart, nil
+}
+
+const rotaColumns = `
+	re.id, re.employee_id, u.name, re.weekday, to_char(re.start_time, 'HH24:MI'),
+	to_char(re.end_time, 'HH24:MI'), re.role, re.created_at
+`
+
+func scanRotaEntry(row interface{ Scan(...interface{}) error }) (models.RotaEntry, error) {
+	var e models.RotaEntry
+	err := row.Scan(&e.ID, &e.EmployeeID, &e.EmployeeName, &e.Weekday, &e.StartTime, &e.EndTime, &e.Role, &e.CreatedAt)
+	return e, err
+}
+
+// GetRota возвращает недельный график; employee_id — только смены одного сотрудника
+func GetRota(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/rota - получение недельного графика")
+
+	query := `
+		SELECT ` + rotaColumns + `
+		FROM rota_entries re
+		JOIN employees e ON re.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE ($1 = '' OR re.employee_id = NULLIF($1, '')::int)
+		ORDER BY re.weekday, re.start_time, u.name
+	`
+	employeeID := r.URL.Query().Get("employee_id")
+	if _, err := strconv.Atoi(employeeID); employeeID != "" && err != nil {
+		http.Error(w, "Неверный employee_id", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(query, employeeID)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]models.RotaEntry, 0)
+	for rows.Next() {
+		e, err := scanRotaEntry(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// CreateRotaEntry добавляет повторяющуюся смену в недельный график
+func CreateRotaEntry(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/rota - добавление смены в недельный график")
+
+	var e models.RotaEntry
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Weekday < 1 || e.Weekday > 7 {
+		http.Error(w, "weekday должен быть от 1 (понедельник) до 7 (воскресенье)", http.StatusBadRequest)
+		return
+	}
+	if !shiftRoles[e.Role] {
+		http.Error(w, shiftRolesHint, http.StatusBadRequest)
+		return
+	}
+	start, errStart := time.Parse("15:04", e.StartTime)
+	end, errEnd := time.Parse("15:04", e.EndTime)
+	if errStart != nil || errEnd != nil {
+		http.Error(w, "Неверный формат времени, ожидается HH:MM", http.StatusBadRequest)
+		return
+	}
+	if !end.After(start) {
+		http.Error(w, "Смена должна заканчиваться позже, чем начинается", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := employeeName(database.DB, e.EmployeeID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Сотрудник не найден", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanRotaEntry(database.DB.QueryRow(`
+		WITH re AS (
+			INSERT INTO rota_entries (employee_id, weekday, start_time, end_time, role)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING *
+		)
+		SELECT `+rotaColumns+`
+		FROM re JOIN employees e ON re.employee_id = e.id JOIN users u ON e.user_id = u.id
+	`, e.EmployeeID, e.Weekday, e.StartTime, e.EndTime, e.Role))
+	if err != nil {
+		log.Printf("Ошибка добавления смены в график: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("В недельный график добавлена смена %d сотрудника %d", created.ID, created.EmployeeID)
+}
+
+// DeleteRotaEntry удаляет смену из недельного графика; уже созданные по ней смены остаются
+func DeleteRotaEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/rota/%d - удаление смены из недельного графика", id)
+
+	result, err := database.DB.Exec(`DELETE FROM rota_entries WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Смена графика не найдена", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyRotaResponse — результат заполнения недели сменами по графику
+type applyRotaResponse struct {
+	Created []models.Shift `json:"created"`
+	Skipped int            `json:"skipped"` // пересекаются с уже существующими сменами
+}
+
+// ApplyRota создает смены на неделю week_start (понедельник, YYYY-MM-DD) по недельному графику.
+// Смены, пересекающиеся с уже назначенными, пропускаются, поэтому повторный запуск безопасен.
+func ApplyRota(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/rota/apply - создание смен по недельному графику")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		WeekStart string `json:"week_start"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	weekStart, err := parseWeekStart(req.WeekStart)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT ` + rotaColumns + `
+		FROM rota_entries re
+		JOIN employees e ON re.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		ORDER BY re.weekday, re.start_time
+	`)
+	if err != nil {
+		log.Printf("Ошибка запроса графика: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var entries []models.RotaEntry
+	for rows.Next() {
+		e, err := scanRotaEntry(rows)
+		if err != nil {
+			rows.Close()
+			log.Printf("Ошибка сканирования: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+
+	resp := applyRotaResponse{Created: []models.Shift{}}
+	for _, e := range entries {
+		day := weekStart.AddDate(0, 0, e.Weekday-1).Format("2006-01-02")
+		start, _ := time.Parse("2006-01-02 15:04", day+" "+e.StartTime)
+		end, _ := time.Parse("2006-01-02 15:04", day+" "+e.EndTime)
+
+		overlaps, err := shiftOverlaps(tx, e.EmployeeID, start, end, 0)
+		if err != nil {
+			log.Printf("Ошибка проверки пересечения смен: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if overlaps {
+			resp.Skipped++
+			continue
+		}
+
+		s, err := scanShift(tx.QueryRow(`
+			WITH s AS (
+				INSERT INTO shifts (employee_id, role, start_time, end_time, rota_entry_id, created_by)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING *
+			)
+			SELECT `+shiftColumns+` FROM s JOIN employees e ON s.employee_id = e.id JOIN users u ON e.user_id = u.id
+		`, e.EmployeeID, e.Role, start, end, e.ID, user.ID))
+		if err != nil {
+			log.Printf("Ошибка создания смены: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Created = append(resp.Created, s)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	log.Printf("По графику на неделю с %s создано смен: %d, пропущено: %d", req.WeekStart, len(resp.Created), resp.Skipped)
+}
+
+const shiftColumns = `
+	s.id, s.employee_id, u.name, s.role, s.start_time, s.end_time, s.rota_entry_id, COALESCE(s.notes, ''), s.created_at
+`
+
+func scanShift(row interface{ Scan(...interface{}) error }) (models.Shift, error) {
+	var s models.Shift
+	var rotaEntryID sql.NullInt64
+	err := row.Scan(&s.ID, &s.EmployeeID, &s.EmployeeName, &s.Role, &s.StartTime, &s.EndTime, &rotaEntryID,
+		&s.Notes, &s.CreatedAt)
+	if err != nil {
+		return s, err
+	}
+	if rotaEntryID.Valid {
+		id := int(rotaEntryID.Int64)
+		s.RotaEntryID = &id
+	}
+	return s, nil
+}
+
+// GetShifts возвращает смены за период from–to (YYYY-MM-DD, по умолчанию текущая неделя) с фильтром employee_id
+func GetShifts(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/shifts - получение смен")
+
+	from, to, err := periodFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	args := []interface{}{from, to}
+	query := `
+		SELECT ` + shiftColumns + `
+		FROM shifts s
+		JOIN employees e ON s.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		WHERE s.start_time >= $1 AND s.start_time < $2`
+	if employeeID := r.URL.Query().Get("employee_id"); employeeID != "" {
+		id, err := strconv.Atoi(employeeID)
+		if err != nil {
+			http.Error(w, "Неверный employee_id", http.StatusBadRequest)
+			return
+		}
+		args = append(args, id)
+		query += " AND s.employee_id = $3"
+	}
+	query += " ORDER BY s.start_time, u.name"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	shifts := make([]models.Shift, 0)
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		shifts = append(shifts, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shifts)
+	log.Printf("Возвращено смен: %d", len(shifts))
+}
+
+// periodFromQuery читает from и to (YYYY-MM-DD, to включительно) и возвращает полуинтервал [from, to+1).
+// По умолчанию — текущая неделя с понедельника.
+func periodFromQuery(r *http.Request) (time.Time, time.Time, error) {
+	today := currentDate()
+	from := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	return parsePeriod(r, from, from.AddDate(0, 0, 7))
+}
+
+// parsePeriod читает from и to из запроса; незаданная граница берется из from или to по умолчанию
+func parsePeriod(r *http.Request, from, to time.Time) (time.Time, time.Time, error) {
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return from, to, validationError("Неверный формат from, ожидается YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return from, to, validationError("Неверный формат to, ожидается YYYY-MM-DD")
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return from, to, validationError("Дата from должна быть не позже to")
+	}
+	return from, to, nil
+}
+
+// validateShift проверяет смену и пересечение с другими сменами сотрудника
+func validateShift(s *models.Shift, excludeID int) error {
+	s.Notes = strings.TrimSpace(s.Notes)
+	if !shiftRoles[s.Role] {
+		return validationError(shiftRolesHint)
+	}
+	if s.StartTime.IsZero() || !s.EndTime.After(s.StartTime) {
+		return validationError("Смена должна заканчиваться позже, чем начинается")
+	}
+	if s.EndTime.Sub(s.StartTime) > 24*time.Hour {
+		return validationError("Смена не может быть длиннее суток")
+	}
+	if _, err := employeeName(database.DB, s.EmployeeID); err != nil {
+		if err == sql.ErrNoRows {
+			return validationError("Сотрудник не найден")
+		}
+		return err
+	}
+	overlaps, err := shiftOverlaps(database.DB, s.EmployeeID, s.StartTime, s.EndTime, excludeID)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return validationError("Смена пересекается с другой сменой сотрудника")
+	}
+	return nil
+}
+
+// CreateShift назначает смену сотруднику
+func CreateShift(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/shifts - назначение смены")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var s models.Shift
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateShift(&s, 0); err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка проверки смены: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	created, err := scanShift(database.DB.QueryRow(`
+		WITH s AS (
+			INSERT INTO shifts (employee_id, role, start_time, end_time, notes, created_by)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+			RETURNING *
+		)
+		SELECT `+shiftColumns+` FROM s JOIN employees e ON s.employee_id = e.id JOIN users u ON e.user_id = u.id
+	`, s.EmployeeID, s.Role, s.StartTime, s.EndTime, s.Notes, user.ID))
+	if err != nil {
+		log.Printf("Ошибка создания смены: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Назначена смена %d сотруднику %d", created.ID, created.EmployeeID)
+}
+
+// UpdateShift переносит смену или меняет роль и комментарий
+func UpdateShift(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/shifts/%d - изменение смены", id)
+
+	var s models.Shift
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = database.DB.QueryRow(`SELECT employee_id FROM shifts WHERE id = $1`, id).Scan(&s.EmployeeID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Смена не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := validateShift(&s, id); err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка проверки смены: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := scanShift(database.DB.QueryRow(`
+		WITH s AS (
+			UPDATE shifts SET role = $1, start_time = $2, end_time = $3, notes = NULLIF($4, '')
+			WHERE id = $5
+			RETURNING *
+		)
+		SELECT `+shiftColumns+` FROM s JOIN employees e ON s.employee_id = e.id JOIN users u ON e.user_id = u.id
+	`, s.Role, s.StartTime, s.EndTime, s.Notes, id))
+	if err != nil {
+		log.Printf("Ошибка обновления смены: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлена смена %d", id)
+}
+
+// DeleteShift отменяет смену; отметки табеля по ней сохраняются
+func DeleteShift(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("DELETE /api/shifts/%d - отмена смены", id)
+
+	result, err := database.DB.Exec(`DELETE FROM shifts WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Смена не найдена", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uncoveredTraining — тренировка, которую тренер проводит вне своей смены
+type uncoveredTraining struct {
+	TrainingID  int       `json:"training_id"`
+	Title       string    `json:"title"`
+	TrainerID   int       `json:"trainer_id"`
+	TrainerName string    `json:"trainer_name"`
+	EmployeeID  *int      `json:"employee_id,omitempty"` // нет — тренер не оформлен сотрудником
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+}
+
+// uncoveredTrainings находит запланированные тренировки за период, которые не укладываются целиком
+// ни в одну смену тренера с ролью trainer. employeeID > 0 ограничивает проверку одним сотрудником.
+func uncoveredTrainings(q queryer, from, to time.Time, employeeID int) ([]uncoveredTraining, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.title, t.trainer_id, u.name, e.id, t.start_time,
+		       t.start_time + t.duration_minutes * INTERVAL '1 minute'
+		FROM trainings t
+		JOIN users u ON t.trainer_id = u.id
+		LEFT JOIN employees e ON e.user_id = t.trainer_id
+		WHERE t.status = 'scheduled' AND t.deleted_at IS NULL
+		AND t.start_time >= $1 AND t.start_time < $2
+		AND ($3 = 0 OR e.id = $3)
+		AND NOT EXISTS(
+			SELECT 1 FROM shifts s
+			WHERE s.employee_id = e.id AND s.role = 'trainer'
+			AND s.start_time <= t.start_time
+			AND s.end_time >= t.start_time + t.duration_minutes * INTERVAL '1 minute'
+		)
+		ORDER BY t.start_time
+	`, from, to, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]uncoveredTraining, 0)
+	for rows.Next() {
+		var t uncoveredTraining
+		var employee sql.NullInt64
+		if err := rows.Scan(&t.TrainingID, &t.Title, &t.TrainerID, &t.TrainerName, &employee, &t.StartTime, &t.EndTime); err != nil {
+			return nil, err
+		}
+		if employee.Valid {
+			id := int(employee.Int64)
+			t.EmployeeID = &id
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// GetShiftCoverage сверяет смены тренеров с их тренировками за период from–to
+// и возвращает тренировки, которые проходят вне смены тренера
+func GetShiftCoverage(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/shifts/coverage - сверка смен тренеров с тренировками")
+
+	from, to, err := periodFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trainings, err := uncoveredTrainings(database.DB, from, to, 0)
+	if err != nil {
+		log.Printf("Ошибка сверки смен: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trainings)
+	log.Printf("Тренировок вне смен: %d", len(trainings))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParsePeriod(t *testing.T) {
+	defFrom, defTo := date(2026, time.March, 1), date(2026, time.April, 1)
+	tests := []struct {
+		query    string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{"", defFrom, defTo, false},
+		{"from=2026-03-10", date(2026, time.March, 10), defTo, false},
+		{"to=2026-03-20", defFrom, date(2026, time.March, 21), false},
+		{"from=2026-01-01&to=2026-01-31", date(2026, time.January, 1), date(2026, time.February, 1), false},
+		{"from=2026-01-05&to=2026-01-05", date(2026, time.January, 5), date(2026, time.January, 6), false},
+		{"from=2026-01-06&to=2026-01-05", time.Time{}, time.Time{}, true},
+		{"from=2026-05-01", time.Time{}, time.Time{}, true}, // позже to по умолчанию
+		{"from=01.03.2026", time.Time{}, time.Time{}, true},
+		{"to=2026-13-01", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/reports?"+tt.query, nil)
+		from, to, err := parsePeriod(r, defFrom, defTo)
+		if tt.wantErr {
+			if _, ok := err.(validationError); !ok {
+				t.Errorf("%q: ожидалась ошибка валидации, получено %v", tt.query, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("%q: период %s — %s, ожидалось %s — %s", tt.query,
+				from.Format("2006-01-02"), to.Format("2006-01-02"), tt.from.Format("2006-01-02"), tt.to.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestPeriodDefaults(t *testing.T) {
+	today := currentDate()
+	r := httptest.NewRequest("GET", "/api/reports", nil)
+
+	from, to, err := reportPeriod(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Day() != 1 || from.Month() != today.Month() || !to.Equal(today.AddDate(0, 0, 1)) {
+		t.Errorf("reportPeriod: %s — %s, ожидался текущий месяц по сегодня", from, to)
+	}
+
+	from, to, err = periodFromQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Weekday() != time.Monday || to.Sub(from) != 7*24*time.Hour || today.Before(from) || !today.Before(to) {
+		t.Errorf("periodFromQuery: %s — %s, ожидалась текущая неделя", from, to)
+	}
+}
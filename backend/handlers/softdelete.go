@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"fitness-club/database"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// includeDeleted проверяет флаг include_deleted=true; учитывается только для администратора
+func includeDeleted(r *http.Request) bool {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return false
+	}
+	user, err := getSessionUser(r)
+	return err == nil && user.Role == "admin"
+}
+
+// notDeleted возвращает условие, скрывающее удаленные записи таблицы с псевдонимом alias,
+// или пустую строку, если администратор запросил удаленные записи
+func notDeleted(r *http.Request, alias string) string {
+	if includeDeleted(r) {
+		return ""
+	}
+	return " AND " + alias + ".deleted_at IS NULL"
+}
+
+// softDelete помечает запись удаленной. Возвращает false, если запись не найдена или уже удалена.
+// table — имя таблицы из кода, не из запроса.
+func softDelete(q queryer, table string, id int) (bool, error) {
+	result, err := q.Exec(`UPDATE `+table+` SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// restoreDeleted снимает отметку об удалении с записи таблицы table по ID из пути запроса
+func restoreDeleted(w http.ResponseWriter, r *http.Request, table, entity, notFound string) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/%s/%d/restore - восстановление: %s", table, id, entity)
+
+	var deletedAt sql.NullTime
+	err = database.DB.QueryRow(`SELECT deleted_at FROM `+table+` WHERE id = $1`, id).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deletedAt.Valid {
+		http.Error(w, "Запись не удалена", http.StatusConflict)
+		return
+	}
+
+	if _, err := database.DB.Exec(`UPDATE `+table+` SET deleted_at = NULL WHERE id = $1`, id); err != nil {
+		log.Printf("Ошибка восстановления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Printf("Восстановлено (%s) с ID: %d", entity, id)
+}
+
+// RestoreUser восстанавливает удаленного пользователя
+func RestoreUser(w http.ResponseWriter, r *http.Request) {
+	restoreDeleted(w, r, "users", "пользователь", "Пользователь не найден")
+}
+
+// RestoreClient восстанавливает удаленного клиента
+func RestoreClient(w http.ResponseWriter, r *http.Request) {
+	restoreDeleted(w, r, "clients", "клиент", "Клиент не найден")
+}
+
+// RestoreTraining восстанавливает удаленную тренировку
+func RestoreTraining(w http.ResponseWriter, r *http.Request) {
+	restoreDeleted(w, r, "trainings", "тренировка", "Тренировка не найдена")
+}
+
+// RestoreSubscription восстанавливает удаленный абонемент
+func RestoreSubscription(w http.ResponseWriter, r *http.Request) {
+	restoreDeleted(w, r, "subscriptions", "абонемент", "Абонемент не найден")
+}
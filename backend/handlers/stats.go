@@ -4,110 +4,248 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fitness-club/database"
+	"fitness-club/models"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 )
 
-// StatsResponse представляет статистику системы
+// StatsResponse представляет статистику системы. Без from/to показатели считаются за все время;
+// с периодом тренировки, новые клиенты, продажи и выручка считаются за период и сравниваются
+// с предыдущим периодом той же длины.
 type StatsResponse struct {
-	TotalUsers       int     `json:"total_users"`
-	TotalClients     int     `json:"total_clients"`
-	TotalTrainers    int     `json:"total_trainers"`
-	TotalTrainings   int     `json:"total_trainings"`
-	ActiveSubscriptions int  `json:"active_subscriptions"`
-	AverageTrainingDuration float64 `json:"average_training_duration"`
-	UpcomingTrainings int   `json:"upcoming_trainings"`
-	CompletedTrainings int  `json:"completed_trainings"`
+	TotalUsers          int `json:"total_users"`
+	TotalClients        int `json:"total_clients"`
+	TotalTrainers       int `json:"total_trainers"`  // пользователи с ролью trainer
+	TotalEmployees      int `json:"total_employees"` // записи в таблице сотрудников
+	ActiveSubscriptions int `json:"active_subscriptions"`
+	UpcomingTrainings   int `json:"upcoming_trainings"`
+	StatsPeriod
+	From     string              `json:"from,omitempty"`
+	To       string              `json:"to,omitempty"` // включительно
+	Previous *StatsPeriod        `json:"previous,omitempty"`
+	Deltas   map[string]*float64 `json:"deltas,omitempty"` // изменение к предыдущему периоду, %
 }
 
-// GetStats возвращает статистику системы
-func GetStats(w http.ResponseWriter, r *http.Request) {
-	log.Println("GET /api/stats - получение статистики")
+// StatsPeriod — показатели, зависящие от периода. Денежные показатели видит только администратор.
+type StatsPeriod struct {
+	TotalTrainings          int           `json:"total_trainings"`
+	CompletedTrainings      int           `json:"completed_trainings"`
+	AverageTrainingDuration float64       `json:"average_training_duration"`
+	NewClients              int           `json:"new_clients"`
+	SubscriptionsSold       int           `json:"subscriptions_sold"`
+	TotalRevenue            *models.Money `json:"total_revenue,omitempty"` // оплаты по журналу платежей
+	TotalRefunds            *models.Money `json:"total_refunds,omitempty"` // возвраты клиентам
+	NetRevenue              *models.Money `json:"net_revenue,omitempty"`   // выручка за вычетом возвратов
+}
 
-	stats := StatsResponse{}
+// statsMoneyFields — изменения денежных показателей, скрываемые от всех, кроме администратора
+var statsMoneyFields = []string{"total_revenue", "net_revenue"}
 
-	// Общее количество пользователей
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
-	if err != nil {
-		log.Printf("Ошибка подсчета пользователей: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// withoutRevenue возвращает копию статистики без денежных показателей
+func (s StatsResponse) withoutRevenue() StatsResponse {
+	s.TotalRevenue, s.TotalRefunds, s.NetRevenue = nil, nil, nil
+	if s.Previous != nil {
+		prev := *s.Previous
+		prev.TotalRevenue, prev.TotalRefunds, prev.NetRevenue = nil, nil, nil
+		s.Previous = &prev
+	}
+	if s.Deltas != nil {
+		deltas := make(map[string]*float64, len(s.Deltas))
+		for k, v := range s.Deltas {
+			deltas[k] = v
+		}
+		for _, k := range statsMoneyFields {
+			delete(deltas, k)
+		}
+		s.Deltas = deltas
 	}
+	return s
+}
 
-	// Количество клиентов
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM clients").Scan(&stats.TotalClients)
-	if err != nil {
-		log.Printf("Ошибка подсчета клиентов: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// statsCache хранит рассчитанную статистику по ключу периода. Кэш живет STATS_CACHE_TTL_SECONDS
+// (по умолчанию 30) и сбрасывается после любого изменяющего запроса.
+var statsCache = struct {
+	sync.Mutex
+	entries    map[string]statsCacheEntry
+	generation int // растет при сбросе, чтобы не сохранить результат, посчитанный до изменения
+}{entries: map[string]statsCacheEntry{}}
+
+type statsCacheEntry struct {
+	stats     StatsResponse
+	expiresAt time.Time
+}
+
+// InvalidateStatsCache сбрасывает кэш статистики
+func InvalidateStatsCache() {
+	statsCache.Lock()
+	statsCache.entries = map[string]statsCacheEntry{}
+	statsCache.generation++
+	statsCache.Unlock()
+}
+
+// deltaPercent возвращает изменение current относительно previous в процентах
+func deltaPercent(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
 	}
+	d := round2(100 * (current - previous) / previous)
+	return &d
+}
 
-	// Количество сотрудников (из таблицы employees)
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM employees").Scan(&stats.TotalTrainers)
+// loadStats считает статистику одним запросом. from и to — полуинтервал периода;
+// nil — без ограничения. Предыдущий период [prevFrom, from) считается тем же запросом.
+func loadStats(from, to, prevFrom *time.Time) (StatsResponse, StatsPeriod, error) {
+	var s StatsResponse
+	var prev StatsPeriod
+	var avgDuration, prevAvgDuration sql.NullFloat64
+	var revenue, refunds, prevRevenue, prevRefunds models.Money
+	err := database.DB.QueryRow(`
+		WITH bounds AS (
+			SELECT COALESCE($1::timestamp, '-infinity') AS cur_from,
+			       COALESCE($2::timestamp, 'infinity') AS cur_to,
+			       COALESCE($3::timestamp, 'infinity') AS prev_from
+		),
+		t AS (
+			SELECT COUNT(*) FILTER (WHERE start_time >= cur_from AND start_time < cur_to) AS total,
+			       COUNT(*) FILTER (WHERE status = 'completed' AND start_time >= cur_from AND start_time < cur_to) AS completed,
+			       AVG(duration_minutes) FILTER (WHERE start_time >= cur_from AND start_time < cur_to) AS avg_duration,
+			       COUNT(*) FILTER (WHERE start_time >= prev_from AND start_time < cur_from) AS prev_total,
+			       COUNT(*) FILTER (WHERE status = 'completed' AND start_time >= prev_from AND start_time < cur_from) AS prev_completed,
+			       AVG(duration_minutes) FILTER (WHERE start_time >= prev_from AND start_time < cur_from) AS prev_avg_duration,
+			       COUNT(*) FILTER (WHERE status = 'scheduled' AND start_time > NOW()) AS upcoming
+			FROM trainings, bounds
+			WHERE deleted_at IS NULL
+		),
+		c AS (
+			SELECT COUNT(*) AS total,
+			       COUNT(*) FILTER (WHERE created_at >= cur_from AND created_at < cur_to) AS new_clients,
+			       COUNT(*) FILTER (WHERE created_at >= prev_from AND created_at < cur_from) AS prev_new_clients
+			FROM clients, bounds
+			WHERE deleted_at IS NULL
+		),
+		s AS (
+			SELECT COUNT(*) FILTER (WHERE status = 'active' AND end_date >= CURRENT_DATE) AS active,
+			       COUNT(*) FILTER (WHERE created_at >= cur_from AND created_at < cur_to) AS sold,
+			       COUNT(*) FILTER (WHERE created_at >= prev_from AND created_at < cur_from) AS prev_sold
+			FROM subscriptions, bounds
+			WHERE deleted_at IS NULL
+		),
+		p AS (
+			SELECT COALESCE(SUM(amount) FILTER (WHERE kind = 'payment' AND paid_at >= cur_from AND paid_at < cur_to), 0) AS revenue,
+			       COALESCE(SUM(amount) FILTER (WHERE kind = 'refund' AND paid_at >= cur_from AND paid_at < cur_to), 0) AS refunds,
+			       COALESCE(SUM(amount) FILTER (WHERE kind = 'payment' AND paid_at >= prev_from AND paid_at < cur_from), 0) AS prev_revenue,
+			       COALESCE(SUM(amount) FILTER (WHERE kind = 'refund' AND paid_at >= prev_from AND paid_at < cur_from), 0) AS prev_refunds
+			FROM payments, bounds
+			WHERE status = 'paid'
+		)
+		SELECT (SELECT COUNT(*) FROM users WHERE deleted_at IS NULL),
+		       c.total,
+		       (SELECT COUNT(*) FROM users WHERE role = 'trainer' AND deleted_at IS NULL),
+		       (SELECT COUNT(*) FROM employees),
+		       s.active, t.upcoming,
+		       t.total, t.completed, t.avg_duration, c.new_clients, s.sold, p.revenue, p.refunds,
+		       t.prev_total, t.prev_completed, t.prev_avg_duration, c.prev_new_clients, s.prev_sold, p.prev_revenue, p.prev_refunds
+		FROM t, c, s, p
+	`, from, to, prevFrom).Scan(
+		&s.TotalUsers, &s.TotalClients, &s.TotalTrainers, &s.TotalEmployees, &s.ActiveSubscriptions, &s.UpcomingTrainings,
+		&s.TotalTrainings, &s.CompletedTrainings, &avgDuration, &s.NewClients, &s.SubscriptionsSold,
+		&revenue, &refunds,
+		&prev.TotalTrainings, &prev.CompletedTrainings, &prevAvgDuration, &prev.NewClients, &prev.SubscriptionsSold,
+		&prevRevenue, &prevRefunds,
+	)
 	if err != nil {
-		log.Printf("Ошибка подсчета сотрудников: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return s, prev, err
 	}
+	s.AverageTrainingDuration = avgDuration.Float64
+	net := revenue.Sub(refunds)
+	s.TotalRevenue, s.TotalRefunds, s.NetRevenue = &revenue, &refunds, &net
+	prev.AverageTrainingDuration = prevAvgDuration.Float64
+	prevNet := prevRevenue.Sub(prevRefunds)
+	prev.TotalRevenue, prev.TotalRefunds, prev.NetRevenue = &prevRevenue, &prevRefunds, &prevNet
+	return s, prev, nil
+}
 
-	// Общее количество тренировок
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM trainings").Scan(&stats.TotalTrainings)
+// GetStats возвращает статистику системы; from и to (YYYY-MM-DD, to включительно) задают период.
+// Выручку и возвраты получает только администратор.
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/stats - получение статистики")
+
+	user, err := getSessionUser(r)
 	if err != nil {
-		log.Printf("Ошибка подсчета тренировок: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
 		return
 	}
+	writeStats := func(stats StatsResponse) {
+		if user.Role != "admin" {
+			stats = stats.withoutRevenue()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
 
-	// Активные абонементы
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM subscriptions 
-		WHERE status = 'active' AND end_date >= CURRENT_DATE
-	`).Scan(&stats.ActiveSubscriptions)
-	if err != nil {
-		log.Printf("Ошибка подсчета активных абонементов: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	query := r.URL.Query()
+	withPeriod := query.Get("from") != "" || query.Get("to") != ""
+
+	// Ключ кэша строится по разобранному периоду, чтобы запросы с одинаковым периодом
+	// (например, только from и from с to по сегодня) попадали в одну запись
+	var from, to, prevFrom *time.Time
+	key := ""
+	if withPeriod {
+		periodFrom, periodTo, err := reportPeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		previous := periodFrom.Add(-periodTo.Sub(periodFrom))
+		from, to, prevFrom = &periodFrom, &periodTo, &previous
+		key = periodFrom.Format("2006-01-02") + "|" + periodTo.Format("2006-01-02")
 	}
 
-	// Средняя длительность тренировок (в минутах)
-	var avgDuration sql.NullFloat64
-	err = database.DB.QueryRow("SELECT AVG(duration_minutes) FROM trainings").Scan(&avgDuration)
-	if err != nil && err != sql.ErrNoRows {
-		log.Printf("Ошибка расчета средней длительности: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	statsCache.Lock()
+	entry, ok := statsCache.entries[key]
+	generation := statsCache.generation
+	statsCache.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		writeStats(entry.stats)
 		return
 	}
-	if avgDuration.Valid {
-		stats.AverageTrainingDuration = avgDuration.Float64
-	}
 
-	// Предстоящие тренировки
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM trainings 
-		WHERE status = 'scheduled' AND start_time > NOW()
-	`).Scan(&stats.UpcomingTrainings)
+	stats, prev, err := loadStats(from, to, prevFrom)
 	if err != nil {
-		log.Printf("Ошибка подсчета предстоящих тренировок: %v", err)
+		log.Printf("Ошибка подсчета статистики: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Завершенные тренировки
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) 
-		FROM trainings 
-		WHERE status = 'completed'
-	`).Scan(&stats.CompletedTrainings)
-	if err != nil {
-		log.Printf("Ошибка подсчета завершенных тренировок: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if withPeriod {
+		stats.From = from.Format("2006-01-02")
+		stats.To = to.AddDate(0, 0, -1).Format("2006-01-02")
+		stats.Previous = &prev
+		stats.Deltas = map[string]*float64{
+			"total_trainings":     deltaPercent(float64(stats.TotalTrainings), float64(prev.TotalTrainings)),
+			"completed_trainings": deltaPercent(float64(stats.CompletedTrainings), float64(prev.CompletedTrainings)),
+			"new_clients":         deltaPercent(float64(stats.NewClients), float64(prev.NewClients)),
+			"subscriptions_sold":  deltaPercent(float64(stats.SubscriptionsSold), float64(prev.SubscriptionsSold)),
+			"total_revenue":       deltaPercent(stats.TotalRevenue.Float64(), prev.TotalRevenue.Float64()),
+			"net_revenue":         deltaPercent(stats.NetRevenue.Float64(), prev.NetRevenue.Float64()),
+		}
+	}
+
+	ttl := time.Duration(getEnvInt("STATS_CACHE_TTL_SECONDS", 30)) * time.Second
+	now := time.Now()
+	statsCache.Lock()
+	if statsCache.generation == generation {
+		for k, e := range statsCache.entries {
+			if now.After(e.expiresAt) {
+				delete(statsCache.entries, k)
+			}
+		}
+		statsCache.entries[key] = statsCacheEntry{stats: stats, expiresAt: now.Add(ttl)}
 	}
+	statsCache.Unlock()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	writeStats(stats)
 	log.Printf("Статистика возвращена успешно")
 }
-
@@ -5,23 +5,95 @@ import (
 	"encoding/json"
 	"fitness-club/models"
 	"fitness-club/database"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// subscriptionSelect выбирает абонемент вместе с клиентом; читается через scanSubscription
+const subscriptionSelect = `
+	SELECT s.id, s.client_id, s.type, s.start_date, s.end_date, s.price,
+	       COALESCE(s.base_price, s.price), s.discount_amount, COALESCE(s.discount_reason, ''), s.promo_code_id,
+	       s.previous_subscription_id, COALESCE(s.change_kind, ''), s.auto_renew,
+	       s.status, s.cancelled_at, s.refund_amount, s.created_at, s.deleted_at,
+	       c.id, c.user_id, c.phone, c.address
+	FROM subscriptions s
+	LEFT JOIN clients c ON s.client_id = c.id
+`
+
+// scanSubscription читает строку запроса subscriptionSelect
+func scanSubscription(row interface{ Scan(...interface{}) error }) (models.Subscription, error) {
+	var s models.Subscription
+	var c models.Client
+	var promoCodeID sql.NullInt64
+	var previousID sql.NullInt64
+	var cancelledAt sql.NullTime
+	var deletedAt sql.NullTime
+	var phone sql.NullString
+	var address sql.NullString
+
+	err := row.Scan(&s.ID, &s.ClientID, &s.Type, &s.StartDate, &s.EndDate, &s.Price,
+		&s.BasePrice, &s.DiscountAmount, &s.DiscountReason, &promoCodeID,
+		&previousID, &s.ChangeKind, &s.AutoRenew,
+		&s.Status, &cancelledAt, &s.RefundAmount, &s.CreatedAt, &deletedAt,
+		&c.ID, &c.UserID, &phone, &address)
+	if err != nil {
+		return s, err
+	}
+
+	// Обрабатываем NULL значения
+	if promoCodeID.Valid {
+		id := int(promoCodeID.Int64)
+		s.PromoCodeID = &id
+	}
+	if previousID.Valid {
+		id := int(previousID.Int64)
+		s.PreviousID = &id
+	}
+	if cancelledAt.Valid {
+		s.CancelledAt = &cancelledAt.Time
+	}
+	if deletedAt.Valid {
+		s.DeletedAt = &deletedAt.Time
+	}
+	if phone.Valid {
+		c.Phone = phone.String
+	}
+	if address.Valid {
+		c.Address = address.String
+	}
+	s.Client = &c
+	return s, nil
+}
+
+// subscriptionOwner возвращает ID пользователя, которому принадлежит абонемент
+func subscriptionOwner(subscriptionID int) (int, error) {
+	var userID int
+	err := database.DB.QueryRow(`
+		SELECT c.user_id
+		FROM subscriptions s
+		JOIN clients c ON s.client_id = c.id
+		WHERE s.id = $1
+	`, subscriptionID).Scan(&userID)
+	return userID, err
+}
+
 // GetSubscriptions возвращает список всех абонементов
 func GetSubscriptions(w http.ResponseWriter, r *http.Request) {
 	log.Println("GET /api/subscriptions - получение списка абонементов")
 
-	rows, err := database.DB.Query(`
-		SELECT s.id, s.client_id, s.type, s.start_date, s.end_date, s.price, s.status, s.created_at,
-		       c.id, c.user_id, c.phone, c.address
-		FROM subscriptions s
-		LEFT JOIN clients c ON s.client_id = c.id
+	format, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(subscriptionSelect + `
+		WHERE 1=1` + notDeleted(r, "s") + `
 		ORDER BY s.created_at DESC
 	`)
 	if err != nil {
@@ -31,31 +103,24 @@ func GetSubscriptions(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	export, err := beginExport(w, format, "subscriptions", "ID", "ID клиента", "Телефон клиента", "Тип", "Начало", "Окончание",
+		"Цена", "Цена по тарифу", "Скидка", "Причина скидки", "Автопродление", "Статус", "Отменен", "Возврат", "Создан", "Удален")
+	if err != nil {
+		log.Printf("Ошибка выгрузки абонементов: %v", err)
+		return
+	}
+
 	var subscriptions []models.Subscription
 	// Инициализируем как пустой массив
 	subscriptions = make([]models.Subscription, 0)
 	
 	for rows.Next() {
-		var s models.Subscription
-		var c models.Client
-		var phone sql.NullString
-		var address sql.NullString
-
-		err := rows.Scan(&s.ID, &s.ClientID, &s.Type, &s.StartDate, &s.EndDate, &s.Price, &s.Status, &s.CreatedAt,
-			&c.ID, &c.UserID, &phone, &address)
+		s, err := scanSubscription(rows)
 		if err != nil {
 			log.Printf("Ошибка сканирования: %v", err)
 			continue
 		}
 
-		// Обрабатываем NULL значения
-		if phone.Valid {
-			c.Phone = phone.String
-		}
-		if address.Valid {
-			c.Address = address.String
-		}
-		
 		// Автоматически обновляем статус на основе текущей даты
 		now := time.Now()
 		originalStatus := s.Status
@@ -75,11 +140,27 @@ func GetSubscriptions(w http.ResponseWriter, r *http.Request) {
 				`, s.Status, s.ID)
 			}
 		}
-		
-		s.Client = &c
+
+		if export != nil {
+			var phone string
+			if s.Client != nil {
+				phone = s.Client.Phone
+			}
+			if !export.Write(s.ID, s.ClientID, phone, s.Type, s.StartDate.Format("2006-01-02"), s.EndDate.Format("2006-01-02"),
+				s.Price, s.BasePrice, s.DiscountAmount, s.DiscountReason, s.AutoRenew, s.Status, s.CancelledAt,
+				s.RefundAmount, s.CreatedAt, s.DeletedAt) {
+				return
+			}
+			continue
+		}
 		subscriptions = append(subscriptions, s)
 	}
 
+	if export != nil {
+		export.Close()
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(subscriptions)
 	log.Printf("Возвращено абонементов: %d", len(subscriptions))
@@ -96,17 +177,8 @@ func GetSubscription(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("GET /api/subscriptions/%d - получение абонемента", id)
 
-	var s models.Subscription
-	var c models.Client
-
-	err = database.DB.QueryRow(`
-		SELECT s.id, s.client_id, s.type, s.start_date, s.end_date, s.price, s.status, s.created_at,
-		       c.id, c.user_id, c.phone, c.address
-		FROM subscriptions s
-		LEFT JOIN clients c ON s.client_id = c.id
-		WHERE s.id = $1
-	`, id).Scan(&s.ID, &s.ClientID, &s.Type, &s.StartDate, &s.EndDate, &s.Price, &s.Status, &s.CreatedAt,
-		&c.ID, &c.UserID, &c.Phone, &c.Address)
+	s, err := scanSubscription(database.DB.QueryRow(subscriptionSelect+`
+		WHERE s.id = $1`+notDeleted(r, "s"), id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -118,8 +190,6 @@ func GetSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Client = &c
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s)
 }
@@ -129,6 +199,12 @@ type SubscriptionRequest struct {
 	UserID    int    `json:"user_id"`    // ID пользователя (будем искать его client_id)
 	Type      string `json:"type"`       // Тип абонемента: monthly, quarterly, yearly
 	StartDate string `json:"start_date"` // Формат: YYYY-MM-DD
+	PromoCode string `json:"promo_code"` // Необязательный промокод
+	PaymentMethod string `json:"payment_method"` // cash (по умолчанию), card, online
+	Paid          *bool  `json:"paid"`           // false — оплата ожидается позже; true — только администратор
+	AutoRenew     bool   `json:"auto_renew"`     // Продлевать автоматически через платежного провайдера
+	RedeemPoints  int    `json:"redeem_points"`  // Сколько бонусных баллов списать в счет оплаты
+	ReferralCode  string `json:"referral_code"`  // Код пригласившего клиента, только при первой покупке
 }
 
 // CreateSubscription создает новый абонемент
@@ -157,10 +233,22 @@ func CreateSubscription(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "type обязателен", http.StatusBadRequest)
 		return
 	}
-	
+
+	// Клиент оформляет абонемент только себе: бонусные баллы и реферальный код
+	// принадлежат владельцу абонемента
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	if user.Role != "admin" && req.UserID != user.ID {
+		http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		return
+	}
+
 	// Находим client_id по user_id
 	var clientID int
-	err := database.DB.QueryRow(`
+	err = database.DB.QueryRow(`
 		SELECT id FROM clients WHERE user_id = $1
 	`, req.UserID).Scan(&clientID)
 	
@@ -183,72 +271,245 @@ func CreateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Рассчитываем дату окончания и цену на основе типа абонемента
-	var endDate time.Time
-	var price float64
-	var typeName string
-	
-	switch req.Type {
-	case "monthly", "Месячный":
-		endDate = startDate.AddDate(0, 1, 0) // +1 месяц
-		price = 2000.0
-		typeName = "Месячный"
-	case "quarterly", "Квартальный":
-		endDate = startDate.AddDate(0, 3, 0) // +3 месяца
-		price = 5000.0
-		typeName = "Квартальный"
-	case "yearly", "Годовой":
-		endDate = startDate.AddDate(1, 0, 0) // +1 год
-		price = 18000.0
-		typeName = "Годовой"
-	default:
+	// Дата окончания и цена определяются тарифом
+	plan, ok := models.FindPlan(req.Type)
+	if !ok {
 		log.Printf("Ошибка: неизвестный тип абонемента '%s'", req.Type)
 		http.Error(w, "Неизвестный тип абонемента. Доступны: monthly, quarterly, yearly", http.StatusBadRequest)
 		return
 	}
-	
-	// Автоматически определяем статус на основе текущей даты
-	now := time.Now()
-	var status string
-	if endDate.Before(now) {
-		status = "expired"
-	} else if startDate.After(now) {
-		status = "active" // Абонемент еще не начался, но активен
-	} else {
-		status = "active" // Абонемент активен
+
+	payment, err := paymentFromRequest(req.PaymentMethod, req.Paid, user.Role == "admin")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Цена, использование промокода и платеж фиксируются в одной транзакции
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	quote, err := quoteSubscription(tx, clientID, plan, req.PromoCode, req.RedeemPoints)
+	if err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка расчета цены: %v", err)
+		http.Error(w, "Ошибка расчета цены", http.StatusInternalServerError)
+		return
 	}
 	
-	// Создаем модель Subscription
-	s := models.Subscription{
-		ClientID:  clientID,
-		Type:      typeName,
-		StartDate: startDate,
-		EndDate:   endDate,
-		Price:     price,
-		Status:    status,
+	// Реферальный код привязывается до записи абонемента: бонус пригласившему
+	// начисляется при оплате первой покупки
+	if strings.TrimSpace(req.ReferralCode) != "" {
+		if err := linkReferral(tx, clientID, req.ReferralCode); err != nil {
+			if _, ok := err.(validationError); ok {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Ошибка привязки реферального кода: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
-	var id int
-	err = database.DB.QueryRow(`
-		INSERT INTO subscriptions (client_id, type, start_date, end_date, price, status) 
-		VALUES ($1, $2, $3, $4, $5, $6) 
-		RETURNING id
-	`, s.ClientID, s.Type, s.StartDate, s.EndDate, s.Price, s.Status).Scan(&id)
+	// Создаем модель Subscription
+	s := newSubscription(clientID, plan, startDate, quote)
+	s.AutoRenew = req.AutoRenew
 
-	if err != nil {
+	if err := sellSubscription(tx, &s, payment); err != nil {
 		log.Printf("Ошибка создания абонемента: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	id := s.ID
+
+	if quote.PointsRedeemed > 0 {
+		err = spendPoints(tx, loyaltyEntry{
+			ClientID:       clientID,
+			Reason:         "redemption",
+			Points:         quote.PointsRedeemed,
+			SubscriptionID: &id,
+			Description:    "Оплата абонемента «" + s.Type + "»",
+		})
+	}
+	if err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка начисления бонусов: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения абонемента: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Счет выставляется сразу после продажи; при ошибке он будет выставлен при первом запросе PDF
+	if _, err := issueInvoice(id); err != nil {
+		log.Printf("Ошибка выставления счета по абонементу %d: %v", id, err)
+	}
 
-	s.ID = id
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(s)
 	log.Printf("Создан абонемент с ID: %d", id)
 }
 
-// DeleteSubscription удаляет абонемент
+// subscriptionStatus определяет статус нового абонемента по датам
+func subscriptionStatus(startDate, endDate time.Time) string {
+	if endDate.Before(time.Now()) {
+		return "expired"
+	}
+	// Абонемент, который еще не начался, тоже считается активным
+	return "active"
+}
+
+// newSubscription собирает абонемент по тарифу и рассчитанной цене
+func newSubscription(clientID int, plan models.SubscriptionPlan, startDate time.Time, quote subscriptionQuote) models.Subscription {
+	endDate := plan.EndDate(startDate)
+	return models.Subscription{
+		ClientID:       clientID,
+		Type:           plan.Name,
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Price:          quote.Total,
+		BasePrice:      quote.BasePrice,
+		DiscountAmount: quote.DiscountAmount,
+		DiscountReason: quote.discountReason(),
+		PromoCodeID:    quote.PromoCodeID,
+		Status:         subscriptionStatus(startDate, endDate),
+	}
+}
+
+// paymentFromRequest формирует платеж за абонемент по способу оплаты из запроса.
+// Оплату принимает только администратор: у остальных платеж всегда ожидает оплаты,
+// а администратор по умолчанию фиксирует оплату сразу.
+func paymentFromRequest(method string, paid *bool, admin bool) (*models.Payment, error) {
+	if method == "" {
+		method = "cash"
+	}
+	if !validPaymentMethod(method) {
+		return nil, validationError("Неизвестный способ оплаты. Доступны: cash, card, online")
+	}
+	if !admin && paid != nil && *paid {
+		return nil, validationError("Отметить абонемент оплаченным может только администратор")
+	}
+	status := "pending"
+	if admin && (paid == nil || *paid) {
+		status = "paid"
+	}
+	return &models.Payment{Method: method, Status: status}, nil
+}
+
+// sellSubscription сохраняет проданный абонемент вместе с использованием промокода и платежом.
+// Вызывается внутри транзакции; для бесплатного абонемента платеж не записывается.
+func sellSubscription(tx *sql.Tx, s *models.Subscription, payment *models.Payment) error {
+	err := tx.QueryRow(`
+		INSERT INTO subscriptions (client_id, type, start_date, end_date, price, base_price,
+		                           discount_amount, discount_reason, promo_code_id,
+		                           previous_subscription_id, change_kind, auto_renew, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, NULLIF($11, ''), $12, $13)
+		RETURNING id, created_at
+	`, s.ClientID, s.Type, s.StartDate, s.EndDate, s.Price, s.BasePrice,
+		s.DiscountAmount, s.DiscountReason, s.PromoCodeID,
+		s.PreviousID, s.ChangeKind, s.AutoRenew, s.Status).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if s.PromoCodeID != nil {
+		if err := redeemPromoCode(tx, *s.PromoCodeID, s.ClientID, s.ID); err != nil {
+			return fmt.Errorf("ошибка применения промокода: %v", err)
+		}
+	}
+
+	if payment != nil && s.Price.IsPositive() {
+		payment.SubscriptionID = &s.ID
+		payment.ClientID = &s.ClientID
+		payment.Kind = "payment"
+		payment.Amount = s.Price
+		if payment.Description == "" {
+			payment.Description = fmt.Sprintf("Абонемент «%s» с %s", s.Type, s.StartDate.Format("02.01.2006"))
+		}
+		if err := recordPayment(tx, payment); err != nil {
+			return fmt.Errorf("ошибка записи платежа: %v", err)
+		}
+		if err := awardPurchasePoints(tx, *payment); err != nil {
+			return fmt.Errorf("ошибка начисления баллов: %v", err)
+		}
+		if err := awardReferral(tx, *payment); err != nil {
+			return fmt.Errorf("ошибка начисления бонуса за приглашение: %v", err)
+		}
+	}
+	return nil
+}
+
+// QuoteSubscription рассчитывает цену абонемента со скидками без его создания
+func QuoteSubscription(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/subscriptions/quote - расчет цены абонемента")
+
+	var req SubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, ok := models.FindPlan(req.Type)
+	if !ok {
+		http.Error(w, "Неизвестный тип абонемента. Доступны: monthly, quarterly, yearly", http.StatusBadRequest)
+		return
+	}
+
+	// Расчет показывает баланс баллов клиента, поэтому доступен только ему самому и администратору
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	if user.Role != "admin" && req.UserID != user.ID {
+		http.Error(w, "Доступ запрещен", http.StatusForbidden)
+		return
+	}
+
+	var clientID int
+	err = database.DB.QueryRow(`SELECT id FROM clients WHERE user_id = $1`, req.UserID).Scan(&clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Клиент для этого пользователя не найден", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка поиска клиента: %v", err)
+		http.Error(w, "Ошибка поиска клиента", http.StatusInternalServerError)
+		return
+	}
+
+	quote, err := quoteSubscription(database.DB, clientID, plan, req.PromoCode, req.RedeemPoints)
+	if err != nil {
+		if _, ok := err.(validationError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Ошибка расчета цены: %v", err)
+		http.Error(w, "Ошибка расчета цены", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
+
+// DeleteSubscription помечает абонемент удаленным; платежи и счета по нему сохраняются
 func DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -259,15 +520,13 @@ func DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("DELETE /api/subscriptions/%d - удаление абонемента", id)
 
-	result, err := database.DB.Exec("DELETE FROM subscriptions WHERE id = $1", id)
+	deleted, err := softDelete(database.DB, "subscriptions", id)
 	if err != nil {
 		log.Printf("Ошибка удаления: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if !deleted {
 		http.Error(w, "Абонемент не найден", http.StatusNotFound)
 		return
 	}
@@ -293,16 +552,36 @@ func UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Отмена проходит через возврат денег и журнал платежей, а не правку статуса
+	if s.Status == "cancelled" {
+		http.Error(w, fmt.Sprintf("Для отмены абонемента используйте POST /api/subscriptions/%d/cancel", id), http.StatusBadRequest)
+		return
+	}
+
 	// Проверяем существование абонемента
-	var exists bool
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1)", id).Scan(&exists)
+	var status string
+	var price models.Money
+	var hasPayments bool
+	err = database.DB.QueryRow(`
+		SELECT status, price, EXISTS(SELECT 1 FROM payments WHERE subscription_id = s.id)
+		FROM subscriptions s WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&status, &price, &hasPayments)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Абонемент не найден", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("Ошибка проверки абонемента: %v", err)
 		http.Error(w, "Ошибка проверки абонемента", http.StatusInternalServerError)
 		return
 	}
-	if !exists {
-		http.Error(w, "Абонемент не найден", http.StatusNotFound)
+	if status == "cancelled" {
+		http.Error(w, "Отмененный абонемент нельзя изменить", http.StatusConflict)
+		return
+	}
+	// Цена проданного абонемента связана с платежами, счетом и расчетом возврата
+	if hasPayments && s.Price.Amount != price.Amount {
+		http.Error(w, "Цену нельзя изменить: по абонементу уже есть платежи", http.StatusBadRequest)
 		return
 	}
 
@@ -320,19 +599,9 @@ func UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Возвращаем обновленный абонемент
-	var updatedSubscription models.Subscription
-	var c models.Client
-
-	err = database.DB.QueryRow(`
-		SELECT s.id, s.client_id, s.type, s.start_date, s.end_date, s.price, s.status, s.created_at,
-		       c.id, c.user_id, c.phone, c.address
-		FROM subscriptions s
-		LEFT JOIN clients c ON s.client_id = c.id
+	updatedSubscription, err := scanSubscription(database.DB.QueryRow(subscriptionSelect+`
 		WHERE s.id = $1
-	`, id).Scan(&updatedSubscription.ID, &updatedSubscription.ClientID, &updatedSubscription.Type,
-		&updatedSubscription.StartDate, &updatedSubscription.EndDate, &updatedSubscription.Price,
-		&updatedSubscription.Status, &updatedSubscription.CreatedAt,
-		&c.ID, &c.UserID, &c.Phone, &c.Address)
+	`, id))
 
 	if err != nil {
 		log.Printf("Ошибка получения обновленного абонемента: %v", err)
@@ -340,8 +609,6 @@ func UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedSubscription.Client = &c
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedSubscription)
 	log.Printf("Обновлен абонемент с ID: %d", id)
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Отметка прихода привязывается к смене, которая идет сейчас или начнется в течение часа.
+// Переработкой считается время сверх длительности смены, если превышение больше
+// SHIFT_OVERTIME_GRACE_MINUTES минут (по умолчанию 15); работа без смены — переработка целиком.
+// Если смена отработана в несколько отметок (перерыв, выход по делам), переработка считается
+// по сумме всех закрытых неотклоненных отметок смены и записывается на последнюю из них.
+
+// employeeForUser возвращает ID сотрудника по пользователю; sql.ErrNoRows — пользователь не сотрудник
+func employeeForUser(userID int) (int, error) {
+	var id int
+	err := database.DB.QueryRow(`SELECT id FROM employees WHERE user_id = $1`, userID).Scan(&id)
+	return id, err
+}
+
+// timeEntryColumns дополнительно читает, сколько отработано по другим отметкам той же смены
+// и есть ли среди них более поздняя. Текущая отметка исключается из подзапросов: в запросах
+// вида WITH te AS (UPDATE ... RETURNING *) подзапрос видит ее еще в прежнем состоянии.
+const timeEntryColumns = `
+	te.id, te.employee_id, u.name, te.shift_id, te.clock_in, te.clock_out, te.status, COALESCE(te.note, ''),
+	te.reviewed_by, te.reviewed_at, te.created_at, sh.start_time, sh.end_time,
+	COALESCE((SELECT SUM(EXTRACT(EPOCH FROM (o.clock_out - o.clock_in)) / 60)::int
+	          FROM time_entries o
+	          WHERE o.shift_id = te.shift_id AND o.id <> te.id
+	          AND o.clock_out IS NOT NULL AND o.status <> 'rejected'), 0),
+	EXISTS(SELECT 1 FROM time_entries o
+	       WHERE o.shift_id = te.shift_id AND o.id <> te.id
+	       AND o.clock_out IS NOT NULL AND o.status <> 'rejected'
+	       AND (o.clock_in, o.id) > (te.clock_in, te.id))
+`
+
+const timeEntryFrom = `
+	FROM time_entries te
+	JOIN employees e ON te.employee_id = e.id
+	JOIN users u ON e.user_id = u.id
+	LEFT JOIN shifts sh ON te.shift_id = sh.id
+`
+
+// scanTimeEntry читает отметку и рассчитывает отработанное время и переработку
+func scanTimeEntry(row interface{ Scan(...interface{}) error }) (models.TimeEntry, error) {
+	var t models.TimeEntry
+	var shiftID, reviewedBy sql.NullInt64
+	var clockOut, reviewedAt, shiftStart, shiftEnd sql.NullTime
+	var shiftWorked int
+	var laterInShift bool
+	err := row.Scan(&t.ID, &t.EmployeeID, &t.EmployeeName, &shiftID, &t.ClockIn, &clockOut, &t.Status, &t.Note,
+		&reviewedBy, &reviewedAt, &t.CreatedAt, &shiftStart, &shiftEnd, &shiftWorked, &laterInShift)
+	if err != nil {
+		return t, err
+	}
+	if shiftID.Valid {
+		id := int(shiftID.Int64)
+		t.ShiftID = &id
+	}
+	if reviewedBy.Valid {
+		id := int(reviewedBy.Int64)
+		t.ReviewedBy = &id
+	}
+	if reviewedAt.Valid {
+		t.ReviewedAt = &reviewedAt.Time
+	}
+	if !clockOut.Valid {
+		return t, nil
+	}
+	t.ClockOut = &clockOut.Time
+
+	t.WorkedMinutes = int(t.ClockOut.Sub(t.ClockIn).Minutes())
+	if t.Status == "rejected" {
+		return t, nil
+	}
+	scheduled := 0
+	if shiftStart.Valid && shiftEnd.Valid {
+		scheduled = int(shiftEnd.Time.Sub(shiftStart.Time).Minutes())
+	}
+	t.OvertimeMinutes = overtimeMinutes(t.WorkedMinutes, shiftWorked, scheduled,
+		getEnvInt("SHIFT_OVERTIME_GRACE_MINUTES", 15), !laterInShift)
+	return t, nil
+}
+
+// overtimeMinutes рассчитывает переработку по отметке: worked — отработано по ней,
+// shiftWorked — по остальным отметкам той же смены, scheduled — длительность смены
+// (0 — работа без смены), last — отметка последняя в смене. Переработка смены целиком
+// приходится на последнюю отметку, чтобы в итогах она не учитывалась дважды.
+func overtimeMinutes(worked, shiftWorked, scheduled, grace int, last bool) int {
+	if scheduled == 0 {
+		return worked
+	}
+	if !last {
+		return 0
+	}
+	if extra := worked + shiftWorked - scheduled; extra > grace {
+		return extra
+	}
+	return 0
+}
+
+// ClockIn отмечает приход текущего сотрудника
+func ClockIn(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/timesheets/clock-in - отметка прихода")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	employeeID, err := employeeForUser(user.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Вы не оформлены сотрудником клуба", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entry, err := scanTimeEntry(database.DB.QueryRow(`
+		WITH te AS (
+			INSERT INTO time_entries (employee_id, shift_id)
+			VALUES ($1, (
+				SELECT id FROM shifts
+				WHERE employee_id = $1 AND start_time - INTERVAL '1 hour' <= NOW() AND end_time > NOW()
+				ORDER BY start_time
+				LIMIT 1
+			))
+			RETURNING *
+		)
+		SELECT `+timeEntryColumns+`
+		FROM te
+		JOIN employees e ON te.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		LEFT JOIN shifts sh ON te.shift_id = sh.id
+	`, employeeID))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Приход уже отмечен, сначала отметьте уход", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка отметки прихода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+	log.Printf("Сотрудник %d отметил приход (смена: %v)", employeeID, entry.ShiftID != nil)
+}
+
+// ClockOut отмечает уход текущего сотрудника; note — необязательный комментарий к отметке
+func ClockOut(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/timesheets/clock-out - отметка ухода")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	employeeID, err := employeeForUser(user.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Вы не оформлены сотрудником клуба", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка поиска сотрудника: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entry, err := scanTimeEntry(database.DB.QueryRow(`
+		WITH te AS (
+			UPDATE time_entries SET clock_out = NOW(), note = COALESCE(NULLIF($2, ''), note)
+			WHERE employee_id = $1 AND clock_out IS NULL
+			RETURNING *
+		)
+		SELECT `+timeEntryColumns+`
+		FROM te
+		JOIN employees e ON te.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		LEFT JOIN shifts sh ON te.shift_id = sh.id
+	`, employeeID, strings.TrimSpace(req.Note)))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Приход не отмечен", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка отметки ухода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+	log.Printf("Сотрудник %d отметил уход, отработано %d мин.", employeeID, entry.WorkedMinutes)
+}
+
+// GetTimeEntries возвращает табель за период from–to с фильтрами employee_id и status.
+// Администратор видит всех сотрудников, остальные — только свои отметки.
+func GetTimeEntries(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/timesheets - получение табеля")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+	from, to, err := periodFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	employeeID := 0
+	if value := r.URL.Query().Get("employee_id"); value != "" {
+		if employeeID, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Неверный employee_id", http.StatusBadRequest)
+			return
+		}
+	}
+	if user.Role != "admin" {
+		own, err := employeeForUser(user.ID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Вы не оформлены сотрудником клуба", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка поиска сотрудника: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if employeeID != 0 && employeeID != own {
+			http.Error(w, "Доступ запрещен", http.StatusForbidden)
+			return
+		}
+		employeeID = own
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+timeEntryColumns+timeEntryFrom+`
+		WHERE te.clock_in >= $1 AND te.clock_in < $2
+		AND ($3 = 0 OR te.employee_id = $3)
+		AND ($4 = '' OR te.status = $4)
+		ORDER BY te.clock_in DESC
+	`, from, to, employeeID, r.URL.Query().Get("status"))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]models.TimeEntry, 0)
+	for rows.Next() {
+		t, err := scanTimeEntry(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		entries = append(entries, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+	log.Printf("Возвращено отметок табеля: %d", len(entries))
+}
+
+// UpdateTimeEntry исправляет время прихода и ухода (например, если сотрудник забыл отметить уход).
+// Исправленная отметка снова ожидает утверждения.
+func UpdateTimeEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("PUT /api/timesheets/%d - исправление отметки табеля", id)
+
+	var req struct {
+		ClockIn  time.Time  `json:"clock_in"`
+		ClockOut *time.Time `json:"clock_out"`
+		Note     string     `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ClockIn.IsZero() || req.ClockOut == nil || !req.ClockOut.After(req.ClockIn) {
+		http.Error(w, "Укажите clock_in и clock_out, уход должен быть позже прихода", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := scanTimeEntry(database.DB.QueryRow(`
+		WITH te AS (
+			UPDATE time_entries
+			SET clock_in = $1, clock_out = $2, note = NULLIF($3, ''), status = 'pending',
+			    reviewed_by = NULL, reviewed_at = NULL
+			WHERE id = $4
+			RETURNING *
+		)
+		SELECT `+timeEntryColumns+`
+		FROM te
+		JOIN employees e ON te.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		LEFT JOIN shifts sh ON te.shift_id = sh.id
+	`, req.ClockIn, req.ClockOut, strings.TrimSpace(req.Note), id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Отметка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка исправления отметки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+	log.Printf("Исправлена отметка табеля %d", id)
+}
+
+// ReviewTimeEntry утверждает (approved) или отклоняет (rejected) закрытую отметку табеля
+func ReviewTimeEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/timesheets/%d/review - утверждение отметки табеля", id)
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if req.Status != "approved" && req.Status != "rejected" {
+		http.Error(w, "status должен быть approved или rejected", http.StatusBadRequest)
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Status == "rejected" && req.Note == "" {
+		http.Error(w, "Укажите причину отклонения", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	var open bool
+	err = database.DB.QueryRow(`SELECT status, clock_out IS NULL FROM time_entries WHERE id = $1`, id).Scan(&status, &open)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Отметка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if open {
+		http.Error(w, "Нельзя утвердить отметку без времени ухода", http.StatusConflict)
+		return
+	}
+	if status != "pending" {
+		http.Error(w, "Отметка уже рассмотрена", http.StatusConflict)
+		return
+	}
+
+	entry, err := scanTimeEntry(database.DB.QueryRow(`
+		WITH te AS (
+			UPDATE time_entries
+			SET status = $1, note = COALESCE(NULLIF($2, ''), note), reviewed_by = $3, reviewed_at = NOW()
+			WHERE id = $4 AND status = 'pending'
+			RETURNING *
+		)
+		SELECT `+timeEntryColumns+`
+		FROM te
+		JOIN employees e ON te.employee_id = e.id
+		JOIN users u ON e.user_id = u.id
+		LEFT JOIN shifts sh ON te.shift_id = sh.id
+	`, req.Status, req.Note, user.ID, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Отметка уже рассмотрена", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка утверждения отметки: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+	log.Printf("Отметка табеля %d: %s", id, req.Status)
+}
+
+// timesheetSummary — итоги табеля сотрудника за период
+type timesheetSummary struct {
+	EmployeeID         int    `json:"employee_id"`
+	EmployeeName       string `json:"employee_name"`
+	ScheduledMinutes   int    `json:"scheduled_minutes"`
+	WorkedMinutes      int    `json:"worked_minutes"` // без отклоненных отметок
+	ApprovedMinutes    int    `json:"approved_minutes"`
+	OvertimeMinutes    int    `json:"overtime_minutes"`
+	PendingEntries     int    `json:"pending_entries"`
+	OpenEntry          bool   `json:"open_entry"`
+	MissedShifts       int    `json:"missed_shifts"`       // прошедшие смены без отметки прихода
+	UncoveredTrainings int    `json:"uncovered_trainings"` // тренировки сотрудника вне его смен
+}
+
+// GetTimesheetSummary возвращает итоги табеля по сотрудникам за период from–to:
+// плановые и отработанные часы, переработку, неутвержденные отметки, пропущенные смены
+// и тренировки, которые тренер проводит вне своих смен
+func GetTimesheetSummary(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/timesheets/summary - итоги табеля")
+
+	from, to, err := periodFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT e.id, u.name,
+		       COALESCE((SELECT SUM(EXTRACT(EPOCH FROM (s.end_time - s.start_time)) / 60)::int
+		                 FROM shifts s WHERE s.employee_id = e.id AND s.start_time >= $1 AND s.start_time < $2), 0),
+		       (SELECT COUNT(*) FROM shifts s
+		        WHERE s.employee_id = e.id AND s.start_time >= $1 AND s.start_time < $2 AND s.end_time < NOW()
+		        AND NOT EXISTS(SELECT 1 FROM time_entries te WHERE te.shift_id = s.id))
+		FROM employees e
+		JOIN users u ON e.user_id = u.id
+		WHERE u.deleted_at IS NULL
+		ORDER BY u.name
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summaries := make([]timesheetSummary, 0)
+	index := map[int]int{}
+	for rows.Next() {
+		var s timesheetSummary
+		if err := rows.Scan(&s.EmployeeID, &s.EmployeeName, &s.ScheduledMinutes, &s.MissedShifts); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		index[s.EmployeeID] = len(summaries)
+		summaries = append(summaries, s)
+	}
+	rows.Close()
+
+	entries, err := database.DB.Query(`
+		SELECT `+timeEntryColumns+timeEntryFrom+`
+		WHERE te.clock_in >= $1 AND te.clock_in < $2
+	`, from, to)
+	if err != nil {
+		log.Printf("Ошибка запроса табеля: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer entries.Close()
+
+	for entries.Next() {
+		t, err := scanTimeEntry(entries)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		i, ok := index[t.EmployeeID]
+		if !ok {
+			continue
+		}
+		s := &summaries[i]
+		switch {
+		case t.ClockOut == nil:
+			s.OpenEntry = true
+		case t.Status == "rejected":
+			continue
+		}
+		s.WorkedMinutes += t.WorkedMinutes
+		s.OvertimeMinutes += t.OvertimeMinutes
+		if t.Status == "approved" {
+			s.ApprovedMinutes += t.WorkedMinutes
+		}
+		if t.Status == "pending" && t.ClockOut != nil {
+			s.PendingEntries++
+		}
+	}
+
+	uncovered, err := uncoveredTrainings(database.DB, from, to, 0)
+	if err != nil {
+		log.Printf("Ошибка сверки смен: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, t := range uncovered {
+		if t.EmployeeID == nil {
+			continue
+		}
+		if i, ok := index[*t.EmployeeID]; ok {
+			summaries[i].UncoveredTrainings++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
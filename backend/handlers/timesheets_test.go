@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestOvertimeMinutes(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		worked, shiftWorked, scheduled, grace int
+		last                                  bool
+		want                                  int
+	}{
+		{"без смены — вся работа переработка", 240, 0, 0, 15, true, 240},
+		{"в пределах смены", 470, 0, 480, 15, true, 0},
+		{"в пределах допуска", 490, 0, 480, 15, true, 0},
+		{"на границе допуска", 495, 0, 480, 15, true, 0},
+		{"сверх допуска — целиком", 500, 0, 480, 15, true, 20},
+		{"разорванная смена, первая отметка", 300, 0, 480, 15, false, 0},
+		{"разорванная смена, последняя отметка", 240, 300, 480, 15, true, 60},
+		{"разорванная смена без переработки", 180, 300, 480, 15, true, 0},
+		{"без допуска", 481, 0, 480, 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := overtimeMinutes(tt.worked, tt.shiftWorked, tt.scheduled, tt.grace, tt.last)
+			if got != tt.want {
+				t.Errorf("overtimeMinutes(%d, %d, %d, %d, %v) = %d, ожидалось %d",
+					tt.worked, tt.shiftWorked, tt.scheduled, tt.grace, tt.last, got, tt.want)
+			}
+		})
+	}
+}
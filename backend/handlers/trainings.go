@@ -10,14 +10,21 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 // GetTrainings возвращает список всех тренировок
 func GetTrainings(w http.ResponseWriter, r *http.Request) {
 	log.Println("GET /api/trainings - получение списка тренировок")
 
+	format, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Получаем параметры фильтрации
 	status := r.URL.Query().Get("status")
 	hallType := r.URL.Query().Get("hall_type")
@@ -26,12 +33,11 @@ func GetTrainings(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT t.id, t.trainer_id, t.title, t.description, t.type, t.hall_type, 
 		       t.start_time, t.duration_minutes, t.max_participants, t.current_participants, 
-		       t.status, t.created_at,
+		       t.status, t.price, t.created_at, t.deleted_at,
 		       u.id, u.name, u.email, u.role
 		FROM trainings t
 		LEFT JOIN users u ON t.trainer_id = u.id
-		WHERE 1=1
-	`
+		WHERE 1=1` + notDeleted(r, "t")
 	args := []interface{}{}
 	argNum := 1
 
@@ -61,27 +67,52 @@ func GetTrainings(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	// Выгрузка содержит только тренировки; участники выгружаются через /trainings/{id}/participants
+	export, err := beginExport(w, format, "trainings", "ID", "Название", "Тип", "Зал", "Начало", "Длительность, мин",
+		"ID тренера", "Тренер", "Мест", "Записано", "Статус", "Цена", "Создана", "Удалена")
+	if err != nil {
+		log.Printf("Ошибка выгрузки тренировок: %v", err)
+		return
+	}
+
 	var trainings []*models.Training
 	trainingMap := make(map[int]*models.Training)
-	
+
 	for rows.Next() {
 		var t models.Training
 		var trainer models.User
+		var deletedAt sql.NullTime
 
 		err := rows.Scan(&t.ID, &t.TrainerID, &t.Title, &t.Description, &t.Type, &t.HallType,
 			&t.StartTime, &t.DurationMinutes, &t.MaxParticipants, &t.CurrentParticipants,
-			&t.Status, &t.CreatedAt,
+			&t.Status, &t.Price, &t.CreatedAt, &deletedAt,
 			&trainer.ID, &trainer.Name, &trainer.Email, &trainer.Role)
 		if err != nil {
 			log.Printf("Ошибка сканирования: %v", err)
 			continue
 		}
+		if deletedAt.Valid {
+			t.DeletedAt = &deletedAt.Time
+		}
+
+		if export != nil {
+			if !export.Write(t.ID, t.Title, t.Type, t.HallType, t.StartTime, t.DurationMinutes, t.TrainerID,
+				trainer.Name, t.MaxParticipants, t.CurrentParticipants, t.Status, t.Price, t.CreatedAt, t.DeletedAt) {
+				return
+			}
+			continue
+		}
 
 		t.Trainer = &trainer
 		t.Participants = []models.TrainingParticipant{} // Инициализируем пустой массив
 		trainingMap[t.ID] = &t
 		trainings = append(trainings, &t)
 	}
+
+	if export != nil {
+		export.Close()
+		return
+	}
 	
 	// Загружаем участников для всех тренировок
 	if len(trainingMap) > 0 {
@@ -140,18 +171,19 @@ func GetTraining(w http.ResponseWriter, r *http.Request) {
 
 	var t models.Training
 	var trainer models.User
+	var deletedAt sql.NullTime
 
 	err = database.DB.QueryRow(`
 		SELECT t.id, t.trainer_id, t.title, t.description, t.type, t.hall_type, 
 		       t.start_time, t.duration_minutes, t.max_participants, t.current_participants, 
-		       t.status, t.created_at,
+		       t.status, t.price, t.created_at, t.deleted_at,
 		       u.id, u.name, u.email, u.role
 		FROM trainings t
 		LEFT JOIN users u ON t.trainer_id = u.id
-		WHERE t.id = $1
-	`, id).Scan(&t.ID, &t.TrainerID, &t.Title, &t.Description, &t.Type, &t.HallType,
+		WHERE t.id = $1`+notDeleted(r, "t"),
+		id).Scan(&t.ID, &t.TrainerID, &t.Title, &t.Description, &t.Type, &t.HallType,
 		&t.StartTime, &t.DurationMinutes, &t.MaxParticipants, &t.CurrentParticipants,
-		&t.Status, &t.CreatedAt,
+		&t.Status, &t.Price, &t.CreatedAt, &deletedAt,
 		&trainer.ID, &trainer.Name, &trainer.Email, &trainer.Role)
 
 	if err != nil {
@@ -164,6 +196,9 @@ func GetTraining(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if deletedAt.Valid {
+		t.DeletedAt = &deletedAt.Time
+	}
 	t.Trainer = &trainer
 
 	// Загружаем участников
@@ -193,6 +228,121 @@ func GetTraining(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(t)
 }
 
+// GetTrainingParticipants возвращает участников тренировки: записавшихся клиентов и лидов
+// на пробной тренировке (они тоже занимают места). Доступно тренеру этой тренировки и администратору.
+// status фильтрует по статусу записи (registered — включая забронированные пробные, attended,
+// cancelled, no_show). Поддерживает выгрузку format=csv|xlsx.
+func GetTrainingParticipants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("GET /api/trainings/%d/participants - получение участников тренировки", id)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var title string
+	var trainerID int
+	err = database.DB.QueryRow(`SELECT t.title, t.trainer_id FROM trainings t WHERE t.id = $1`+notDeleted(r, "t"), id).
+		Scan(&title, &trainerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Тренировка не найдена", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.Role != "admin" && trainerID != user.ID {
+		http.Error(w, "Доступ запрещен. Участников видит только тренер этой тренировки", http.StatusForbidden)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", "registered", "attended", "cancelled", "no_show":
+	default:
+		http.Error(w, "status должен быть registered, attended, cancelled или no_show", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT 'member', tp.id, tp.user_id, NULL::int, u.name, u.email, COALESCE(c.phone, ''), tp.status, tp.registered_at
+		FROM training_participants tp
+		JOIN users u ON tp.user_id = u.id
+		LEFT JOIN clients c ON c.user_id = u.id
+		WHERE tp.training_id = $1 AND ($2 = '' OR tp.status = $2)
+		UNION ALL
+		SELECT 'trial', lt.id, NULL::int, l.id, l.name, COALESCE(l.email, ''), COALESCE(l.phone, ''),
+		       CASE lt.status WHEN 'booked' THEN 'registered' ELSE lt.status END, lt.created_at
+		FROM lead_trials lt
+		JOIN leads l ON lt.lead_id = l.id
+		WHERE lt.training_id = $1 AND ($2 = '' OR lt.status = CASE $2 WHEN 'registered' THEN 'booked' ELSE $2 END)
+		ORDER BY 9, 2
+	`, id, status)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	export, err := beginExport(w, format, fmt.Sprintf("training-%d-participants", id), "Тип", "ID записи",
+		"Тренировка", "ID пользователя", "ID лида", "Имя", "Email", "Телефон", "Статус", "Записан")
+	if err != nil {
+		log.Printf("Ошибка выгрузки участников: %v", err)
+		return
+	}
+
+	attendees := make([]models.TrainingAttendee, 0)
+	for rows.Next() {
+		var a models.TrainingAttendee
+		var userID, leadID sql.NullInt64
+		if err := rows.Scan(&a.Kind, &a.ID, &userID, &leadID, &a.Name, &a.Email, &a.Phone, &a.Status, &a.RegisteredAt); err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		if userID.Valid {
+			v := int(userID.Int64)
+			a.UserID = &v
+		}
+		if leadID.Valid {
+			v := int(leadID.Int64)
+			a.LeadID = &v
+		}
+		if export != nil {
+			if !export.Write(a.Kind, a.ID, title, a.UserID, a.LeadID, a.Name, a.Email, a.Phone, a.Status, a.RegisteredAt) {
+				return
+			}
+			continue
+		}
+		attendees = append(attendees, a)
+	}
+
+	if export != nil {
+		export.Close()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attendees)
+	log.Printf("Возвращено участников: %d", len(attendees))
+}
+
 // CreateTraining создает новую тренировку
 func CreateTraining(w http.ResponseWriter, r *http.Request) {
 	log.Println("POST /api/trainings - создание тренировки")
@@ -238,7 +388,7 @@ func CreateTraining(w http.ResponseWriter, r *http.Request) {
 	} else {
 		// Проверяем, что указанный тренер существует и имеет роль trainer или admin
 		var trainerRole string
-		err = database.DB.QueryRow("SELECT role FROM users WHERE id = $1", t.TrainerID).Scan(&trainerRole)
+		err = database.DB.QueryRow("SELECT role FROM users WHERE id = $1 AND deleted_at IS NULL", t.TrainerID).Scan(&trainerRole)
 		if err != nil {
 			http.Error(w, "Тренер не найден", http.StatusBadRequest)
 			return
@@ -249,6 +399,18 @@ func CreateTraining(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Тренер должен иметь действующие сертификаты, требуемые для зала и типа тренировки
+	missing, err := missingQualifications(database.DB, t.TrainerID, t.HallType, t.Type, t.StartTime)
+	if err != nil {
+		log.Printf("Ошибка проверки квалификации: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(missing) > 0 {
+		http.Error(w, "У тренера нет действующего сертификата: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+
 	if t.Type == "group" && t.MaxParticipants < 2 {
 		http.Error(w, "Групповая тренировка должна иметь минимум 2 участника", http.StatusBadRequest)
 		return
@@ -270,11 +432,11 @@ func CreateTraining(w http.ResponseWriter, r *http.Request) {
 	var id int
 	err = database.DB.QueryRow(`
 		INSERT INTO trainings (trainer_id, title, description, type, hall_type, start_time, 
-		                       duration_minutes, max_participants, current_participants, status) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
+		                       duration_minutes, max_participants, current_participants, status, price) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) 
 		RETURNING id
 	`, t.TrainerID, t.Title, t.Description, t.Type, t.HallType, t.StartTime,
-		t.DurationMinutes, t.MaxParticipants, t.CurrentParticipants, t.Status).Scan(&id)
+		t.DurationMinutes, t.MaxParticipants, t.CurrentParticipants, t.Status, t.Price).Scan(&id)
 
 	if err != nil {
 		log.Printf("Ошибка создания тренировки: %v", err)
@@ -329,13 +491,61 @@ func UpdateTraining(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Тренировку нельзя ни изменить внутри закрытого периода, ни перенести в него
+	locked, err := trainingPayrollLocked(database.DB, id)
+	if err == nil && !locked {
+		locked, err = payrollLocked(database.DB, t.StartTime)
+	}
+	if err != nil {
+		log.Printf("Ошибка проверки закрытого периода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "Период закрыт для расчета зарплаты", http.StatusConflict)
+		return
+	}
+
+	// trainer_id можно не передавать — тогда тренер остается прежним
+	if t.TrainerID == 0 {
+		t.TrainerID = trainingTrainerID
+	} else if t.TrainerID != trainingTrainerID {
+		// Передать тренировку другому тренеру может только администратор
+		if userRole != "admin" {
+			http.Error(w, "Сменить тренера может только администратор", http.StatusForbidden)
+			return
+		}
+		var trainerRole string
+		err = database.DB.QueryRow("SELECT role FROM users WHERE id = $1 AND deleted_at IS NULL", t.TrainerID).Scan(&trainerRole)
+		if err != nil {
+			http.Error(w, "Тренер не найден", http.StatusBadRequest)
+			return
+		}
+		if trainerRole != "trainer" && trainerRole != "admin" {
+			http.Error(w, "Указанный пользователь не является тренером", http.StatusBadRequest)
+			return
+		}
+	}
+
+	missing, err := missingQualifications(database.DB, t.TrainerID, t.HallType, t.Type, t.StartTime)
+	if err != nil {
+		log.Printf("Ошибка проверки квалификации: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(missing) > 0 {
+		http.Error(w, "У тренера нет действующего сертификата: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return
+	}
+
 	_, err = database.DB.Exec(`
 		UPDATE trainings 
 		SET title = $1, description = $2, type = $3, hall_type = $4, 
-		    start_time = $5, duration_minutes = $6, max_participants = $7, status = $8
-		WHERE id = $9
+		    start_time = $5, duration_minutes = $6, max_participants = $7, status = $8, price = $9,
+		    trainer_id = $10
+		WHERE id = $11
 	`, t.Title, t.Description, t.Type, t.HallType, t.StartTime,
-		t.DurationMinutes, t.MaxParticipants, t.Status, id)
+		t.DurationMinutes, t.MaxParticipants, t.Status, t.Price, t.TrainerID, id)
 
 	if err != nil {
 		log.Printf("Ошибка обновления: %v", err)
@@ -347,7 +557,7 @@ func UpdateTraining(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Обновлена тренировка с ID: %d", id)
 }
 
-// DeleteTraining удаляет тренировку (только админ)
+// DeleteTraining помечает тренировку удаленной (только админ); записи участников сохраняются
 func DeleteTraining(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -358,15 +568,24 @@ func DeleteTraining(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("DELETE /api/trainings/%d - удаление тренировки", id)
 
-	result, err := database.DB.Exec("DELETE FROM trainings WHERE id = $1", id)
+	locked, err := trainingPayrollLocked(database.DB, id)
 	if err != nil {
-		log.Printf("Ошибка удаления: %v", err)
+		log.Printf("Ошибка проверки закрытого периода: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if locked {
+		http.Error(w, "Период закрыт для расчета зарплаты", http.StatusConflict)
+		return
+	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	deleted, err := softDelete(database.DB, "trainings", id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
 		http.Error(w, "Тренировка не найдена", http.StatusNotFound)
 		return
 	}
@@ -417,7 +636,7 @@ func RegisterForTraining(w http.ResponseWriter, r *http.Request) {
 	err = database.DB.QueryRow(`
 		SELECT max_participants, current_participants, status, type
 		FROM trainings 
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`, trainingID).Scan(&training.MaxParticipants, &training.CurrentParticipants, &training.Status, &training.Type)
 
 	if err != nil {
@@ -463,6 +682,7 @@ func RegisterForTraining(w http.ResponseWriter, r *http.Request) {
 				WHERE c.user_id = $1 
 				AND s.status = 'active' 
 				AND s.end_date >= CURRENT_DATE
+				AND s.deleted_at IS NULL
 			)
 		`, userID).Scan(&hasActiveSubscription)
 
@@ -478,6 +698,20 @@ func RegisterForTraining(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Политика клуба REQUIRE_WAIVER: без подписанного отказа от претензий и анкеты здоровья запись запрещена
+	if waiverRequired() {
+		signed, err := hasCurrentWaiver(database.DB, userID)
+		if err != nil {
+			log.Printf("Ошибка проверки отказа от претензий: %v", err)
+			http.Error(w, "Ошибка проверки отказа от претензий", http.StatusInternalServerError)
+			return
+		}
+		if !signed {
+			http.Error(w, "Для записи на тренировку необходимо подписать действующую редакцию отказа от претензий и заполнить анкету здоровья", http.StatusForbidden)
+			return
+		}
+	}
+
 	// Регистрируем
 	_, err = database.DB.Exec(`
 		INSERT INTO training_participants (training_id, user_id, status) 
@@ -611,6 +845,17 @@ func UpdateTrainingStatus(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    locked, err := trainingPayrollLocked(database.DB, id)
+    if err != nil {
+        log.Printf("Ошибка проверки закрытого периода: %v", err)
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if locked {
+        http.Error(w, "Период закрыт для расчета зарплаты", http.StatusConflict)
+        return
+    }
+
     result, err := database.DB.Exec(`UPDATE trainings SET status = $1 WHERE id = $2`, req.Status, id)
     if err != nil {
         log.Printf("Ошибка обновления статуса: %v", err)
@@ -627,3 +872,123 @@ func UpdateTrainingStatus(w http.ResponseWriter, r *http.Request) {
     w.WriteHeader(http.StatusOK)
     log.Printf("Обновлен статус тренировки %d -> %s", id, req.Status)
 }
+
+// MarkAttendance отмечает посетивших тренировку участников и начисляет им бонусные баллы.
+// Отметить можно только начавшуюся и не отмененную тренировку; повторная отметка баллы не начисляет.
+func MarkAttendance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Неверный ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("POST /api/trainings/%d/attendance - отметка посещения тренировки", id)
+
+	var req struct {
+		UserIDs []int `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		http.Error(w, "Укажите user_ids участников", http.StatusBadRequest)
+		return
+	}
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		log.Printf("Ошибка начала транзакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var status, title string
+	var startTime time.Time
+	var trainerID int
+	err = tx.QueryRow(`
+		SELECT status, title, start_time, trainer_id FROM trainings WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&status, &title, &startTime, &trainerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Тренировка не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.Role != "admin" && trainerID != user.ID {
+		http.Error(w, "Доступ запрещен. Посещение отмечает только тренер этой тренировки", http.StatusForbidden)
+		return
+	}
+	if status == "cancelled" || startTime.After(time.Now()) {
+		http.Error(w, "Отметить посещение можно только у прошедшей тренировки", http.StatusConflict)
+		return
+	}
+	locked, err := payrollLocked(tx, startTime)
+	if err != nil {
+		log.Printf("Ошибка проверки закрытого периода: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if locked {
+		http.Error(w, "Период закрыт для расчета зарплаты", http.StatusConflict)
+		return
+	}
+
+	rows, err := tx.Query(`
+		UPDATE training_participants tp SET status = 'attended'
+		FROM clients c
+		WHERE tp.training_id = $1 AND tp.user_id = ANY($2) AND tp.status <> 'cancelled' AND c.user_id = tp.user_id
+		RETURNING tp.user_id, c.id
+	`, id, pq.Array(req.UserIDs))
+	if err != nil {
+		log.Printf("Ошибка отметки посещения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attended := []int{}
+	clientIDs := []int{}
+	for rows.Next() {
+		var userID, clientID int
+		if err := rows.Scan(&userID, &clientID); err != nil {
+			rows.Close()
+			log.Printf("Ошибка сканирования: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		attended = append(attended, userID)
+		clientIDs = append(clientIDs, clientID)
+	}
+	rows.Close()
+
+	points := getEnvInt("LOYALTY_ATTENDANCE_POINTS", 10)
+	for i := range clientIDs {
+		err := awardPoints(tx, loyaltyEntry{
+			ClientID:    clientIDs[i],
+			Reason:      "attendance",
+			Points:      points,
+			TrainingID:  &id,
+			Description: "Тренировка «" + title + "»",
+		})
+		if err != nil {
+			log.Printf("Ошибка начисления баллов: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]int{"attended": attended})
+	log.Printf("Тренировка %d: отмечено посетивших %d", id, len(attended))
+}
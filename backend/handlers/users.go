@@ -19,11 +19,17 @@ import (
 // GetUsers возвращает список всех пользователей
 func GetUsers(w http.ResponseWriter, r *http.Request) {
 	log.Println("GET /api/users - получение списка пользователей")
-	
+
+	format, ok := exportRequest(w, r)
+	if !ok {
+		return
+	}
+
 	rows, err := database.DB.Query(`
-		SELECT id, name, email, role, created_at 
-		FROM users 
-		ORDER BY created_at DESC
+		SELECT u.id, u.name, u.email, u.role, u.created_at, u.deleted_at
+		FROM users u
+		WHERE 1=1` + notDeleted(r, "u") + `
+		ORDER BY u.created_at DESC
 	`)
 	if err != nil {
 		log.Printf("Ошибка запроса: %v", err)
@@ -32,16 +38,37 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	export, err := beginExport(w, format, "users", "ID", "Имя", "Email", "Роль", "Создан", "Удален")
+	if err != nil {
+		log.Printf("Ошибка выгрузки пользователей: %v", err)
+		return
+	}
+
 	var users []models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt); err != nil {
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt, &deletedAt); err != nil {
 			log.Printf("Ошибка сканирования: %v", err)
 			continue
 		}
+		if deletedAt.Valid {
+			u.DeletedAt = &deletedAt.Time
+		}
+		if export != nil {
+			if !export.Write(u.ID, u.Name, u.Email, u.Role, u.CreatedAt, u.DeletedAt) {
+				return
+			}
+			continue
+		}
 		users = append(users, u)
 	}
 
+	if export != nil {
+		export.Close()
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 	log.Printf("Возвращено пользователей: %d", len(users))
@@ -59,11 +86,12 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 	log.Printf("GET /api/users/%d - получение пользователя", id)
 
 	var u models.User
+	var deletedAt sql.NullTime
 	err = database.DB.QueryRow(`
-		SELECT id, name, email, role, created_at 
-		FROM users 
-		WHERE id = $1
-	`, id).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt)
+		SELECT u.id, u.name, u.email, u.role, u.created_at, u.deleted_at
+		FROM users u
+		WHERE u.id = $1`+notDeleted(r, "u"),
+		id).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.CreatedAt, &deletedAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -75,6 +103,10 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if deletedAt.Valid {
+		u.DeletedAt = &deletedAt.Time
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
@@ -180,7 +212,8 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Создан пользователь с ID: %d, роль: %s", id, u.Role)
 }
 
-// DeleteUser удаляет пользователя
+// DeleteUser помечает пользователя удаленным и завершает его сессии.
+// Абонементы, платежи и история тренировок сохраняются; вернуть пользователя — POST /api/users/{id}/restore
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -191,19 +224,37 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("DELETE /api/users/%d - удаление пользователя", id)
 
-	result, err := database.DB.Exec("DELETE FROM users WHERE id = $1", id)
+	tx, err := database.DB.Begin()
 	if err != nil {
-		log.Printf("Ошибка удаления: %v", err)
+		log.Printf("Ошибка начала транзакции: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	deleted, err := softDelete(tx, "users", id)
+	if err != nil {
+		log.Printf("Ошибка удаления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
 		http.Error(w, "Пользователь не найден", http.StatusNotFound)
 		return
 	}
 
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = $1", id); err != nil {
+		log.Printf("Ошибка удаления сессий: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Ошибка сохранения: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 	log.Printf("Удален пользователь с ID: %d", id)
 }
@@ -229,7 +280,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	var exists bool
 	var currentPassword sql.NullString
 	var currentRole string
-	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1), (SELECT password FROM users WHERE id = $1), (SELECT role FROM users WHERE id = $1)", id).Scan(&exists, &currentPassword, &currentRole)
+	err = database.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL), (SELECT password FROM users WHERE id = $1), (SELECT role FROM users WHERE id = $1)", id).Scan(&exists, &currentPassword, &currentRole)
 	if err != nil {
 		log.Printf("Ошибка проверки пользователя: %v", err)
 		http.Error(w, "Ошибка проверки пользователя", http.StatusInternalServerError)
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fitness-club/database"
+	"fitness-club/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// waiverRequired — включена ли политика REQUIRE_WAIVER: без подписанной действующей редакции
+// отказа от претензий клиент не может записаться на тренировку
+func waiverRequired() bool {
+	return getEnvBool("REQUIRE_WAIVER", false)
+}
+
+const waiverVersionColumns = `id, version, title, body, health_questions, published_at, created_by`
+
+func scanWaiverVersion(row interface{ Scan(...interface{}) error }) (models.WaiverVersion, error) {
+	var v models.WaiverVersion
+	var questions []byte
+	var createdBy sql.NullInt64
+	if err := row.Scan(&v.ID, &v.Version, &v.Title, &v.Body, &questions, &v.PublishedAt, &createdBy); err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(questions, &v.HealthQuestions); err != nil {
+		return v, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		v.CreatedBy = &id
+	}
+	return v, nil
+}
+
+// currentWaiver возвращает действующую (последнюю опубликованную) редакцию, sql.ErrNoRows — если редакций нет
+func currentWaiver(q queryer) (models.WaiverVersion, error) {
+	return scanWaiverVersion(q.QueryRow(`
+		SELECT ` + waiverVersionColumns + ` FROM waiver_versions ORDER BY published_at DESC, id DESC LIMIT 1
+	`))
+}
+
+const waiverSignatureColumns = `
+	cw.id, cw.client_id, cw.waiver_version_id, wv.version, cw.signed_name, cw.health_answers, cw.signed_at, cw.recorded_by
+`
+
+func scanWaiverSignature(row interface{ Scan(...interface{}) error }) (models.WaiverSignature, error) {
+	var s models.WaiverSignature
+	var answers []byte
+	var recordedBy sql.NullInt64
+	err := row.Scan(&s.ID, &s.ClientID, &s.WaiverVersionID, &s.Version, &s.SignedName, &answers, &s.SignedAt, &recordedBy)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(answers, &s.HealthAnswers); err != nil {
+		return s, err
+	}
+	if recordedBy.Valid {
+		id := int(recordedBy.Int64)
+		s.RecordedBy = &id
+	}
+	return s, nil
+}
+
+// hasCurrentWaiver проверяет, подписал ли клиент пользователя userID действующую редакцию.
+// Проверка проходит, пока ни одной редакции не опубликовано, и для пользователей без профиля клиента.
+func hasCurrentWaiver(q queryer, userID int) (bool, error) {
+	var signed bool
+	err := q.QueryRow(`
+		SELECT NOT EXISTS(SELECT 1 FROM waiver_versions)
+		    OR NOT EXISTS(SELECT 1 FROM clients WHERE user_id = $1)
+		    OR EXISTS(
+			SELECT 1
+			FROM client_waivers cw
+			JOIN clients c ON cw.client_id = c.id
+			WHERE c.user_id = $1 AND cw.waiver_version_id = (
+				SELECT id FROM waiver_versions ORDER BY published_at DESC, id DESC LIMIT 1
+			)
+		)
+	`, userID).Scan(&signed)
+	return signed, err
+}
+
+// GetCurrentWaiver возвращает действующую редакцию отказа от претензий с вопросами анкеты здоровья
+func GetCurrentWaiver(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/waivers/current - действующая редакция отказа от претензий")
+
+	v, err := currentWaiver(database.DB)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Отказ от претензий еще не опубликован", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// GetWaiverVersions возвращает все редакции отказа от претензий, новые первыми
+func GetWaiverVersions(w http.ResponseWriter, r *http.Request) {
+	log.Println("GET /api/waivers - список редакций отказа от претензий")
+
+	rows, err := database.DB.Query(`SELECT ` + waiverVersionColumns + ` FROM waiver_versions ORDER BY published_at DESC, id DESC`)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	versions := make([]models.WaiverVersion, 0)
+	for rows.Next() {
+		v, err := scanWaiverVersion(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+	log.Printf("Возвращено редакций: %d", len(versions))
+}
+
+// CreateWaiverVersion публикует новую редакцию; после этого все клиенты должны подписать ее заново
+func CreateWaiverVersion(w http.ResponseWriter, r *http.Request) {
+	log.Println("POST /api/waivers - публикация редакции отказа от претензий")
+
+	user, err := getSessionUser(r)
+	if err != nil {
+		http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		return
+	}
+
+	var v models.WaiverVersion
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	v.Version = strings.TrimSpace(v.Version)
+	v.Title = strings.TrimSpace(v.Title)
+	if v.Version == "" || v.Title == "" || strings.TrimSpace(v.Body) == "" {
+		http.Error(w, "Версия, заголовок и текст обязательны", http.StatusBadRequest)
+		return
+	}
+	questions := make([]string, 0, len(v.HealthQuestions))
+	for _, q := range v.HealthQuestions {
+		if q = strings.TrimSpace(q); q != "" {
+			questions = append(questions, q)
+		}
+	}
+	questionsJSON, _ := json.Marshal(questions)
+
+	created, err := scanWaiverVersion(database.DB.QueryRow(`
+		INSERT INTO waiver_versions (version, title, body, health_questions, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+waiverVersionColumns,
+		v.Version, v.Title, v.Body, questionsJSON, user.ID))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Редакция с такой версией уже существует", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка создания редакции: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+	log.Printf("Опубликована редакция отказа от претензий %s (ID: %d)", created.Version, created.ID)
+}
+
+// loadClientHealth читает экстренный контакт, медицинские сведения и последнюю подпись клиента
+func loadClientHealth(clientID int) (models.ClientHealth, error) {
+	h := models.ClientHealth{ClientID: clientID}
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(emergency_contact_name, ''), COALESCE(emergency_contact_phone, ''),
+		       COALESCE(emergency_contact_relation, ''), COALESCE(medical_notes, '')
+		FROM clients WHERE id = $1 AND deleted_at IS NULL
+	`, clientID).Scan(&h.EmergencyContactName, &h.EmergencyContactPhone, &h.EmergencyContactRelation, &h.MedicalNotes)
+	if err != nil {
+		return h, err
+	}
+
+	signature, err := scanWaiverSignature(database.DB.QueryRow(`
+		SELECT `+waiverSignatureColumns+`
+		FROM client_waivers cw JOIN waiver_versions wv ON cw.waiver_version_id = wv.id
+		WHERE cw.client_id = $1
+		ORDER BY wv.published_at DESC, cw.signed_at DESC
+		LIMIT 1
+	`, clientID))
+	if err == sql.ErrNoRows {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	h.Waiver = &signature
+
+	current, err := currentWaiver(database.DB)
+	if err != nil {
+		return h, err
+	}
+	h.WaiverCurrent = current.ID == signature.WaiverVersionID
+	return h, nil
+}
+
+// GetClientHealth возвращает экстренный контакт, медицинские сведения и статус отказа от претензий клиента
+func GetClientHealth(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/health - медицинские сведения клиента", clientID)
+
+	h, err := loadClientHealth(clientID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Клиент не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
+
+// UpdateClientHealth обновляет экстренный контакт и медицинские сведения; клиент может заполнить их сам
+func UpdateClientHealth(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("PUT /api/clients/%d/health - обновление медицинских сведений клиента", clientID)
+
+	var h models.ClientHealth
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.EmergencyContactName = strings.TrimSpace(h.EmergencyContactName)
+	h.EmergencyContactPhone = strings.TrimSpace(h.EmergencyContactPhone)
+	if (h.EmergencyContactName == "") != (h.EmergencyContactPhone == "") {
+		http.Error(w, "Для экстренного контакта нужны и имя, и телефон", http.StatusBadRequest)
+		return
+	}
+	if h.EmergencyContactPhone != "" && len(normalizePhone(h.EmergencyContactPhone)) < 5 {
+		http.Error(w, "Неверный телефон экстренного контакта", http.StatusBadRequest)
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE clients
+		SET emergency_contact_name = NULLIF($1, ''), emergency_contact_phone = NULLIF($2, ''),
+		    emergency_contact_relation = NULLIF($3, ''), medical_notes = NULLIF($4, '')
+		WHERE id = $5 AND deleted_at IS NULL
+	`, h.EmergencyContactName, h.EmergencyContactPhone, strings.TrimSpace(h.EmergencyContactRelation),
+		strings.TrimSpace(h.MedicalNotes), clientID)
+	if err != nil {
+		log.Printf("Ошибка обновления: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		http.Error(w, "Клиент не найден", http.StatusNotFound)
+		return
+	}
+
+	updated, err := loadClientHealth(clientID)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+	log.Printf("Обновлены медицинские сведения клиента %d", clientID)
+}
+
+// GetClientWaivers возвращает историю подписей клиента
+func GetClientWaivers(w http.ResponseWriter, r *http.Request) {
+	clientID, _, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("GET /api/clients/%d/waivers - подписи отказа от претензий", clientID)
+
+	rows, err := database.DB.Query(`
+		SELECT `+waiverSignatureColumns+`
+		FROM client_waivers cw JOIN waiver_versions wv ON cw.waiver_version_id = wv.id
+		WHERE cw.client_id = $1
+		ORDER BY cw.signed_at DESC
+	`, clientID)
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	signatures := make([]models.WaiverSignature, 0)
+	for rows.Next() {
+		s, err := scanWaiverSignature(rows)
+		if err != nil {
+			log.Printf("Ошибка сканирования: %v", err)
+			continue
+		}
+		signatures = append(signatures, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(signatures)
+}
+
+// signWaiverRequest — подпись действующей редакции с ответами анкеты здоровья
+type signWaiverRequest struct {
+	WaiverVersionID int                   `json:"waiver_version_id"` // редакция, которую видел клиент
+	SignedName      string                `json:"signed_name"`
+	HealthAnswers   []models.HealthAnswer `json:"health_answers"` // в порядке вопросов редакции
+}
+
+// SignWaiver записывает подпись клиента под действующей редакцией.
+// Клиент подписывает сам; если подпись вносит сотрудник по бумажному бланку, он сохраняется в recorded_by.
+func SignWaiver(w http.ResponseWriter, r *http.Request) {
+	clientID, user, ok := authorizeClient(w, r, false)
+	if !ok {
+		return
+	}
+
+	log.Printf("POST /api/clients/%d/waivers - подпись отказа от претензий", clientID)
+
+	var req signWaiverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.SignedName = strings.TrimSpace(req.SignedName)
+	if req.SignedName == "" {
+		http.Error(w, "Укажите ФИО подписавшего", http.StatusBadRequest)
+		return
+	}
+
+	current, err := currentWaiver(database.DB)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Отказ от претензий еще не опубликован", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Клиент должен подписывать именно тот текст, который ему показали
+	if req.WaiverVersionID != current.ID {
+		http.Error(w, "Редакция устарела, ознакомьтесь с действующей версией "+current.Version, http.StatusConflict)
+		return
+	}
+	if len(req.HealthAnswers) != len(current.HealthQuestions) {
+		http.Error(w, "Ответьте на все вопросы анкеты здоровья", http.StatusBadRequest)
+		return
+	}
+	for i := range req.HealthAnswers {
+		req.HealthAnswers[i].Question = current.HealthQuestions[i]
+		req.HealthAnswers[i].Comment = strings.TrimSpace(req.HealthAnswers[i].Comment)
+	}
+	answers, _ := json.Marshal(req.HealthAnswers)
+
+	var recordedBy interface{}
+	if user.Role != "user" {
+		recordedBy = user.ID
+	}
+
+	var signatureID int
+	err = database.DB.QueryRow(`
+		INSERT INTO client_waivers (client_id, waiver_version_id, signed_name, health_answers, recorded_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, clientID, current.ID, req.SignedName, answers, recordedBy).Scan(&signatureID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			http.Error(w, "Клиент уже подписал действующую редакцию", http.StatusConflict)
+			return
+		}
+		log.Printf("Ошибка сохранения подписи: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	signature, err := scanWaiverSignature(database.DB.QueryRow(`
+		SELECT `+waiverSignatureColumns+`
+		FROM client_waivers cw JOIN waiver_versions wv ON cw.waiver_version_id = wv.id
+		WHERE cw.id = $1
+	`, signatureID))
+	if err != nil {
+		log.Printf("Ошибка запроса: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(signature)
+	log.Printf("Клиент %d подписал отказ от претензий %s", clientID, current.Version)
+}
@@ -0,0 +1,482 @@
+// Package importer загружает клиентов и их абонементы из CSV.
+// Используется HTTP-обработчиком импорта и утилитой cmd/import.
+package importer
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"fitness-club/models"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns — поддерживаемые колонки CSV. Обязательны name и email, порядок колонок любой.
+var Columns = []string{
+	"name", "email", "password", "phone", "address", "birth_date", "is_student",
+	"subscription_type", "subscription_start", "subscription_end", "subscription_price",
+}
+
+// Options — параметры импорта
+type Options struct {
+	DryRun         bool // проверить и прогнать импорт в транзакции, затем откатить
+	BatchSize      int  // 0 — весь файл одной транзакцией; иначе фиксировать каждые BatchSize строк
+	StartRow       int  // продолжить с указанной строки файла (номер строки CSV, заголовок — 1)
+	UpdateExisting bool // обновлять данные клиентов с уже существующим email вместо пропуска
+}
+
+// RowResult — результат обработки одной строки
+type RowResult struct {
+	Row            int      `json:"row"`
+	Email          string   `json:"email,omitempty"`
+	Status         string   `json:"status"` // created, updated, skipped, error
+	Errors         []string `json:"errors,omitempty"`
+	UserID         int      `json:"user_id,omitempty"`
+	ClientID       int      `json:"client_id,omitempty"`
+	SubscriptionID int      `json:"subscription_id,omitempty"`
+}
+
+// Report — отчет об импорте
+type Report struct {
+	DryRun    bool        `json:"dry_run"`
+	TotalRows int         `json:"total_rows"`
+	Created   int         `json:"created"`
+	Updated   int         `json:"updated"`
+	Skipped   int         `json:"skipped"`
+	Failed    int         `json:"failed"`
+	Committed bool        `json:"committed"`          // изменения сохранены в БД
+	NextRow   int         `json:"next_row,omitempty"` // с какой строки продолжить после сбоя
+	Rows      []RowResult `json:"rows"`
+}
+
+// record — разобранная строка CSV
+type record struct {
+	row          int
+	name         string
+	email        string
+	password     string
+	phone        string
+	address      string
+	birthDate    *time.Time
+	isStudent    bool
+	plan         *models.SubscriptionPlan
+	start        time.Time
+	end          time.Time
+	price        models.Money
+	hasPrice     bool
+	parseErrors  []string
+	duplicateRow int
+}
+
+// Import читает CSV и загружает клиентов. Ошибка возвращается только для нечитаемого файла;
+// ошибки отдельных строк попадают в отчет.
+func Import(db *sql.DB, r io.Reader, opts Options) (*Report, error) {
+	records, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: opts.DryRun, Rows: []RowResult{}}
+	pending := make([]record, 0, len(records))
+	for _, rec := range records {
+		if rec.row < opts.StartRow {
+			continue
+		}
+		report.TotalRows++
+		if len(rec.parseErrors) > 0 {
+			report.add(RowResult{Row: rec.row, Email: rec.email, Status: "error", Errors: rec.parseErrors})
+			continue
+		}
+		pending = append(pending, rec)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 || opts.DryRun {
+		batchSize = len(pending)
+	}
+	// В режиме одной транзакции файл с ошибками не загружается совсем
+	if opts.BatchSize <= 0 && !opts.DryRun && report.Failed > 0 {
+		for _, rec := range pending {
+			report.add(RowResult{Row: rec.row, Email: rec.email, Status: "skipped",
+				Errors: []string{"файл содержит ошибки, строка не загружена"}})
+		}
+		sortRows(report)
+		return report, nil
+	}
+
+	for start := 0; start < len(pending); start += batchSize {
+		end := start + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		results, err := importBatch(db, pending[start:end], opts)
+		if err != nil {
+			report.NextRow = pending[start].row
+			for _, rec := range pending[start:] {
+				report.add(RowResult{Row: rec.row, Email: rec.email, Status: "error",
+					Errors: []string{"не загружено: " + err.Error()}})
+			}
+			break
+		}
+
+		batchFailed := false
+		for _, res := range results {
+			if res.Status == "error" {
+				batchFailed = true
+			}
+		}
+		// Одна транзакция фиксируется, только если все строки загрузились
+		if opts.BatchSize <= 0 && batchFailed && !opts.DryRun {
+			for i := range results {
+				if results[i].Status != "error" {
+					results[i].Status = "skipped"
+					results[i].Errors = append(results[i].Errors, "файл содержит ошибки, строка не загружена")
+					results[i].UserID, results[i].ClientID, results[i].SubscriptionID = 0, 0, 0
+				}
+			}
+		}
+		for _, res := range results {
+			report.add(res)
+		}
+		if !opts.DryRun && !(opts.BatchSize <= 0 && batchFailed) {
+			report.Committed = true
+		}
+	}
+
+	sortRows(report)
+	return report, nil
+}
+
+func (rep *Report) add(res RowResult) {
+	switch res.Status {
+	case "created":
+		rep.Created++
+	case "updated":
+		rep.Updated++
+	case "skipped":
+		rep.Skipped++
+	case "error":
+		rep.Failed++
+	}
+	rep.Rows = append(rep.Rows, res)
+}
+
+func sortRows(rep *Report) {
+	sort.Slice(rep.Rows, func(i, j int) bool { return rep.Rows[i].Row < rep.Rows[j].Row })
+}
+
+// importBatch загружает строки в одной транзакции. Каждая строка выполняется в своей точке сохранения,
+// поэтому ошибка строки не прерывает остальные. В режиме одной транзакции с ошибками и при DryRun
+// транзакция откатывается.
+func importBatch(db *sql.DB, records []record, opts Options) ([]RowResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]RowResult, 0, len(records))
+	failed := false
+	for _, rec := range records {
+		if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+		res := importRow(tx, rec, opts)
+		if res.Status == "error" {
+			failed = true
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				return nil, err
+			}
+		} else if _, err := tx.Exec("RELEASE SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	if opts.DryRun || opts.BatchSize <= 0 && failed {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// importRow создает или обновляет пользователя, клиента и абонемент одной строки
+func importRow(tx *sql.Tx, rec record, opts Options) RowResult {
+	res := RowResult{Row: rec.row, Email: rec.email}
+	fail := func(format string, args ...interface{}) RowResult {
+		res.Status = "error"
+		res.Errors = append(res.Errors, fmt.Sprintf(format, args...))
+		res.UserID, res.ClientID, res.SubscriptionID = 0, 0, 0
+		return res
+	}
+
+	if rec.duplicateRow > 0 {
+		res.Status = "skipped"
+		res.Errors = []string{fmt.Sprintf("email уже встречался в строке %d", rec.duplicateRow)}
+		return res
+	}
+
+	var role string
+	err := tx.QueryRow(`SELECT id, role FROM users WHERE LOWER(email) = $1`, rec.email).Scan(&res.UserID, &role)
+	switch {
+	case err == sql.ErrNoRows:
+		password := rec.password
+		if password == "" {
+			password = randomPassword()
+		}
+		err = tx.QueryRow(`
+			INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, 'user') RETURNING id
+		`, rec.name, rec.email, password).Scan(&res.UserID)
+		if err != nil {
+			return fail("ошибка создания пользователя: %v", err)
+		}
+		res.Status = "created"
+	case err != nil:
+		return fail("ошибка поиска пользователя: %v", err)
+	case !opts.UpdateExisting:
+		res.Status = "skipped"
+		res.Errors = []string{"пользователь с таким email уже существует"}
+		return res
+	case role != "user":
+		return fail("email принадлежит сотруднику (роль %s)", role)
+	default:
+		res.Status = "updated"
+	}
+
+	err = tx.QueryRow(`SELECT id FROM clients WHERE user_id = $1`, res.UserID).Scan(&res.ClientID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			INSERT INTO clients (user_id, phone, address, birth_date, is_student)
+			VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5)
+			RETURNING id
+		`, res.UserID, rec.phone, rec.address, rec.birthDate, rec.isStudent).Scan(&res.ClientID)
+	} else if err == nil {
+		_, err = tx.Exec(`
+			UPDATE clients
+			SET phone = COALESCE(NULLIF($1, ''), phone), address = COALESCE(NULLIF($2, ''), address),
+			    birth_date = COALESCE($3, birth_date), is_student = $4
+			WHERE id = $5
+		`, rec.phone, rec.address, rec.birthDate, rec.isStudent, res.ClientID)
+	}
+	if err != nil {
+		return fail("ошибка сохранения клиента: %v", err)
+	}
+
+	if rec.plan != nil {
+		var exists bool
+		err = tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM subscriptions WHERE client_id = $1 AND start_date = $2 AND type = $3)
+		`, res.ClientID, rec.start, rec.plan.Name).Scan(&exists)
+		if err != nil {
+			return fail("ошибка проверки абонемента: %v", err)
+		}
+		// Повторный импорт того же файла не создает абонемент второй раз
+		if !exists {
+			price := rec.plan.Price
+			if rec.hasPrice {
+				price = rec.price
+			}
+			status := "active"
+			if rec.end.Before(today()) {
+				status = "expired"
+			}
+			err = tx.QueryRow(`
+				INSERT INTO subscriptions (client_id, type, start_date, end_date, price, base_price,
+				                           discount_amount, discount_reason, status)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, 'Импорт', $8)
+				RETURNING id
+			`, res.ClientID, rec.plan.Name, rec.start, rec.end, price, rec.plan.Price,
+				models.MaxMoney(rec.plan.Price.Sub(price), models.NewMoney(0)), status).Scan(&res.SubscriptionID)
+			if err != nil {
+				return fail("ошибка создания абонемента: %v", err)
+			}
+		}
+	}
+	return res
+}
+
+// parse читает CSV и проверяет каждую строку
+func parse(r io.Reader) ([]record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("пустой файл")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заголовка CSV: %v", err)
+	}
+	// Excel часто сохраняет CSV с разделителем ";"
+	if len(header) == 1 && strings.Contains(header[0], ";") {
+		return nil, fmt.Errorf("используйте запятую в качестве разделителя колонок")
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !knownColumn(name) {
+			return nil, fmt.Errorf("неизвестная колонка %q, доступны: %s", name, strings.Join(Columns, ", "))
+		}
+		index[name] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("отсутствует обязательная колонка %s", required)
+		}
+	}
+
+	seen := map[string]int{}
+	records := []record{}
+	for row := 2; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV в строке %d: %v", row, err)
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		if strings.Join(fields, "") == "" {
+			continue
+		}
+
+		rec := parseRecord(row, get)
+		if rec.email != "" {
+			if first, ok := seen[rec.email]; ok {
+				rec.duplicateRow = first
+			} else {
+				seen[rec.email] = row
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func parseRecord(row int, get func(string) string) record {
+	rec := record{
+		row:      row,
+		name:     get("name"),
+		email:    strings.ToLower(get("email")),
+		password: get("password"),
+		phone:    get("phone"),
+		address:  get("address"),
+	}
+	addErr := func(format string, args ...interface{}) {
+		rec.parseErrors = append(rec.parseErrors, fmt.Sprintf(format, args...))
+	}
+
+	if rec.name == "" {
+		addErr("не указано имя")
+	}
+	if rec.email == "" {
+		addErr("не указан email")
+	} else if at := strings.Index(rec.email, "@"); at < 1 || !strings.Contains(rec.email[at:], ".") {
+		addErr("неверный email %q", rec.email)
+	}
+
+	if value := get("birth_date"); value != "" {
+		date, err := parseDate(value)
+		if err != nil || date.After(today()) {
+			addErr("неверная дата рождения %q", value)
+		} else {
+			rec.birthDate = &date
+		}
+	}
+	if value := get("is_student"); value != "" {
+		switch strings.ToLower(value) {
+		case "1", "true", "yes", "да", "+":
+			rec.isStudent = true
+		case "0", "false", "no", "нет", "-":
+		default:
+			addErr("неверное значение is_student %q", value)
+		}
+	}
+
+	planType := get("subscription_type")
+	if planType == "" {
+		for _, column := range []string{"subscription_start", "subscription_end", "subscription_price"} {
+			if get(column) != "" {
+				addErr("%s указан без subscription_type", column)
+			}
+		}
+		return rec
+	}
+	plan, ok := models.FindPlan(planType)
+	if !ok {
+		addErr("неизвестный тип абонемента %q", planType)
+		return rec
+	}
+	rec.plan = &plan
+
+	start, err := parseDate(get("subscription_start"))
+	if err != nil {
+		addErr("неверная или пустая дата начала абонемента %q", get("subscription_start"))
+		return rec
+	}
+	rec.start = start
+	rec.end = plan.EndDate(start)
+	if value := get("subscription_end"); value != "" {
+		end, err := parseDate(value)
+		if err != nil || end.Before(start) {
+			addErr("неверная дата окончания абонемента %q", value)
+		} else {
+			rec.end = end
+		}
+	}
+	if value := get("subscription_price"); value != "" {
+		price, err := models.ParseMoney(value)
+		if err != nil || price.IsNegative() {
+			addErr("неверная цена абонемента %q", value)
+		} else {
+			rec.price, rec.hasPrice = price, true
+		}
+	}
+	return rec
+}
+
+func knownColumn(name string) bool {
+	for _, c := range Columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDate принимает даты в форматах YYYY-MM-DD и DD.MM.YYYY
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "02.01.2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата %q", value)
+}
+
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func randomPassword() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(bytes)
+}
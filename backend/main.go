@@ -4,9 +4,11 @@ import (
 	"fitness-club/database"
 	"fitness-club/handlers"
 	"fitness-club/middleware"
+	"fitness-club/models"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -18,12 +20,22 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// Валюта клуба для всех денежных сумм
+	models.SetDefaultCurrency(os.Getenv("CLUB_CURRENCY"))
+
+	// Фоновые задачи: автопродление абонементов и сгорание бонусных баллов
+	handlers.StartAutoRenewal(time.Hour)
+	handlers.StartLoyaltyExpiry(time.Hour)
+
 	// Создание роутера
 	r := mux.NewRouter()
 
 	// Применение CORS middleware
 	r.Use(middleware.CORS)
 
+	// Кэш статистики сбрасывается после любых изменений данных
+	r.Use(middleware.InvalidateOnWrite(handlers.InvalidateStatsCache))
+
 	// Универсальный обработчик OPTIONS для всех путей (должен быть до других маршрутов)
 	r.Methods("OPTIONS").PathPrefix("/api").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -33,6 +45,11 @@ func main() {
 	r.HandleFunc("/api/auth/login", handlers.Login).Methods("POST")
 	r.HandleFunc("/api/auth/register", handlers.Register).Methods("POST")
 
+	// Загруженность клуба публична: EventSource не умеет передавать заголовок Authorization
+	r.HandleFunc("/api/occupancy", handlers.GetOccupancy).Methods("GET")
+	r.HandleFunc("/api/occupancy/stream", handlers.StreamOccupancy).Methods("GET")
+	r.HandleFunc("/api/occupancy/typical", handlers.GetTypicalOccupancy).Methods("GET")
+
 	// Защищенные маршруты (требуют авторизации)
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(middleware.AuthMiddleware)
@@ -47,30 +64,96 @@ func main() {
 	api.HandleFunc("/users/{id}", handlers.GetUser).Methods("GET")
 	api.Handle("/users/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateUser))).Methods("PUT")
 	api.Handle("/users/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteUser))).Methods("DELETE")
+	api.Handle("/users/{id}/restore", middleware.AdminOnly(http.HandlerFunc(handlers.RestoreUser))).Methods("POST")
+	api.HandleFunc("/users/{id}/export", handlers.ExportUserData).Methods("GET")
+	api.Handle("/users/{id}/erase", middleware.AdminOnly(http.HandlerFunc(handlers.EraseUser))).Methods("POST")
+
+	// Вход в клуб по QR-коду
+	api.Handle("/checkin", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.Checkin))).Methods("POST")
+	api.Handle("/visits", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetVisits))).Methods("GET")
+
+	// Импорт клиентов из CSV
+	api.Handle("/import/members", middleware.AdminOnly(http.HandlerFunc(handlers.ImportMembers))).Methods("POST")
 
 	// API маршруты для тренировок
 	api.HandleFunc("/trainings/{id}/register", handlers.RegisterForTraining).Methods("POST")
 	api.HandleFunc("/trainings/{id}/cancel", handlers.CancelRegistration).Methods("POST")
+	api.Handle("/trainings/{id:[0-9]+}/participants", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetTrainingParticipants))).Methods("GET")
+	api.Handle("/trainings/{id}/attendance", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.MarkAttendance))).Methods("POST")
 	api.Handle("/trainings/{id:[0-9]+}/status", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.UpdateTrainingStatus))).Methods("PUT")
 	api.HandleFunc("/trainings", handlers.GetTrainings).Methods("GET")
 	api.Handle("/trainings", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.CreateTraining))).Methods("POST")
 	api.HandleFunc("/trainings/{id}", handlers.GetTraining).Methods("GET")
 	api.Handle("/trainings/{id}", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.UpdateTraining))).Methods("PUT")
 	api.Handle("/trainings/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteTraining))).Methods("DELETE")
+	api.Handle("/trainings/{id}/restore", middleware.AdminOnly(http.HandlerFunc(handlers.RestoreTraining))).Methods("POST")
 
 	// API маршруты для клиентов
 	api.HandleFunc("/clients", handlers.GetClients).Methods("GET")
 	api.HandleFunc("/clients", handlers.CreateClient).Methods("POST")
 	api.HandleFunc("/clients/{id}", handlers.GetClient).Methods("GET")
+	api.HandleFunc("/clients/{id}/measurements", handlers.GetMeasurements).Methods("GET")
+	api.HandleFunc("/clients/{id}/measurements", handlers.CreateMeasurement).Methods("POST")
+	api.HandleFunc("/clients/{id}/measurements/{measurementId}", handlers.DeleteMeasurement).Methods("DELETE")
+	api.HandleFunc("/clients/{id}/health", handlers.GetClientHealth).Methods("GET")
+	api.HandleFunc("/clients/{id}/health", handlers.UpdateClientHealth).Methods("PUT")
+	api.HandleFunc("/clients/{id}/waivers", handlers.GetClientWaivers).Methods("GET")
+	api.HandleFunc("/clients/{id}/waivers", handlers.SignWaiver).Methods("POST")
+	api.HandleFunc("/clients/{id}/notes", handlers.GetClientNotes).Methods("GET")
+	api.HandleFunc("/clients/{id}/notes", handlers.CreateClientNote).Methods("POST")
+	api.HandleFunc("/clients/{id}/notes/{noteId}", handlers.UpdateClientNote).Methods("PUT")
+	api.HandleFunc("/clients/{id}/notes/{noteId}", handlers.DeleteClientNote).Methods("DELETE")
+	api.Handle("/follow-ups", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetFollowUps))).Methods("GET")
+	api.HandleFunc("/clients/{id}/loyalty", handlers.GetLoyalty).Methods("GET")
+	api.Handle("/clients/{id}/loyalty/adjust", middleware.AdminOnly(http.HandlerFunc(handlers.AdjustLoyalty))).Methods("POST")
+	api.HandleFunc("/clients/{id}/goals", handlers.GetGoals).Methods("GET")
+	api.HandleFunc("/clients/{id}/goals", handlers.CreateGoal).Methods("POST")
+	api.HandleFunc("/clients/{id}/goals/{goalId}", handlers.UpdateGoal).Methods("PUT")
 	api.Handle("/clients/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateClient))).Methods("PUT")
 	api.Handle("/clients/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteClient))).Methods("DELETE")
+	api.Handle("/clients/{id}/restore", middleware.AdminOnly(http.HandlerFunc(handlers.RestoreClient))).Methods("POST")
+
+	// API маршруты для лидов
+	api.Handle("/leads", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetLeads))).Methods("GET")
+	api.Handle("/leads", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.CreateLead))).Methods("POST")
+	api.Handle("/leads/stats", middleware.AdminOnly(http.HandlerFunc(handlers.GetLeadStats))).Methods("GET")
+	api.Handle("/leads/{id}", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetLead))).Methods("GET")
+	api.Handle("/leads/{id}", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.UpdateLead))).Methods("PUT")
+	api.Handle("/leads/{id}/trials", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.BookLeadTrial))).Methods("POST")
+	api.Handle("/leads/{id}/trials/{trialId}", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.UpdateLeadTrial))).Methods("PUT")
+	api.Handle("/leads/{id}/convert", middleware.AdminOnly(http.HandlerFunc(handlers.ConvertLead))).Methods("POST")
 
 	// API маршруты для абонементов
 	api.HandleFunc("/subscriptions", handlers.GetSubscriptions).Methods("GET")
 	api.HandleFunc("/subscriptions", handlers.CreateSubscription).Methods("POST")
+	api.HandleFunc("/subscriptions/quote", handlers.QuoteSubscription).Methods("POST")
 	api.HandleFunc("/subscriptions/{id}", handlers.GetSubscription).Methods("GET")
+	api.HandleFunc("/subscriptions/{id}/invoice.pdf", handlers.GetSubscriptionInvoice).Methods("GET")
+	api.Handle("/subscriptions/{id}/renew", middleware.AdminOnly(http.HandlerFunc(handlers.RenewSubscription))).Methods("POST")
+	api.Handle("/subscriptions/{id}/upgrade", middleware.AdminOnly(http.HandlerFunc(handlers.UpgradeSubscription))).Methods("POST")
+	api.Handle("/subscriptions/{id}/cancel", middleware.AdminOnly(http.HandlerFunc(handlers.CancelSubscription))).Methods("POST")
+	api.HandleFunc("/subscriptions/{id}/auto-renew", handlers.SetSubscriptionAutoRenew).Methods("PUT")
 	api.Handle("/subscriptions/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateSubscription))).Methods("PUT")
 	api.Handle("/subscriptions/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteSubscription))).Methods("DELETE")
+	api.Handle("/subscriptions/{id}/restore", middleware.AdminOnly(http.HandlerFunc(handlers.RestoreSubscription))).Methods("POST")
+
+	// API маршруты для платежей
+	api.Handle("/payments", middleware.AdminOnly(http.HandlerFunc(handlers.GetPayments))).Methods("GET")
+	api.Handle("/payments/{id}/confirm", middleware.AdminOnly(http.HandlerFunc(handlers.ConfirmPayment))).Methods("POST")
+
+	// API маршруты для промокодов
+	api.Handle("/promo-codes", middleware.AdminOnly(http.HandlerFunc(handlers.GetPromoCodes))).Methods("GET")
+	api.Handle("/promo-codes", middleware.AdminOnly(http.HandlerFunc(handlers.CreatePromoCode))).Methods("POST")
+	api.Handle("/promo-codes/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdatePromoCode))).Methods("PUT")
+	api.Handle("/promo-codes/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeletePromoCode))).Methods("DELETE")
+
+	// API маршруты для отказа от претензий
+	api.HandleFunc("/waivers/current", handlers.GetCurrentWaiver).Methods("GET")
+	api.Handle("/waivers", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetWaiverVersions))).Methods("GET")
+	api.Handle("/waivers", middleware.AdminOnly(http.HandlerFunc(handlers.CreateWaiverVersion))).Methods("POST")
+
+	// API маршруты для счетов
+	api.Handle("/invoices", middleware.AdminOnly(http.HandlerFunc(handlers.GetInvoices))).Methods("GET")
 
 	// API маршруты для сотрудников
 	api.HandleFunc("/employees", handlers.GetEmployees).Methods("GET")
@@ -79,8 +162,76 @@ func main() {
 	api.Handle("/employees/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateEmployee))).Methods("PUT")
 	api.Handle("/employees/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteEmployee))).Methods("DELETE")
 
+	// API маршруты для смен и табеля
+	api.Handle("/rota", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetRota))).Methods("GET")
+	api.Handle("/rota", middleware.AdminOnly(http.HandlerFunc(handlers.CreateRotaEntry))).Methods("POST")
+	api.Handle("/rota/apply", middleware.AdminOnly(http.HandlerFunc(handlers.ApplyRota))).Methods("POST")
+	api.Handle("/rota/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteRotaEntry))).Methods("DELETE")
+	api.Handle("/shifts", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetShifts))).Methods("GET")
+	api.Handle("/shifts", middleware.AdminOnly(http.HandlerFunc(handlers.CreateShift))).Methods("POST")
+	api.Handle("/shifts/coverage", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetShiftCoverage))).Methods("GET")
+	api.Handle("/shifts/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateShift))).Methods("PUT")
+	api.Handle("/shifts/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteShift))).Methods("DELETE")
+	api.HandleFunc("/timesheets/clock-in", handlers.ClockIn).Methods("POST")
+	api.HandleFunc("/timesheets/clock-out", handlers.ClockOut).Methods("POST")
+	api.HandleFunc("/timesheets", handlers.GetTimeEntries).Methods("GET")
+	api.Handle("/timesheets/summary", middleware.AdminOnly(http.HandlerFunc(handlers.GetTimesheetSummary))).Methods("GET")
+	api.Handle("/timesheets/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.UpdateTimeEntry))).Methods("PUT")
+	api.Handle("/timesheets/{id}/review", middleware.AdminOnly(http.HandlerFunc(handlers.ReviewTimeEntry))).Methods("POST")
+
+	// API маршруты для сертификатов тренеров
+	api.Handle("/employees/{id}/certifications", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetCertifications))).Methods("GET")
+	api.Handle("/employees/{id}/certifications", middleware.AdminOnly(http.HandlerFunc(handlers.CreateCertification))).Methods("POST")
+	api.Handle("/employees/{id}/certifications/{certId}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteCertification))).Methods("DELETE")
+	api.Handle("/employees/{id}/certifications/{certId}/document", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetCertificationDocument))).Methods("GET")
+	api.Handle("/certifications/expiring", middleware.AdminOnly(http.HandlerFunc(handlers.GetExpiringCertifications))).Methods("GET")
+	api.Handle("/qualification-requirements", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetQualificationRequirements))).Methods("GET")
+	api.Handle("/qualification-requirements", middleware.AdminOnly(http.HandlerFunc(handlers.CreateQualificationRequirement))).Methods("POST")
+	api.Handle("/qualification-requirements/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteQualificationRequirement))).Methods("DELETE")
+
+	// API маршруты для отсутствия сотрудников и замены тренеров
+	api.HandleFunc("/leave-requests", handlers.GetLeaveRequests).Methods("GET")
+	api.HandleFunc("/leave-requests", handlers.CreateLeaveRequest).Methods("POST")
+	api.HandleFunc("/leave-requests/{id}/cancel", handlers.CancelLeaveRequest).Methods("POST")
+	api.Handle("/leave-requests/{id}/approve", middleware.AdminOnly(http.HandlerFunc(handlers.ApproveLeaveRequest))).Methods("POST")
+	api.Handle("/leave-requests/{id}/reject", middleware.AdminOnly(http.HandlerFunc(handlers.RejectLeaveRequest))).Methods("POST")
+	api.Handle("/leave-requests/{id}/trainings", middleware.AdminOnly(http.HandlerFunc(handlers.GetLeaveAffectedTrainings))).Methods("GET")
+	api.Handle("/leave-requests/{id}/reassign", middleware.AdminOnly(http.HandlerFunc(handlers.ReassignLeaveTrainings))).Methods("POST")
+
+	// API маршруты для уведомлений
+	api.HandleFunc("/notifications", handlers.GetNotifications).Methods("GET")
+	api.HandleFunc("/notifications/{id}/read", handlers.MarkNotificationRead).Methods("POST")
+
+	// API маршруты для расчета зарплаты
+	api.Handle("/employees/{id}/compensation", middleware.AdminOnly(http.HandlerFunc(handlers.GetCompensationSchemes))).Methods("GET")
+	api.Handle("/employees/{id}/compensation", middleware.AdminOnly(http.HandlerFunc(handlers.CreateCompensationScheme))).Methods("POST")
+	api.Handle("/employees/{id}/compensation/{schemeId}", middleware.AdminOnly(http.HandlerFunc(handlers.DeleteCompensationScheme))).Methods("DELETE")
+	api.Handle("/payroll/runs", middleware.AdminOnly(http.HandlerFunc(handlers.GetPayrollRuns))).Methods("GET")
+	api.Handle("/payroll/runs", middleware.AdminOnly(http.HandlerFunc(handlers.CreatePayrollRun))).Methods("POST")
+	api.Handle("/payroll/runs/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.GetPayrollRun))).Methods("GET")
+	api.Handle("/payroll/runs/{id}", middleware.AdminOnly(http.HandlerFunc(handlers.DeletePayrollRun))).Methods("DELETE")
+	api.Handle("/payroll/runs/{id}/recalculate", middleware.AdminOnly(http.HandlerFunc(handlers.RecalculatePayrollRun))).Methods("POST")
+	api.Handle("/payroll/runs/{id}/lock", middleware.AdminOnly(http.HandlerFunc(handlers.LockPayrollRun))).Methods("POST")
+	api.Handle("/payroll/runs/{id}/export", middleware.AdminOnly(http.HandlerFunc(handlers.ExportPayrollRun))).Methods("GET")
+
 	// API маршруты для статистики
 	api.HandleFunc("/stats", handlers.GetStats).Methods("GET")
+	api.Handle("/stats/trainings", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetTrainingAttendanceStats))).Methods("GET")
+	api.Handle("/stats/halls", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetHallPopularityStats))).Methods("GET")
+	api.Handle("/stats/heatmap", middleware.TrainerOrAdmin(http.HandlerFunc(handlers.GetAttendanceHeatmap))).Methods("GET")
+	api.Handle("/stats/trainers", middleware.AdminOnly(http.HandlerFunc(handlers.GetTrainerUtilizationStats))).Methods("GET")
+	api.Handle("/stats/churn", middleware.AdminOnly(http.HandlerFunc(handlers.GetChurnStats))).Methods("GET")
+	api.Handle("/stats/renewals", middleware.AdminOnly(http.HandlerFunc(handlers.GetRenewalStats))).Methods("GET")
+	api.Handle("/stats/cohorts", middleware.AdminOnly(http.HandlerFunc(handlers.GetCohortStats))).Methods("GET")
+	api.Handle("/stats/at-risk", middleware.AdminOnly(http.HandlerFunc(handlers.GetAtRiskMembers))).Methods("GET")
+
+	// API маршруты для финансовых отчетов (format=csv — выгрузка в CSV)
+	api.Handle("/reports/revenue", middleware.AdminOnly(http.HandlerFunc(handlers.GetRevenueReport))).Methods("GET")
+	api.Handle("/reports/revenue-by-plan", middleware.AdminOnly(http.HandlerFunc(handlers.GetRevenueByPlanReport))).Methods("GET")
+	api.Handle("/reports/revenue-by-method", middleware.AdminOnly(http.HandlerFunc(handlers.GetRevenueByMethodReport))).Methods("GET")
+	api.Handle("/reports/member-revenue", middleware.AdminOnly(http.HandlerFunc(handlers.GetMemberRevenueReport))).Methods("GET")
+	api.Handle("/reports/outstanding", middleware.AdminOnly(http.HandlerFunc(handlers.GetOutstandingPaymentsReport))).Methods("GET")
+	api.Handle("/reports/refunds", middleware.AdminOnly(http.HandlerFunc(handlers.GetRefundsReport))).Methods("GET")
 
 	// Обработчик для несуществующих маршрутов (для отладки)
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
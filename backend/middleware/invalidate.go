@@ -0,0 +1,18 @@
+package middleware
+
+import "net/http"
+
+// InvalidateOnWrite вызывает invalidate после каждого изменяющего запроса (не GET, HEAD или OPTIONS),
+// чтобы кэшированные данные не отставали от базы
+func InvalidateOnWrite(invalidate func()) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				invalidate()
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+// Halls — залы клуба (совпадают с допустимыми значениями trainings.hall_type и visits.hall)
+var Halls = []string{"pilates", "yoga", "gym", "dance", "cardio"}
+
+// ValidHall проверяет код зала
+func ValidHall(hall string) bool {
+	for _, h := range Halls {
+		if h == hall {
+			return true
+		}
+	}
+	return false
+}
@@ -4,12 +4,13 @@ import "time"
 
 // User представляет пользователя системы
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"password,omitempty" db:"password"`
-	Role      string    `json:"role" db:"role"` // user, trainer, admin
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Email     string     `json:"email" db:"email"`
+	Password  string     `json:"password,omitempty" db:"password"`
+	Role      string     `json:"role" db:"role"` // user, trainer, admin
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 // Client представляет клиента фитнес-клуба
@@ -19,21 +20,72 @@ type Client struct {
 	Phone     string    `json:"phone" db:"phone"`
 	Address   string    `json:"address" db:"address"`
 	BirthDate *time.Time `json:"birth_date,omitempty" db:"birth_date"`
+	IsStudent bool      `json:"is_student" db:"is_student"`
+	FamilyID  *int      `json:"family_id,omitempty" db:"family_id"` // общий для членов одной семьи
+	// Вычисляемые поля списка клиентов
+	SubscriptionStatus string     `json:"subscription_status,omitempty" db:"-"` // active, expiring, expired, none
+	LastVisitAt        *time.Time `json:"last_visit_at,omitempty" db:"-"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	User      *User     `json:"user,omitempty"`
+	Notes     []ClientNote `json:"notes,omitempty"` // заметки сотрудников, только для персонала
 }
 
 // Subscription представляет абонемент
 type Subscription struct {
-	ID        int       `json:"id" db:"id"`
-	ClientID  int       `json:"client_id" db:"client_id"`
-	Type      string    `json:"type" db:"type"`
-	StartDate time.Time `json:"start_date" db:"start_date"`
-	EndDate   time.Time `json:"end_date" db:"end_date"`
-	Price     float64   `json:"price" db:"price"`
-	Status    string    `json:"status" db:"status"` // active, expired, cancelled
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	Client    *Client   `json:"client,omitempty"`
+	ID             int        `json:"id" db:"id"`
+	ClientID       int        `json:"client_id" db:"client_id"`
+	Type           string     `json:"type" db:"type"`
+	StartDate      time.Time  `json:"start_date" db:"start_date"`
+	EndDate        time.Time  `json:"end_date" db:"end_date"`
+	Price          Money      `json:"price" db:"price"`                     // итоговая цена с учетом скидок
+	BasePrice      Money      `json:"base_price" db:"base_price"`           // цена по тарифу
+	DiscountAmount Money      `json:"discount_amount" db:"discount_amount"` // сумма всех скидок
+	DiscountReason string     `json:"discount_reason,omitempty" db:"discount_reason"`
+	PromoCodeID    *int       `json:"promo_code_id,omitempty" db:"promo_code_id"`
+	PreviousID     *int       `json:"previous_subscription_id,omitempty" db:"previous_subscription_id"`
+	ChangeKind     string     `json:"change_kind,omitempty" db:"change_kind"` // renewal, upgrade
+	AutoRenew      bool       `json:"auto_renew" db:"auto_renew"`
+	Status         string     `json:"status" db:"status"` // active, expired, cancelled
+	CancelledAt    *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	RefundAmount   Money      `json:"refund_amount" db:"refund_amount"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Client         *Client    `json:"client,omitempty"`
+}
+
+// Payment представляет запись в журнале платежей
+type Payment struct {
+	ID                int        `json:"id" db:"id"`
+	SubscriptionID    *int       `json:"subscription_id,omitempty" db:"subscription_id"`
+	ClientID          *int       `json:"client_id,omitempty" db:"client_id"`
+	Kind              string     `json:"kind" db:"kind"` // payment, refund
+	Amount            Money      `json:"amount" db:"amount"`
+	Method            string     `json:"method" db:"method"` // cash, card, online, auto
+	Status            string     `json:"status" db:"status"` // pending, paid, failed
+	Provider          string     `json:"provider,omitempty" db:"provider"`
+	ProviderReference string     `json:"provider_reference,omitempty" db:"provider_reference"`
+	Description       string     `json:"description,omitempty" db:"description"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	PaidAt            *time.Time `json:"paid_at,omitempty" db:"paid_at"` // когда деньги получены; по нему считается выручка
+}
+
+// PromoCode представляет промокод на скидку при покупке абонемента
+type PromoCode struct {
+	ID              int        `json:"id" db:"id"`
+	Code            string     `json:"code" db:"code"`
+	Description     string     `json:"description" db:"description"`
+	DiscountType    string     `json:"discount_type" db:"discount_type"`               // percent, fixed
+	DiscountPercent Percent    `json:"discount_percent,omitempty" db:"discount_value"` // для percent; 12.50 — 12,5%
+	DiscountAmount  *Money     `json:"discount_amount,omitempty" db:"discount_value"`  // для fixed, в валюте клуба
+	ValidFrom       *time.Time `json:"valid_from,omitempty" db:"valid_from"`
+	ValidTo         *time.Time `json:"valid_to,omitempty" db:"valid_to"`
+	UsageLimit      *int       `json:"usage_limit,omitempty" db:"usage_limit"`
+	PerClientLimit  *int       `json:"per_client_limit,omitempty" db:"per_client_limit"`
+	Plans           []string   `json:"plans,omitempty" db:"plans"` // коды тарифов; пусто — любые
+	TimesUsed       int        `json:"times_used" db:"times_used"`
+	Active          bool       `json:"active" db:"active"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
 }
 
 // Employee представляет сотрудника
@@ -41,12 +93,165 @@ type Employee struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
 	Position  string    `json:"position" db:"position"`
-	Salary    *float64  `json:"salary,omitempty" db:"salary"`
+	Salary    *Money    `json:"salary,omitempty" db:"salary"`
 	HireDate  time.Time `json:"hire_date" db:"hire_date"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	User      *User     `json:"user,omitempty"`
 }
 
+// CompensationScheme — схема оплаты сотрудника на период действия
+type CompensationScheme struct {
+	ID          int        `json:"id" db:"id"`
+	EmployeeID  int        `json:"employee_id" db:"employee_id"`
+	SchemeType  string     `json:"scheme_type" db:"scheme_type"`     // fixed_monthly, per_training, per_participant, personal_revenue_percent
+	RateAmount  *Money     `json:"rate_amount,omitempty" db:"rate"`  // сумма в валюте клуба; для personal_revenue_percent пусто
+	RatePercent Percent    `json:"rate_percent,omitempty" db:"rate"` // процент выручки для personal_revenue_percent
+	ValidFrom   time.Time  `json:"valid_from" db:"valid_from"`
+	ValidTo     *time.Time `json:"valid_to,omitempty" db:"valid_to"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PayrollRun — расчет зарплаты за период
+type PayrollRun struct {
+	ID           int           `json:"id" db:"id"`
+	PeriodStart  time.Time     `json:"period_start" db:"period_start"`
+	PeriodEnd    time.Time     `json:"period_end" db:"period_end"`
+	Status       string        `json:"status" db:"status"` // draft, locked
+	Total        Money         `json:"total" db:"total"`
+	CreatedBy    *int          `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	CalculatedAt time.Time     `json:"calculated_at" db:"calculated_at"`
+	LockedBy     *int          `json:"locked_by,omitempty" db:"locked_by"`
+	LockedAt     *time.Time    `json:"locked_at,omitempty" db:"locked_at"`
+	Lines        []PayrollLine `json:"lines,omitempty"`
+}
+
+// PayrollLine — начисление сотруднику по одной схеме оплаты
+type PayrollLine struct {
+	ID           int     `json:"id" db:"id"`
+	EmployeeID   *int    `json:"employee_id,omitempty" db:"employee_id"`
+	EmployeeName string  `json:"employee_name" db:"employee_name"`
+	SchemeID     *int    `json:"scheme_id,omitempty" db:"scheme_id"`
+	SchemeType   string  `json:"scheme_type" db:"scheme_type"`
+	Description  string  `json:"description" db:"description"`
+	Quantity     float64 `json:"quantity" db:"quantity"` // тренировки, участники, доля месяца или выручка
+	RateAmount   *Money  `json:"rate_amount,omitempty" db:"rate"`
+	RatePercent  Percent `json:"rate_percent,omitempty" db:"rate"`
+	Amount       Money   `json:"amount" db:"amount"`
+}
+
+// RotaEntry — повторяющаяся смена недельного графика
+type RotaEntry struct {
+	ID           int       `json:"id" db:"id"`
+	EmployeeID   int       `json:"employee_id" db:"employee_id"`
+	EmployeeName string    `json:"employee_name,omitempty" db:"-"`
+	Weekday      int       `json:"weekday" db:"weekday"`       // 1 — понедельник, 7 — воскресенье
+	StartTime    string    `json:"start_time" db:"start_time"` // HH:MM
+	EndTime      string    `json:"end_time" db:"end_time"`     // HH:MM
+	Role         string    `json:"role" db:"role"`             // reception, trainer, cleaner, manager, other
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// Shift — смена сотрудника на конкретную дату
+type Shift struct {
+	ID           int       `json:"id" db:"id"`
+	EmployeeID   int       `json:"employee_id" db:"employee_id"`
+	EmployeeName string    `json:"employee_name,omitempty" db:"-"`
+	Role         string    `json:"role" db:"role"` // reception, trainer, cleaner, manager, other
+	StartTime    time.Time `json:"start_time" db:"start_time"`
+	EndTime      time.Time `json:"end_time" db:"end_time"`
+	RotaEntryID  *int      `json:"rota_entry_id,omitempty" db:"rota_entry_id"`
+	Notes        string    `json:"notes,omitempty" db:"notes"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// TimeEntry — отметка прихода и ухода сотрудника в табеле
+type TimeEntry struct {
+	ID              int        `json:"id" db:"id"`
+	EmployeeID      int        `json:"employee_id" db:"employee_id"`
+	EmployeeName    string     `json:"employee_name,omitempty" db:"-"`
+	ShiftID         *int       `json:"shift_id,omitempty" db:"shift_id"`
+	ClockIn         time.Time  `json:"clock_in" db:"clock_in"`
+	ClockOut        *time.Time `json:"clock_out,omitempty" db:"clock_out"`
+	Status          string     `json:"status" db:"status"` // pending, approved, rejected
+	Note            string     `json:"note,omitempty" db:"note"`
+	ReviewedBy      *int       `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	WorkedMinutes   int        `json:"worked_minutes" db:"-"`
+	OvertimeMinutes int        `json:"overtime_minutes" db:"-"` // сверх смены с учетом допуска, на последней отметке смены; без смены — все время
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Certification — сертификат сотрудника, дающий право вести тренировки определенного вида
+type Certification struct {
+	ID           int        `json:"id" db:"id"`
+	EmployeeID   int        `json:"employee_id" db:"employee_id"`
+	EmployeeName string     `json:"employee_name,omitempty" db:"-"`
+	CertType     string     `json:"cert_type" db:"cert_type"`
+	Issuer       string     `json:"issuer" db:"issuer"`
+	IssuedAt     *time.Time `json:"issued_at,omitempty" db:"issued_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	DocumentName string     `json:"document_name,omitempty" db:"document_name"`
+	HasDocument  bool       `json:"has_document" db:"-"`
+	DaysLeft     *int       `json:"days_left,omitempty" db:"-"` // только в уведомлениях об истечении
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// QualificationRequirement — сертификат, обязательный для тренировок в зале и/или определенного типа
+type QualificationRequirement struct {
+	ID           int       `json:"id" db:"id"`
+	HallType     *string   `json:"hall_type,omitempty" db:"hall_type"`         // пусто — любой зал
+	TrainingType *string   `json:"training_type,omitempty" db:"training_type"` // пусто — любой тип
+	CertType     string    `json:"cert_type" db:"cert_type"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// LeaveRequest — заявка сотрудника на отсутствие (больничный, отпуск)
+type LeaveRequest struct {
+	ID            int        `json:"id" db:"id"`
+	EmployeeID    int        `json:"employee_id" db:"employee_id"`
+	EmployeeName  string     `json:"employee_name,omitempty" db:"-"`
+	LeaveType     string     `json:"leave_type" db:"leave_type"` // sick, vacation, other
+	StartDate     time.Time  `json:"start_date" db:"start_date"`
+	EndDate       time.Time  `json:"end_date" db:"end_date"`
+	Reason        string     `json:"reason,omitempty" db:"reason"`
+	Status        string     `json:"status" db:"status"` // pending, approved, rejected, cancelled
+	ReviewComment string     `json:"review_comment,omitempty" db:"review_comment"`
+	ReviewedBy    *int       `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedBy     *int       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AffectedTraining — тренировка отсутствующего тренера с возможными заменами
+type AffectedTraining struct {
+	TrainingID   int              `json:"training_id"`
+	Title        string           `json:"title"`
+	Type         string           `json:"type"`
+	HallType     string           `json:"hall_type"`
+	StartTime    time.Time        `json:"start_time"`
+	Participants int              `json:"participants"`
+	Substitutes  []SubstituteUser `json:"substitutes"` // квалифицированные и свободные тренеры
+}
+
+// SubstituteUser — тренер, который может провести тренировку вместо отсутствующего
+type SubstituteUser struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// Notification — уведомление пользователю
+type Notification struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Kind       string     `json:"kind" db:"kind"` // trainer_changed
+	Title      string     `json:"title" db:"title"`
+	Message    string     `json:"message" db:"message"`
+	TrainingID *int       `json:"training_id,omitempty" db:"training_id"`
+	ReadAt     *time.Time `json:"read_at,omitempty" db:"read_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
 // Session представляет сессию пользователя
 type Session struct {
 	ID        int       `json:"id" db:"id"`
@@ -70,7 +275,9 @@ type Training struct {
 	MaxParticipants    int       `json:"max_participants" db:"max_participants"`
 	CurrentParticipants int      `json:"current_participants" db:"current_participants"`
 	Status             string    `json:"status" db:"status"` // scheduled, completed, cancelled
+	Price              *Money    `json:"price,omitempty" db:"price"` // стоимость персональной тренировки
 	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	Trainer            *User     `json:"trainer,omitempty"`
 	Participants       []TrainingParticipant `json:"participants,omitempty"`
 }
@@ -85,6 +292,202 @@ type TrainingParticipant struct {
 	User         *User     `json:"user,omitempty"`
 }
 
+// TrainingAttendee — строка списка участников тренировки: клиент (member) или лид на пробной тренировке (trial)
+type TrainingAttendee struct {
+	Kind         string    `json:"kind"` // member, trial
+	ID           int       `json:"id"`   // ID записи участника или пробной тренировки
+	UserID       *int      `json:"user_id,omitempty"`
+	LeadID       *int      `json:"lead_id,omitempty"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email,omitempty"`
+	Phone        string    `json:"phone,omitempty"`
+	Status       string    `json:"status"` // registered, attended, cancelled, no_show
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// Invoice представляет счет-квитанцию по проданному абонементу
+type Invoice struct {
+	ID             int       `json:"id" db:"id"`
+	SubscriptionID *int      `json:"subscription_id,omitempty" db:"subscription_id"`
+	Year           int       `json:"year" db:"year"`
+	SequenceNumber int       `json:"sequence_number" db:"sequence_number"`
+	Number         string    `json:"number" db:"number"`
+	ClientName     string    `json:"client_name" db:"client_name"`
+	ClientEmail    string    `json:"client_email" db:"client_email"`
+	Description    string    `json:"description" db:"description"`
+	Amount         Money     `json:"amount" db:"amount"`
+	IssuedAt       time.Time `json:"issued_at" db:"issued_at"`
+	// Состояние оплаты на момент выставления: по нему в PDF ставится отметка об оплате
+	PaymentStatus string     `json:"payment_status,omitempty" db:"payment_status"` // pending, paid, failed
+	PaidAt        *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+	PDF           []byte     `json:"-" db:"pdf"`
+}
+
+// Visit представляет посещение клуба: вход и выход клиента
+type Visit struct {
+	ID             int        `json:"id" db:"id"`
+	ClientID       int        `json:"client_id" db:"client_id"`
+	SubscriptionID *int       `json:"subscription_id,omitempty" db:"subscription_id"`
+	Hall           string     `json:"hall" db:"hall"` // pilates, yoga, gym, dance, cardio
+	CheckedInAt    time.Time  `json:"checked_in_at" db:"checked_in_at"`
+	CheckedOutAt   *time.Time `json:"checked_out_at,omitempty" db:"checked_out_at"`
+	CheckedInBy    *int       `json:"checked_in_by,omitempty" db:"checked_in_by"`
+	CheckedOutBy   *int       `json:"checked_out_by,omitempty" db:"checked_out_by"`
+	Client         *Client    `json:"client,omitempty"`
+}
+
+// Measurement представляет замер тела и результаты контрольных упражнений клиента
+type Measurement struct {
+	ID             int                `json:"id" db:"id"`
+	ClientID       int                `json:"client_id" db:"client_id"`
+	MeasuredAt     time.Time          `json:"measured_at" db:"measured_at"`
+	WeightKg       *float64           `json:"weight_kg,omitempty" db:"weight_kg"`
+	BodyFatPercent *float64           `json:"body_fat_percent,omitempty" db:"body_fat_percent"`
+	ChestCm        *float64           `json:"chest_cm,omitempty" db:"chest_cm"`
+	WaistCm        *float64           `json:"waist_cm,omitempty" db:"waist_cm"`
+	HipsCm         *float64           `json:"hips_cm,omitempty" db:"hips_cm"`
+	ArmCm          *float64           `json:"arm_cm,omitempty" db:"arm_cm"`
+	ThighCm        *float64           `json:"thigh_cm,omitempty" db:"thigh_cm"`
+	Benchmarks     map[string]float64 `json:"benchmarks" db:"benchmarks"` // например {"bench_press_kg": 80, "run_5km_min": 27.5}
+	Notes          string             `json:"notes,omitempty" db:"notes"`
+	RecordedBy     *int               `json:"recorded_by,omitempty" db:"recorded_by"`
+	CreatedAt      time.Time          `json:"created_at" db:"created_at"`
+}
+
+// Goal представляет цель клиента по одному из показателей
+type Goal struct {
+	ID          int        `json:"id" db:"id"`
+	ClientID    int        `json:"client_id" db:"client_id"`
+	Metric      string     `json:"metric" db:"metric"` // weight_kg, waist_cm, ... или контрольное упражнение
+	StartValue  *float64   `json:"start_value,omitempty" db:"start_value"`
+	TargetValue float64    `json:"target_value" db:"target_value"`
+	TargetDate  *time.Time `json:"target_date,omitempty" db:"target_date"`
+	Status      string     `json:"status" db:"status"` // active, achieved, abandoned
+	Notes       string     `json:"notes,omitempty" db:"notes"`
+	CreatedBy   *int       `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	AchievedAt  *time.Time `json:"achieved_at,omitempty" db:"achieved_at"`
+	// Вычисляемые поля
+	CurrentValue *float64 `json:"current_value,omitempty" db:"-"`
+	Progress     *float64 `json:"progress_percent,omitempty" db:"-"`
+}
+
+// ClientHealth — экстренный контакт и медицинские сведения клиента, видны только сотрудникам и самому клиенту
+type ClientHealth struct {
+	ClientID                 int              `json:"client_id"`
+	EmergencyContactName     string           `json:"emergency_contact_name"`
+	EmergencyContactPhone    string           `json:"emergency_contact_phone"`
+	EmergencyContactRelation string           `json:"emergency_contact_relation"`
+	MedicalNotes             string           `json:"medical_notes"`
+	Waiver                   *WaiverSignature `json:"waiver,omitempty"` // последняя подпись
+	WaiverCurrent            bool             `json:"waiver_current"`   // подписана действующая редакция
+}
+
+// WaiverVersion — редакция отказа от претензий с анкетой здоровья
+type WaiverVersion struct {
+	ID              int       `json:"id" db:"id"`
+	Version         string    `json:"version" db:"version"`
+	Title           string    `json:"title" db:"title"`
+	Body            string    `json:"body" db:"body"`
+	HealthQuestions []string  `json:"health_questions" db:"health_questions"`
+	PublishedAt     time.Time `json:"published_at" db:"published_at"`
+	CreatedBy       *int      `json:"created_by,omitempty" db:"created_by"`
+}
+
+// HealthAnswer — ответ на вопрос анкеты здоровья
+type HealthAnswer struct {
+	Question string `json:"question"`
+	Answer   bool   `json:"answer"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// WaiverSignature — подпись клиента под редакцией отказа от претензий
+type WaiverSignature struct {
+	ID              int            `json:"id" db:"id"`
+	ClientID        int            `json:"client_id" db:"client_id"`
+	WaiverVersionID int            `json:"waiver_version_id" db:"waiver_version_id"`
+	Version         string         `json:"version" db:"-"`
+	SignedName      string         `json:"signed_name" db:"signed_name"`
+	HealthAnswers   []HealthAnswer `json:"health_answers" db:"health_answers"`
+	SignedAt        time.Time      `json:"signed_at" db:"signed_at"`
+	RecordedBy      *int           `json:"recorded_by,omitempty" db:"recorded_by"` // сотрудник, внесший бумажную подпись
+}
+
+// ClientNote — заметка сотрудника о клиенте или запись о контакте с ним (звонок, визит, письмо)
+type ClientNote struct {
+	ID             int        `json:"id" db:"id"`
+	ClientID       int        `json:"client_id" db:"client_id"`
+	ClientName     string     `json:"client_name,omitempty" db:"-"`
+	AuthorID       *int       `json:"author_id,omitempty" db:"author_id"`
+	AuthorName     string     `json:"author_name,omitempty" db:"-"`
+	Kind           string     `json:"kind" db:"kind"`             // note, call, visit, email, message
+	Visibility     string     `json:"visibility" db:"visibility"` // staff — только администраторы, trainers — и тренеры
+	Body           string     `json:"body" db:"body"`
+	Tags           []string   `json:"tags" db:"tags"`
+	FollowUpAt     *time.Time `json:"follow_up_at,omitempty" db:"follow_up_at"`
+	AssignedTo     *int       `json:"assigned_to,omitempty" db:"assigned_to"` // кому напомнить, по умолчанию автору
+	FollowUpDoneAt *time.Time `json:"follow_up_done_at,omitempty" db:"follow_up_done_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Lead — потенциальный клиент, который еще ничего не купил
+type Lead struct {
+	ID              int         `json:"id" db:"id"`
+	Name            string      `json:"name" db:"name"`
+	Email           string      `json:"email,omitempty" db:"email"`
+	Phone           string      `json:"phone,omitempty" db:"phone"`
+	Source          string      `json:"source" db:"source"` // walk_in, website, referral, social, ads, partner, other
+	Status          string      `json:"status" db:"status"` // new, contacted, trial_booked, converted, lost
+	Notes           string      `json:"notes,omitempty" db:"notes"`
+	LostReason      string      `json:"lost_reason,omitempty" db:"lost_reason"`
+	AssignedTo      *int        `json:"assigned_to,omitempty" db:"assigned_to"`
+	ConvertedUserID *int        `json:"converted_user_id,omitempty" db:"converted_user_id"`
+	ConvertedAt     *time.Time  `json:"converted_at,omitempty" db:"converted_at"`
+	CreatedBy       *int        `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	Trials          []LeadTrial `json:"trials,omitempty"`
+}
+
+// LeadTrial — бесплатная пробная тренировка потенциального клиента
+type LeadTrial struct {
+	ID            int       `json:"id" db:"id"`
+	LeadID        int       `json:"lead_id" db:"lead_id"`
+	TrainingID    int       `json:"training_id" db:"training_id"`
+	TrainingTitle string    `json:"training_title,omitempty" db:"-"`
+	StartTime     time.Time `json:"start_time" db:"-"`
+	Status        string    `json:"status" db:"status"` // booked, attended, no_show, cancelled
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// LoyaltyTransaction — запись журнала бонусных баллов: начисление (points > 0) или списание (points < 0)
+type LoyaltyTransaction struct {
+	ID               int        `json:"id" db:"id"`
+	ClientID         int        `json:"client_id" db:"client_id"`
+	Reason           string     `json:"reason" db:"reason"` // attendance, checkin, purchase, referral, redemption, expiry, adjustment, reversal
+	Points           int        `json:"points" db:"points"`
+	Remaining        int        `json:"remaining" db:"remaining"` // неизрасходованный остаток начисления
+	ExpiresAt        *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	TrainingID       *int       `json:"training_id,omitempty" db:"training_id"`
+	SubscriptionID   *int       `json:"subscription_id,omitempty" db:"subscription_id"`
+	ReferralClientID *int       `json:"referral_client_id,omitempty" db:"referral_client_id"`
+	Description      string     `json:"description,omitempty" db:"description"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// LoyaltyAccount — бонусный счет клиента
+type LoyaltyAccount struct {
+	ClientID       int                  `json:"client_id"`
+	Balance        int                  `json:"balance"`
+	ExpiringPoints int                  `json:"expiring_points"` // сгорят в ближайшие 30 дней
+	NextExpiry     *time.Time           `json:"next_expiry,omitempty"`
+	ReferralCode   string               `json:"referral_code"`
+	ReferredBy     *int                 `json:"referred_by,omitempty"`
+	Referrals      int                  `json:"referrals"` // сколько приглашенных оплатили абонемент
+	Transactions   []LoyaltyTransaction `json:"transactions"`
+}
+
 // LoginRequest представляет запрос на вход
 type LoginRequest struct {
 	Email    string `json:"email"`
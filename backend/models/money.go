@@ -0,0 +1,318 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency — валюта клуба, в которой хранятся все суммы (задается через CLUB_CURRENCY)
+var DefaultCurrency = "RUB"
+
+// SetDefaultCurrency меняет валюту клуба; пустое значение оставляет текущую
+func SetDefaultCurrency(code string) {
+	if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+		DefaultCurrency = code
+	}
+}
+
+// Money — денежная сумма в минимальных единицах валюты (копейках).
+// В JSON передается как {"amount": "2000.00", "currency": "RUB"}, в БД — как DECIMAL(10,2).
+type Money struct {
+	Amount   int64  // сумма в копейках
+	Currency string // код валюты ISO 4217
+}
+
+// NewMoney создает сумму из копеек в валюте клуба
+func NewMoney(minor int64) Money {
+	return Money{Amount: minor, Currency: DefaultCurrency}
+}
+
+// Rubles создает сумму из целого числа основных единиц валюты клуба
+func Rubles(units int64) Money {
+	return NewMoney(units * 100)
+}
+
+// ParseMoney разбирает сумму вида "2000", "2000.5" или "2000,50" в валюте клуба
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	if s == "" {
+		return Money{}, fmt.Errorf("пустая сумма")
+	}
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > 2 {
+		// DECIMAL(10,2) не хранит больше двух знаков, лишние допускаются только нулями
+		if strings.Trim(frac[2:], "0") != "" {
+			return Money{}, fmt.Errorf("сумма %q содержит больше двух знаков после запятой", s)
+		}
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("неверная сумма %q", s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || cents < 0 {
+		return Money{}, fmt.Errorf("неверная сумма %q", s)
+	}
+	m := NewMoney(units*100 + cents)
+	if negative {
+		m.Amount = -m.Amount
+	}
+	return m, nil
+}
+
+// currency возвращает валюту суммы; у нулевого значения Money это валюта клуба
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// String возвращает сумму с двумя знаками после точки, например "2000.00"
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// Float64 возвращает сумму в основных единицах; только для отображения и графиков
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / 100
+}
+
+// Add возвращает сумму двух значений
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currency()}
+}
+
+// Sub возвращает разность двух значений
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currency()}
+}
+
+// Mul возвращает сумму, умноженную на целое число
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.currency()}
+}
+
+// MulRatio возвращает m * num / den с округлением до копейки (половина — от нуля)
+func (m Money) MulRatio(num, den int64) Money {
+	if den == 0 {
+		return Money{Currency: m.currency()}
+	}
+	product := m.Amount * num
+	result := product / den
+	if remainder := product % den; remainder != 0 && 2*abs64(remainder) >= abs64(den) {
+		if (product < 0) != (den < 0) {
+			result--
+		} else {
+			result++
+		}
+	}
+	return Money{Amount: result, Currency: m.currency()}
+}
+
+// Percent возвращает указанный процент от суммы
+func (m Money) Percent(p Percent) Money {
+	return m.MulRatio(int64(p), 10000)
+}
+
+// IsZero сообщает, что сумма равна нулю
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// IsPositive сообщает, что сумма больше нуля
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// IsNegative сообщает, что сумма меньше нуля
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Less сообщает, что сумма меньше другой
+func (m Money) Less(o Money) bool { return m.Amount < o.Amount }
+
+// MinMoney возвращает меньшую из сумм
+func MinMoney(a, b Money) Money {
+	if b.Amount < a.Amount {
+		return b
+	}
+	return a
+}
+
+// MaxMoney возвращает большую из сумм
+func MaxMoney(a, b Money) Money {
+	if b.Amount > a.Amount {
+		return b
+	}
+	return a
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON кодирует сумму строкой, чтобы клиенты не теряли точность на float
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency()})
+}
+
+// UnmarshalJSON принимает объект {"amount", "currency"}, строку "2000.00" или число 2000.
+// Валюта, отличная от валюты клуба, отклоняется.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		var v moneyJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(v.Amount)
+		if err != nil {
+			return err
+		}
+		// Суммы хранятся только в валюте клуба, пересчета курсов нет
+		if v.Currency != "" && !strings.EqualFold(strings.TrimSpace(v.Currency), DefaultCurrency) {
+			return fmt.Errorf("валюта %s не поддерживается, суммы принимаются только в %s", v.Currency, DefaultCurrency)
+		}
+		*m = parsed
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(s)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	default:
+		parsed, err := ParseMoney(string(data))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+}
+
+// Scan читает сумму из колонки DECIMAL
+func (m *Money) Scan(value interface{}) error {
+	var parsed Money
+	var err error
+	switch v := value.(type) {
+	case nil:
+		parsed = NewMoney(0)
+	case []byte:
+		parsed, err = ParseMoney(string(v))
+	case string:
+		parsed, err = ParseMoney(v)
+	case int64:
+		parsed = Rubles(v)
+	case float64:
+		parsed = NewMoney(int64(math.Round(v * 100)))
+	default:
+		return fmt.Errorf("не удается прочитать сумму из %T", value)
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value записывает сумму в колонку DECIMAL
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Percent — процент в сотых долях (базисных пунктах): 1250 — это 12,5%.
+// В JSON и БД передается десятичным числом с двумя знаками: 12.50.
+type Percent int64
+
+// WholePercent создает процент из целого числа процентов
+func WholePercent(n int) Percent {
+	return Percent(n * 100)
+}
+
+// ParsePercent разбирает процент вида "15", "12.5" или "12,50"
+func ParsePercent(s string) (Percent, error) {
+	m, err := ParseMoney(s)
+	if err != nil {
+		return 0, fmt.Errorf("неверный процент %q", s)
+	}
+	return Percent(m.Amount), nil
+}
+
+// String возвращает процент с двумя знаками после точки, например "12.50"
+func (p Percent) String() string {
+	return NewMoney(int64(p)).String()
+}
+
+// MarshalJSON кодирует процент числом
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON принимает число 12.5 или строку "12.5"
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParsePercent(s)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// Scan читает процент из колонки DECIMAL
+func (p *Percent) Scan(value interface{}) error {
+	var m Money
+	if err := m.Scan(value); err != nil {
+		return err
+	}
+	*p = Percent(m.Amount)
+	return nil
+}
+
+// Value записывает процент в колонку DECIMAL
+func (p Percent) Value() (driver.Value, error) {
+	return p.String(), nil
+}
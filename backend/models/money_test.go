@@ -0,0 +1,252 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"2000", 200000, false},
+		{"2000.5", 200050, false},
+		{"2000,50", 200050, false},
+		{" 12.30 ", 1230, false},
+		{".75", 75, false},
+		{"+1", 100, false},
+		{"-0.50", -50, false},
+		{"10.500", 1050, false},
+		{"10.505", 0, true},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"1.x", 0, true},
+		{"1.-5", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %v, ожидалась ошибка", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != DefaultCurrency {
+			t.Errorf("ParseMoney(%q) = %d %s, ожидалось %d %s", tt.in, got.Amount, got.Currency, tt.want, DefaultCurrency)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{200050, "2000.50"},
+		{-5, "-0.05"},
+		{-123456, "-1234.56"},
+	}
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount).String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String() = %q, ожидалось %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		amount, num, den int64
+		want             int64
+	}{
+		{1000, 1, 3, 333},
+		{2000, 1, 3, 667},
+		{100, 1, 2, 50},
+		{1, 1, 2, 1},   // половина копейки округляется от нуля
+		{-1, 1, 2, -1}, // и для отрицательных сумм
+		{3, 1, 4, 1},
+		{1, 1, 4, 0},
+		{-1000, 1, 3, -333},
+		{-2000, 1, 3, -667},
+		{1000, 1, -3, -333},
+		{1000, 5, 0, 0},
+	}
+	for _, tt := range tests {
+		got := NewMoney(tt.amount).MulRatio(tt.num, tt.den)
+		if got.Amount != tt.want {
+			t.Errorf("%d * %d / %d = %d, ожидалось %d", tt.amount, tt.num, tt.den, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmeticKeepsCurrency(t *testing.T) {
+	m := Money{Amount: 1000, Currency: "EUR"}
+	for name, got := range map[string]Money{
+		"Add":      m.Add(NewMoney(1)),
+		"Sub":      m.Sub(NewMoney(1)),
+		"Mul":      m.Mul(2),
+		"MulRatio": m.MulRatio(1, 2),
+		"Percent":  m.Percent(WholePercent(10)),
+	} {
+		if got.Currency != "EUR" {
+			t.Errorf("%s: валюта %q, ожидалась EUR", name, got.Currency)
+		}
+	}
+	if got := (Money{Amount: 100}).Add(NewMoney(50)); got.Currency != DefaultCurrency || got.Amount != 150 {
+		t.Errorf("нулевая валюта: получено %d %s", got.Amount, got.Currency)
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		percent Percent
+		want    int64
+	}{
+		{200000, WholePercent(10), 20000},
+		{200000, 1250, 25000},
+		{999, WholePercent(15), 150}, // 149,85 копейки
+		{100, 1, 0},
+		{200000, WholePercent(100), 200000},
+		{200000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount).Percent(tt.percent); got.Amount != tt.want {
+			t.Errorf("%s%% от %d = %d, ожидалось %d", tt.percent, tt.amount, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMinMaxMoney(t *testing.T) {
+	a, b := NewMoney(100), NewMoney(-50)
+	if got := MinMoney(a, b); got.Amount != -50 {
+		t.Errorf("MinMoney = %d, ожидалось -50", got.Amount)
+	}
+	if got := MaxMoney(a, b); got.Amount != 100 {
+		t.Errorf("MaxMoney = %d, ожидалось 100", got.Amount)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(Money{Amount: 200050, Currency: "RUB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":"2000.50","currency":"RUB"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	tests := []struct {
+		in       string
+		amount   int64
+		currency string
+	}{
+		{`{"amount": "15.5", "currency": "rub"}`, 1550, DefaultCurrency},
+		{`{"amount": "15.5"}`, 1550, DefaultCurrency},
+		{`"2000,00"`, 200000, DefaultCurrency},
+		{`2000.5`, 200050, DefaultCurrency},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if m.Amount != tt.amount || m.Currency != tt.currency {
+			t.Errorf("Unmarshal(%s) = %d %s, ожидалось %d %s", tt.in, m.Amount, m.Currency, tt.amount, tt.currency)
+		}
+	}
+
+	for _, in := range []string{`"1.234"`, `{"amount": "50", "currency": "USD"}`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("Unmarshal(%s) = %d %s, ожидалась ошибка", in, m.Amount, m.Currency)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int64
+	}{
+		{nil, 0},
+		{[]byte("2000.50"), 200050},
+		{"12.3", 1230},
+		{int64(7), 700},
+		{float64(0.1) + float64(0.2), 30},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.value); err != nil {
+			t.Errorf("Scan(%v): %v", tt.value, err)
+			continue
+		}
+		if m.Amount != tt.want {
+			t.Errorf("Scan(%v) = %d, ожидалось %d", tt.value, m.Amount, tt.want)
+		}
+	}
+	var m Money
+	if err := m.Scan(true); err == nil {
+		t.Errorf("Scan(bool) должен вернуть ошибку")
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Percent
+		wantErr bool
+	}{
+		{"15", 1500, false},
+		{"12.5", 1250, false},
+		{"12,50", 1250, false},
+		{"0.01", 1, false},
+		{"0.001", 0, true},
+		{"", 0, true},
+		{"пять", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePercent(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePercent(%q) = %d, ожидалась ошибка", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePercent(%q) = %d, %v, ожидалось %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestPercentJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		P Percent `json:"p"`
+	}{1250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"p":12.50}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	for _, in := range []string{`12.5`, `"12.5"`, `"12,50"`} {
+		var p Percent
+		if err := json.Unmarshal([]byte(in), &p); err != nil || p != 1250 {
+			t.Errorf("Unmarshal(%s) = %d, %v, ожидалось 1250", in, p, err)
+		}
+	}
+
+	p := Percent(700)
+	if err := json.Unmarshal([]byte(`null`), &p); err != nil || p != 700 {
+		t.Errorf("Unmarshal(null) изменил значение: %d, %v", p, err)
+	}
+}
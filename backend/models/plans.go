@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// SubscriptionPlan описывает тариф абонемента
+type SubscriptionPlan struct {
+	Code   string `json:"code"`   // monthly, quarterly, yearly
+	Name   string `json:"name"`   // название, которое сохраняется в subscriptions.type
+	Months int    `json:"months"` // длительность в месяцах
+	Price  Money  `json:"price"`
+}
+
+// SubscriptionPlans — тарифы клуба
+var SubscriptionPlans = []SubscriptionPlan{
+	{Code: "monthly", Name: "Месячный", Months: 1, Price: Rubles(2000)},
+	{Code: "quarterly", Name: "Квартальный", Months: 3, Price: Rubles(5000)},
+	{Code: "yearly", Name: "Годовой", Months: 12, Price: Rubles(18000)},
+}
+
+// FindPlan ищет тариф по коду или названию
+func FindPlan(typeOrName string) (SubscriptionPlan, bool) {
+	for _, p := range SubscriptionPlans {
+		if p.Code == typeOrName || p.Name == typeOrName {
+			return p, true
+		}
+	}
+	return SubscriptionPlan{}, false
+}
+
+// EndDate возвращает дату окончания абонемента по тарифу
+func (p SubscriptionPlan) EndDate(start time.Time) time.Time {
+	return start.AddDate(0, p.Months, 0)
+}
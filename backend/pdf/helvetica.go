@@ -0,0 +1,57 @@
+package pdf
+
+import "strings"
+
+// Ширины символов Helvetica (ASCII 32–126) в тысячных долях кегля
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+func helveticaWidth(c byte) int {
+	if c < 32 || int(c-32) >= len(helveticaWidths) {
+		return 556
+	}
+	return helveticaWidths[c-32]
+}
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// transliterate приводит строку к ASCII для встроенного шрифта Helvetica
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 128:
+			b.WriteRune(r)
+		case r == '№':
+			b.WriteString("No.")
+		case r == '«' || r == '»':
+			b.WriteByte('"')
+		case r == '—' || r == '–':
+			b.WriteByte('-')
+		default:
+			lower := []rune(strings.ToLower(string(r)))[0]
+			lat, ok := cyrillicToLatin[lower]
+			if !ok {
+				b.WriteByte('?')
+				continue
+			}
+			if lower != r && lat != "" {
+				lat = strings.ToUpper(lat[:1]) + lat[1:]
+			}
+			b.WriteString(lat)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,302 @@
+// Package pdf формирует простые PDF-документы (текст и линии) без внешних утилит.
+// Для кириллицы подключается TrueType-шрифт (в документ встраиваются только использованные
+// глифы); без него используется встроенный Helvetica с транслитерацией.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Размеры страницы A4 в пунктах
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document представляет PDF-документ
+type Document struct {
+	pages []*bytes.Buffer
+	font  *trueTypeFont
+	used  map[uint16]rune
+}
+
+// New создает пустой документ
+func New() *Document {
+	return &Document{used: make(map[uint16]rune)}
+}
+
+// LoadFont подключает TrueType-шрифт из файла
+func (d *Document) LoadFont(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	font, err := parseTrueType(data)
+	if err != nil {
+		return fmt.Errorf("шрифт %s: %v", path, err)
+	}
+	d.font = font
+	return nil
+}
+
+// LoadFirstFont подключает первый доступный шрифт из списка путей
+func (d *Document) LoadFirstFont(paths ...string) error {
+	var lastErr error
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if lastErr = d.LoadFont(p); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("не указан путь к шрифту")
+	}
+	return lastErr
+}
+
+// AddPage добавляет новую страницу, дальнейший вывод идет на нее
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) current() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text выводит строку; y отсчитывается от верхнего края страницы
+func (d *Document) Text(x, y, size float64, s string) {
+	fmt.Fprintf(d.current(), "BT /F1 %.2f Tf %.2f %.2f Td %s Tj ET\n", size, x, PageHeight-y, d.encode(s))
+}
+
+// TextRight выводит строку, выровненную по правому краю в точке x
+func (d *Document) TextRight(x, y, size float64, s string) {
+	d.Text(x-d.TextWidth(s, size), y, size, s)
+}
+
+// Line рисует отрезок; координаты y отсчитываются от верхнего края
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.current(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// TextWidth возвращает ширину строки в пунктах
+func (d *Document) TextWidth(s string, size float64) float64 {
+	var total float64
+	if d.font != nil {
+		for _, r := range s {
+			total += float64(d.font.advance(d.font.glyph(r)))
+		}
+		return total * size / float64(d.font.unitsPerEm)
+	}
+	for _, c := range []byte(transliterate(s)) {
+		total += float64(helveticaWidth(c))
+	}
+	return total * size / 1000
+}
+
+func (d *Document) encode(s string) string {
+	if d.font != nil {
+		var b strings.Builder
+		b.WriteByte('<')
+		for _, r := range s {
+			gid := d.font.glyph(r)
+			d.used[gid] = r
+			fmt.Fprintf(&b, "%04X", gid)
+		}
+		b.WriteByte('>')
+		return b.String()
+	}
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range []byte(transliterate(s)) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Bytes собирает документ и возвращает его содержимое
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	w := &writer{}
+	// Номера объектов: 1 — каталог, 2 — дерево страниц, 3 — шрифт
+	catalog, pagesObj, fontObj := 1, 2, 3
+	w.reserve(3)
+
+	pageIDs := make([]int, 0, len(d.pages))
+	for _, content := range d.pages {
+		stream, err := deflate(content.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		contentID := w.add(streamObject(fmt.Sprintf("/Filter /FlateDecode /Length %d", len(stream)), stream))
+		pageIDs = append(pageIDs, w.add([]byte(fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, PageWidth, PageHeight, fontObj, contentID))))
+	}
+
+	kids := make([]string, len(pageIDs))
+	for i, id := range pageIDs {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	w.set(catalog, []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)))
+	w.set(pagesObj, []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pageIDs))))
+
+	if d.font == nil {
+		w.set(fontObj, []byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"))
+		return w.bytes(catalog), nil
+	}
+
+	if err := d.writeTrueType(w, fontObj); err != nil {
+		return nil, err
+	}
+	return w.bytes(catalog), nil
+}
+
+// writeTrueType встраивает подмножество шрифта с использованными глифами
+// как CID-шрифт с кодировкой Identity-H
+func (d *Document) writeTrueType(w *writer, fontObj int) error {
+	f := d.font
+	gids := make([]int, 0, len(d.used))
+	for gid := range d.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	// Имя подмножества по правилам PDF начинается с метки: ABCDEF+Имя
+	name := f.name
+	data, err := f.subset(d.used)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		name = subsetTag(gids) + "+" + f.name
+	} else {
+		data = f.data
+	}
+	fontFile, err := deflate(data)
+	if err != nil {
+		return err
+	}
+	fileID := w.add(streamObject(fmt.Sprintf("/Filter /FlateDecode /Length %d /Length1 %d", len(fontFile), len(data)), fontFile))
+
+	scale := func(v int) int { return v * 1000 / f.unitsPerEm }
+	descriptorID := w.add([]byte(fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, scale(f.bbox[0]), scale(f.bbox[1]), scale(f.bbox[2]), scale(f.bbox[3]),
+		scale(f.ascent), scale(f.descent), scale(f.ascent), fileID)))
+
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, scale(f.advance(uint16(gid))))
+	}
+	cidID := w.add([]byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 500 /W [%s] /CIDToGIDMap /Identity >>",
+		name, descriptorID, widths.String())))
+
+	var cmap bytes.Buffer
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	cmap.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	cmap.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", gid, utf16Hex(d.used[uint16(gid)]))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	toUnicodeID := w.add(streamObject(fmt.Sprintf("/Length %d", cmap.Len()), cmap.Bytes()))
+
+	w.set(fontObj, []byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidID, toUnicodeID)))
+	return nil
+}
+
+func utf16Hex(r rune) string {
+	if r < 0x10000 {
+		return fmt.Sprintf("%04X", r)
+	}
+	r -= 0x10000
+	return fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func streamObject(dict string, data []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s >>\nstream\n", dict)
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+// writer собирает объекты документа и таблицу перекрестных ссылок
+type writer struct {
+	objects [][]byte
+}
+
+func (w *writer) reserve(n int) {
+	for i := 0; i < n; i++ {
+		w.objects = append(w.objects, nil)
+	}
+}
+
+func (w *writer) add(obj []byte) int {
+	w.objects = append(w.objects, obj)
+	return len(w.objects)
+}
+
+func (w *writer) set(id int, obj []byte) {
+	w.objects[id-1] = obj
+}
+
+func (w *writer) bytes(root int) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(obj)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(w.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.objects)+1, root, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseObjects проверяет таблицу перекрестных ссылок и возвращает объекты документа по номерам
+func parseObjects(t *testing.T, data []byte) map[int][]byte {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("нет заголовка PDF: %q", data[:min(len(data), 16)])
+	}
+	if !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("нет маркера конца файла")
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("нет startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d не указывает на таблицу xref", xref)
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+
+	objects := make(map[int][]byte)
+	for id := 1; id < count; id++ {
+		entry := lines[2+id]
+		offset, err := strconv.Atoi(entry[:10])
+		if err != nil || !strings.HasSuffix(entry, " n ") {
+			t.Fatalf("неверная запись xref %q", entry)
+		}
+		header := fmt.Sprintf("%d 0 obj\n", id)
+		if !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Fatalf("смещение объекта %d указывает на %q", id, data[offset:offset+12])
+		}
+		body := data[offset+len(header):]
+		objects[id] = body[:bytes.Index(body, []byte("\nendobj\n"))]
+	}
+	return objects
+}
+
+// streamData возвращает распакованное содержимое потока объекта
+func streamData(t *testing.T, obj []byte) []byte {
+	t.Helper()
+	start := bytes.Index(obj, []byte("stream\n"))
+	end := bytes.LastIndex(obj, []byte("\nendstream"))
+	if start < 0 || end < start {
+		t.Fatalf("объект не содержит поток: %q", obj[:min(len(obj), 80)])
+	}
+	data := obj[start+len("stream\n") : end]
+	if !bytes.Contains(obj[:start], []byte("/FlateDecode")) {
+		return data
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Счет № 5", "Schet No. 5"},
+		{"«Щука» — Юля", `"Shchuka" - Yulya`},
+		{"подъезд", "podezd"},
+		{"日本", "??"},
+	}
+	for _, tt := range tests {
+		if got := transliterate(tt.in); got != tt.want {
+			t.Errorf("transliterate(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBytesBuiltinFont(t *testing.T) {
+	doc := New()
+	doc.Text(50, 60, 12, "Счет (копия)")
+	doc.Line(50, 70, 200, 70, 0.5)
+	doc.AddPage()
+	doc.TextRight(545, 60, 10, "Итого")
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := parseObjects(t, data)
+	if !bytes.Contains(objects[2], []byte("/Count 2")) {
+		t.Errorf("дерево страниц: %s", objects[2])
+	}
+	if !bytes.Contains(objects[3], []byte("/BaseFont /Helvetica")) {
+		t.Errorf("шрифт: %s", objects[3])
+	}
+	content := string(streamData(t, objects[4]))
+	if !strings.Contains(content, `BT /F1 12.00 Tf 50.00 781.89 Td (Schet \(kopiya\)) Tj ET`) {
+		t.Errorf("содержимое первой страницы: %q", content)
+	}
+	if !strings.Contains(content, "0.50 w 50.00 771.89 m 200.00 771.89 l S") {
+		t.Errorf("нет линии на первой странице: %q", content)
+	}
+}
+
+// testFontPaths — шрифты с кириллицей, которые обычно есть на сервере сборки
+var testFontPaths = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+}
+
+func TestBytesTrueTypeSubset(t *testing.T) {
+	doc := New()
+	if err := doc.LoadFirstFont(testFontPaths...); err != nil {
+		t.Skipf("шрифт с кириллицей не найден: %v", err)
+	}
+	const text = "Счет-квитанция № 2026-000001"
+	doc.Text(50, 60, 12, text)
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := parseObjects(t, data)
+
+	font := string(objects[3])
+	m := regexp.MustCompile(`/Subtype /Type0 /BaseFont /([A-Z]{6})\+(\S+) /Encoding /Identity-H`).FindStringSubmatch(font)
+	if m == nil || m[2] != doc.font.name {
+		t.Fatalf("шрифт не встроен как подмножество: %s", font)
+	}
+
+	// Текст кодируется номерами глифов шрифта
+	var hex strings.Builder
+	for _, r := range text {
+		fmt.Fprintf(&hex, "%04X", doc.font.glyph(r))
+	}
+	if content := string(streamData(t, objects[4])); !strings.Contains(content, "<"+hex.String()+"> Tj") {
+		t.Errorf("текст закодирован неверно: %q", content)
+	}
+
+	var fontFile []byte
+	for _, obj := range objects {
+		if bytes.Contains(obj, []byte("/Length1")) {
+			fontFile = streamData(t, obj)
+		}
+	}
+	if fontFile == nil {
+		t.Fatal("нет встроенного файла шрифта")
+	}
+	if len(fontFile) >= len(doc.font.data) {
+		t.Errorf("подмножество (%d байт) не меньше исходного шрифта (%d байт)", len(fontFile), len(doc.font.data))
+	}
+	checkFontFile(t, fontFile, doc.font, text)
+}
+
+// checkFontFile проверяет контрольные суммы подмножества и наличие контуров нужных глифов
+func checkFontFile(t *testing.T, data []byte, font *trueTypeFont, text string) {
+	t.Helper()
+	if sum := tableChecksum(data); sum != 0xB1B0AFBA {
+		t.Errorf("контрольная сумма файла %08X, ожидалось B1B0AFBA", sum)
+	}
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := data[12+i*16:]
+		tag := string(rec[:4])
+		offset := binary.BigEndian.Uint32(rec[8:])
+		length := binary.BigEndian.Uint32(rec[12:])
+		tables[tag] = data[offset : offset+length]
+		if tag != "head" && tableChecksum(tables[tag]) != binary.BigEndian.Uint32(rec[4:]) {
+			t.Errorf("неверная контрольная сумма таблицы %s", tag)
+		}
+	}
+	for _, tag := range []string{"glyf", "head", "hhea", "hmtx", "loca", "maxp"} {
+		if tables[tag] == nil {
+			t.Fatalf("в подмножестве нет таблицы %s", tag)
+		}
+	}
+	if binary.BigEndian.Uint16(tables["head"][50:]) != 1 {
+		t.Fatal("loca подмножества должна быть в длинном формате")
+	}
+
+	loca := tables["loca"]
+	glyphSize := func(gid uint16) uint32 {
+		return binary.BigEndian.Uint32(loca[int(gid+1)*4:]) - binary.BigEndian.Uint32(loca[int(gid)*4:])
+	}
+	for _, r := range text {
+		if r == ' ' {
+			continue
+		}
+		if gid := font.glyph(r); gid == 0 || glyphSize(gid) == 0 {
+			t.Errorf("нет контура глифа %q (gid %d)", r, gid)
+		}
+	}
+	if gid := font.glyph('Ж'); gid != 0 && glyphSize(gid) != 0 {
+		t.Errorf("неиспользованный глиф 'Ж' попал в подмножество")
+	}
+}
@@ -0,0 +1,186 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// Таблицы, которые нужны программе просмотра для TrueType-шрифта внутри PDF
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// subset собирает шрифт только с глифами из used. Номера глифов не меняются — контуры
+// неиспользуемых глифов просто удаляются, поэтому CIDToGIDMap /Identity остается верным.
+// Для шрифтов без таблиц glyf/loca (контуры CFF) возвращает nil — встраивается весь файл.
+func (f *trueTypeFont) subset(used map[uint16]rune) ([]byte, error) {
+	glyf, loca, head, maxp := f.tables["glyf"], f.tables["loca"], f.tables["head"], f.tables["maxp"]
+	if glyf == nil || loca == nil || len(maxp) < 6 {
+		return nil, nil
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longOffsets := binary.BigEndian.Uint16(head[50:]) == 1
+	offset := func(gid int) int {
+		if longOffsets {
+			return int(binary.BigEndian.Uint32(loca[gid*4:]))
+		}
+		return int(binary.BigEndian.Uint16(loca[gid*2:])) * 2
+	}
+	if (longOffsets && len(loca) < (numGlyphs+1)*4) || (!longOffsets && len(loca) < (numGlyphs+1)*2) {
+		return nil, fmt.Errorf("повреждена таблица loca")
+	}
+	glyph := func(gid int) []byte {
+		start, end := offset(gid), offset(gid+1)
+		if start >= end || end > len(glyf) {
+			return nil
+		}
+		return glyf[start:end]
+	}
+
+	// Глиф 0 (.notdef) обязателен; составные глифы тянут за собой свои компоненты
+	keep := map[int]bool{0: true}
+	queue := []int{0}
+	for gid := range used {
+		if int(gid) < numGlyphs && !keep[int(gid)] {
+			keep[int(gid)] = true
+			queue = append(queue, int(gid))
+		}
+	}
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		for _, component := range glyphComponents(glyph(gid)) {
+			if component < numGlyphs && !keep[component] {
+				keep[component] = true
+				queue = append(queue, component)
+			}
+		}
+	}
+
+	// Новые glyf и loca; loca всегда в длинном формате
+	var newGlyf []byte
+	newLoca := make([]byte, (numGlyphs+1)*4)
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(newLoca[gid*4:], uint32(len(newGlyf)))
+		if keep[gid] {
+			newGlyf = append(newGlyf, glyph(gid)...)
+			for len(newGlyf)%4 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[numGlyphs*4:], uint32(len(newGlyf)))
+
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0) // checkSumAdjustment считается по готовому файлу
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+
+	tables := map[string][]byte{"glyf": newGlyf, "loca": newLoca, "head": newHead}
+	for _, tag := range subsetTables {
+		if _, ok := tables[tag]; !ok && f.tables[tag] != nil {
+			tables[tag] = f.tables[tag]
+		}
+	}
+	return buildFontFile(tables), nil
+}
+
+// glyphComponents возвращает номера глифов, из которых состоит составной глиф
+func glyphComponents(g []byte) []int {
+	if len(g) < 10 || int16(binary.BigEndian.Uint16(g)) >= 0 {
+		return nil
+	}
+	var components []int
+	for pos := 10; pos+4 <= len(g); {
+		flags := binary.BigEndian.Uint16(g[pos:])
+		components = append(components, int(binary.BigEndian.Uint16(g[pos+2:])))
+		pos += 4
+		if flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&0x0008 != 0: // WE_HAVE_A_SCALE
+			pos += 2
+		case flags&0x0040 != 0: // WE_HAVE_AN_X_AND_Y_SCALE
+			pos += 4
+		case flags&0x0080 != 0: // WE_HAVE_A_TWO_BY_TWO
+			pos += 8
+		}
+		if flags&0x0020 == 0 { // MORE_COMPONENTS
+			break
+		}
+	}
+	return components
+}
+
+// buildFontFile собирает файл TrueType из таблиц и проставляет контрольные суммы
+func buildFontFile(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= n {
+		searchRange *= 2
+		entrySelector++
+	}
+	searchRange *= 16
+
+	out := make([]byte, 12+n*16)
+	binary.BigEndian.PutUint32(out[0:], 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(n*16-searchRange))
+
+	headOffset := -1
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := 12 + i*16
+		copy(out[rec:], tag)
+		binary.BigEndian.PutUint32(out[rec+4:], tableChecksum(data))
+		binary.BigEndian.PutUint32(out[rec+8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(out[rec+12:], uint32(len(data)))
+		if tag == "head" {
+			headOffset = len(out)
+		}
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-tableChecksum(out))
+	}
+	return out
+}
+
+// tableChecksum — сумма 32-битных слов таблицы, как требует формат TrueType
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// subsetTag возвращает шестибуквенную метку подмножества для имени шрифта (ABCDEF+Имя)
+func subsetTag(gids []int) string {
+	h := crc32.NewIEEE()
+	for _, gid := range gids {
+		h.Write([]byte{byte(gid >> 8), byte(gid)})
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag)
+}
@@ -0,0 +1,232 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// trueTypeFont содержит метрики TrueType-шрифта, нужные для встраивания
+type trueTypeFont struct {
+	data       []byte
+	tables     map[string][]byte
+	name       string
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	advances   []uint16
+	cmap       map[rune]uint16
+}
+
+func (f *trueTypeFont) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+func (f *trueTypeFont) advance(gid uint16) int {
+	if len(f.advances) == 0 {
+		return 0
+	}
+	if int(gid) < len(f.advances) {
+		return int(f.advances[gid])
+	}
+	return int(f.advances[len(f.advances)-1])
+}
+
+func parseTrueType(data []byte) (*trueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("файл слишком короткий")
+	}
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("поврежден каталог таблиц")
+		}
+		tag := string(data[rec : rec+4])
+		offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("таблица %s выходит за пределы файла", tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "cmap"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("нет таблицы %s", tag)
+		}
+	}
+
+	f := &trueTypeFont{data: data, tables: tables, name: "EmbeddedFont"}
+
+	head := tables["head"]
+	if len(head) < 54 {
+		return nil, fmt.Errorf("повреждена таблица head")
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return nil, fmt.Errorf("unitsPerEm равен нулю")
+	}
+	for i := 0; i < 4; i++ {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+i*2:])))
+	}
+
+	hhea := tables["hhea"]
+	if len(hhea) < 36 {
+		return nil, fmt.Errorf("повреждена таблица hhea")
+	}
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	numMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+
+	hmtx := tables["hmtx"]
+	if len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("повреждена таблица hmtx")
+	}
+	f.advances = make([]uint16, numMetrics)
+	for i := range f.advances {
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[i*4:])
+	}
+
+	cmap, err := parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	f.cmap = cmap
+
+	if name := parsePostScriptName(tables["name"]); name != "" {
+		f.name = name
+	}
+	return f, nil
+}
+
+// parseCmap читает юникодную подтаблицу формата 12 или 4
+func parseCmap(t []byte) (map[rune]uint16, error) {
+	if len(t) < 4 {
+		return nil, fmt.Errorf("повреждена таблица cmap")
+	}
+	var format4, format12 []byte
+	n := int(binary.BigEndian.Uint16(t[2:]))
+	for i := 0; i < n; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(t) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(t[rec:])
+		encoding := binary.BigEndian.Uint16(t[rec+2:])
+		offset := int(binary.BigEndian.Uint32(t[rec+4:]))
+		if offset+2 > len(t) {
+			continue
+		}
+		sub := t[offset:]
+		switch binary.BigEndian.Uint16(sub) {
+		case 12:
+			if platform == 3 && encoding == 10 || platform == 0 {
+				format12 = sub
+			}
+		case 4:
+			if platform == 3 && encoding == 1 || platform == 0 {
+				format4 = sub
+			}
+		}
+	}
+
+	m := make(map[rune]uint16)
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for i := 0; i < groups; i++ {
+			g := 16 + i*12
+			if g+12 > len(format12) {
+				break
+			}
+			start := binary.BigEndian.Uint32(format12[g:])
+			end := binary.BigEndian.Uint32(format12[g+4:])
+			gid := binary.BigEndian.Uint32(format12[g+8:])
+			for c := start; c <= end && c-start < 0x10000; c++ {
+				m[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segX2 := int(binary.BigEndian.Uint16(format4[6:]))
+		ends := 14
+		starts := ends + segX2 + 2
+		deltas := starts + segX2
+		rangeOffsets := deltas + segX2
+		if rangeOffsets+segX2 > len(format4) {
+			return nil, fmt.Errorf("повреждена подтаблица cmap")
+		}
+		for s := 0; s < segX2; s += 2 {
+			end := int(binary.BigEndian.Uint16(format4[ends+s:]))
+			start := int(binary.BigEndian.Uint16(format4[starts+s:]))
+			delta := int(binary.BigEndian.Uint16(format4[deltas+s:]))
+			ro := int(binary.BigEndian.Uint16(format4[rangeOffsets+s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if ro == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					idx := rangeOffsets + s + ro + (c-start)*2
+					if idx+2 > len(format4) {
+						continue
+					}
+					gid = int(binary.BigEndian.Uint16(format4[idx:]))
+					if gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					m[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("нет юникодной подтаблицы cmap")
+	}
+	return m, nil
+}
+
+// parsePostScriptName извлекает PostScript-имя шрифта (nameID 6)
+func parsePostScriptName(t []byte) string {
+	if len(t) < 6 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(t[2:]))
+	storage := int(binary.BigEndian.Uint16(t[4:]))
+	for i := 0; i < count; i++ {
+		rec := 6 + i*12
+		if rec+12 > len(t) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(t[rec:])
+		nameID := binary.BigEndian.Uint16(t[rec+6:])
+		length := int(binary.BigEndian.Uint16(t[rec+8:]))
+		offset := storage + int(binary.BigEndian.Uint16(t[rec+10:]))
+		if nameID != 6 || offset+length > len(t) {
+			continue
+		}
+		raw := t[offset : offset+length]
+		var name string
+		if platform == 3 || platform == 0 {
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[j*2:])
+			}
+			name = string(utf16.Decode(units))
+		} else {
+			name = string(raw)
+		}
+		name = strings.Map(func(r rune) rune {
+			if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+				return -1
+			}
+			return r
+		}, name)
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
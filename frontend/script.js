@@ -956,6 +956,20 @@ function formatTime(date) {
     });
 }
 
+// Денежные суммы приходят с сервера строкой: { amount: "2000.00", currency: "RUB" }
+function moneyAmount(money) {
+    if (money === null || money === undefined) return null;
+    return typeof money === 'object' ? money.amount : String(money);
+}
+
+function formatMoney(money) {
+    const amount = moneyAmount(money);
+    if (amount === null) return '';
+    const [whole, frac = '00'] = amount.split('.');
+    const currency = money.currency && money.currency !== 'RUB' ? money.currency : 'руб.';
+    return `${whole.replace(/\B(?=(\d{3})+(?!\d))/g, ' ')},${frac} ${currency}`;
+}
+
 // Функции для админ-панели
 async function loadUsers() {
     try {
@@ -1132,7 +1146,14 @@ async function deleteUser(id) {
 
 async function loadClients() {
     try {
-        const response = await fetch(`${API_URL}/clients`, {
+        // Поиск и фильтр по абонементу выполняются на сервере
+        const params = new URLSearchParams();
+        const query = (document.getElementById('clients-search')?.value || '').trim();
+        const subscriptionStatus = document.getElementById('clients-status')?.value || '';
+        if (query) params.set('q', query);
+        if (subscriptionStatus) params.set('subscription_status', subscriptionStatus);
+
+        const response = await fetch(`${API_URL}/clients?${params}`, {
             headers: { 'Authorization': authToken }
         });
         const clients = await response.json();
@@ -1149,7 +1170,6 @@ async function loadClients() {
         });
         const allSubscriptions = await subscriptionsResponse.json();
 
-        const searchTerm = (document.getElementById('clients-search')?.value || '').toLowerCase();
         const sort = document.getElementById('clients-sort')?.value || '';
 
         let prepared = clients.map(c => {
@@ -1164,14 +1184,6 @@ async function loadClients() {
             return { c, activeSubscriptions, hasActiveSubscription };
         });
 
-        if (searchTerm) {
-            prepared = prepared.filter(({ c }) => {
-                return (c.user?.name || '').toLowerCase().includes(searchTerm) ||
-                       (c.user?.email || '').toLowerCase().includes(searchTerm) ||
-                       (c.phone || '').toLowerCase().includes(searchTerm);
-            });
-        }
-
         prepared.sort((a, b) => {
             const byStr = (av, bv, dir='asc') => {
                 av = (av || '').toString().toLowerCase();
@@ -1378,7 +1390,7 @@ async function loadSubscriptions() {
                 <div class="list-item-info">
                     <p><strong>${s.type}</strong> - ${clientName}</p>
                     <p>Период: ${startDate} - ${endDate}</p>
-                    <p>Цена: ${s.price} руб.</p>
+                    <p>Цена: ${formatMoney(s.price)}</p>
                     <p>Статус: <span class="badge ${s.status === 'active' ? 'badge-status scheduled' : 'badge-status cancelled'}">${s.status === 'active' ? 'Активен' : s.status === 'expired' ? 'Истек' : 'Отменен'}</span></p>
                 </div>
                 ${currentUser && currentUser.role === 'admin' ? `
@@ -1633,7 +1645,7 @@ async function showSubscriptionEditModal(subscriptionId) {
         document.getElementById('se-type').value = sub.type || 'monthly';
         document.getElementById('se-start').value = sub.start_date ? new Date(sub.start_date).toISOString().split('T')[0] : '';
         document.getElementById('se-end').value = sub.end_date ? new Date(sub.end_date).toISOString().split('T')[0] : '';
-        document.getElementById('se-price').value = sub.price ?? '';
+        document.getElementById('se-price').value = moneyAmount(sub.price) ?? '';
         document.getElementById('se-status').value = sub.status || 'active';
     }
 
@@ -1643,7 +1655,7 @@ async function showSubscriptionEditModal(subscriptionId) {
             type: document.getElementById('se-type').value,
             start_date: document.getElementById('se-start').value,
             end_date: document.getElementById('se-end').value,
-            price: parseFloat(document.getElementById('se-price').value),
+            price: document.getElementById('se-price').value,
             status: document.getElementById('se-status').value
         };
 
@@ -1710,8 +1722,8 @@ async function loadEmployees() {
                 case 'name-desc': return byStr(a.user?.name, b.user?.name, 'desc');
                 case 'position-asc': return byStr(a.position, b.position, 'asc');
                 case 'position-desc': return byStr(a.position, b.position, 'desc');
-                case 'salary-desc': return byNum(a.salary, b.salary, 'desc');
-                case 'salary-asc': return byNum(a.salary, b.salary, 'asc');
+                case 'salary-desc': return byNum(Number(moneyAmount(a.salary)), Number(moneyAmount(b.salary)), 'desc');
+                case 'salary-asc': return byNum(Number(moneyAmount(a.salary)), Number(moneyAmount(b.salary)), 'asc');
                 default: return 0;
             }
         });
@@ -1721,7 +1733,7 @@ async function loadEmployees() {
                 <div class="list-item-info">
                     <p><strong>${e.user?.name || 'N/A'}</strong></p>
                     <p>Должность: ${e.position}</p>
-                    <p>Зарплата: ${e.salary || 'не указана'}</p>
+                    <p>Зарплата: ${e.salary ? formatMoney(e.salary) : 'не указана'}</p>
                 </div>
                 ${currentUser && currentUser.role === 'admin' ? `
                 <div style="display:flex;gap:10px;">
@@ -1786,7 +1798,7 @@ async function showEmployeeModal(employeeId) {
                 </div>
                 <div class='form-group'>
                     <label>Зарплата</label>
-                    <input id='em-salary' type='number' min='0' step='0.01' value='${employee?.salary ?? ''}'>
+                    <input id='em-salary' type='number' min='0' step='0.01' value='${moneyAmount(employee?.salary) ?? ''}'>
                 </div>
                 <div class='form-group'>
                     <label>Дата найма</label>
@@ -1806,7 +1818,7 @@ async function showEmployeeModal(employeeId) {
         e.preventDefault();
         const body = {
             position: document.getElementById('em-position').value.trim(),
-            salary: document.getElementById('em-salary').value ? parseFloat(document.getElementById('em-salary').value) : null,
+            salary: document.getElementById('em-salary').value || null,
             hire_date: document.getElementById('em-hire').value
         };
 
@@ -2038,6 +2050,17 @@ async function loadStats() {
                     <div class="stat-value">${stats.completed_trainings}</div>
                     <div class="stat-label">Завершенных тренировок</div>
                 </div>
+                ${stats.net_revenue !== undefined ? `
+                <div class="stat-card">
+                    <div class="stat-icon">💰</div>
+                    <div class="stat-value">${formatMoney(stats.net_revenue)}</div>
+                    <div class="stat-label">Выручка за вычетом возвратов</div>
+                </div>
+                <div class="stat-card">
+                    <div class="stat-icon">↩️</div>
+                    <div class="stat-value">${formatMoney(stats.total_refunds)}</div>
+                    <div class="stat-label">Возвраты</div>
+                </div>` : ''}
             </div>
         `;
     } catch (error) {
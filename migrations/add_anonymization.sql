@@ -0,0 +1,5 @@
+-- Обезличивание персональных данных клиентов вместо удаления
+-- Выполнить: psql -d fitness_club -f migrations/add_anonymization.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
@@ -0,0 +1,38 @@
+-- Сертификаты тренеров и требования к квалификации для проведения тренировок
+-- Выполнить: psql -d fitness_club -f migrations/add_certifications.sql
+
+-- Сертификаты сотрудников; скан документа хранится в базе, как и PDF счетов
+CREATE TABLE IF NOT EXISTS certifications (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    cert_type VARCHAR(100) NOT NULL, -- например pilates, yoga
+    issuer VARCHAR(255) NOT NULL,
+    issued_at DATE,
+    expires_at DATE CHECK (expires_at >= issued_at), -- NULL — бессрочный
+    document BYTEA,
+    document_name VARCHAR(255),
+    document_type VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Требования: тренировка в зале hall_type и/или типа training_type требует сертификат cert_type.
+-- NULL в hall_type или training_type означает «любой».
+CREATE TABLE IF NOT EXISTS qualification_requirements (
+    id SERIAL PRIMARY KEY,
+    hall_type VARCHAR(100),
+    training_type VARCHAR(100),
+    cert_type VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (hall_type IS NOT NULL OR training_type IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_qualification_requirements_unique
+    ON qualification_requirements (COALESCE(hall_type, ''), COALESCE(training_type, ''), cert_type);
+CREATE INDEX IF NOT EXISTS idx_certifications_employee ON certifications(employee_id);
+CREATE INDEX IF NOT EXISTS idx_certifications_expires ON certifications(expires_at);
+
+-- Пилатес и йогу проводят только сертифицированные тренеры
+INSERT INTO qualification_requirements (hall_type, cert_type) VALUES
+    ('pilates', 'pilates'),
+    ('yoga', 'yoga')
+ON CONFLICT DO NOTHING;
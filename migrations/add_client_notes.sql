@@ -0,0 +1,23 @@
+-- Заметки сотрудников о клиентах и история контактов с напоминаниями
+-- Выполнить: psql -d fitness_club -f migrations/add_client_notes.sql
+
+CREATE TABLE IF NOT EXISTS client_notes (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'note' CHECK (kind IN ('note', 'call', 'visit', 'email', 'message')),
+    -- staff — видят только администраторы (ресепшен), trainers — также тренеры клиента
+    visibility VARCHAR(20) NOT NULL DEFAULT 'staff' CHECK (visibility IN ('staff', 'trainers')),
+    body TEXT NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    follow_up_at TIMESTAMP,
+    assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    follow_up_done_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_notes_client_id ON client_notes(client_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_client_notes_tags ON client_notes USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_client_notes_follow_up ON client_notes(follow_up_at)
+    WHERE follow_up_at IS NOT NULL AND follow_up_done_at IS NULL;
@@ -0,0 +1,36 @@
+-- Прогресс клиентов: замеры тела, контрольные упражнения и цели
+-- Выполнить: psql -d fitness_club -f migrations/add_client_progress.sql
+
+CREATE TABLE IF NOT EXISTS client_measurements (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    measured_at DATE NOT NULL DEFAULT CURRENT_DATE,
+    weight_kg DECIMAL(5, 2) CHECK (weight_kg > 0),
+    body_fat_percent DECIMAL(4, 1) CHECK (body_fat_percent >= 0 AND body_fat_percent <= 100),
+    chest_cm DECIMAL(5, 1) CHECK (chest_cm > 0),
+    waist_cm DECIMAL(5, 1) CHECK (waist_cm > 0),
+    hips_cm DECIMAL(5, 1) CHECK (hips_cm > 0),
+    arm_cm DECIMAL(5, 1) CHECK (arm_cm > 0),
+    thigh_cm DECIMAL(5, 1) CHECK (thigh_cm > 0),
+    benchmarks JSONB NOT NULL DEFAULT '{}', -- контрольные упражнения, например {"bench_press_kg": 80}
+    notes TEXT,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS client_goals (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    metric VARCHAR(100) NOT NULL, -- weight_kg, waist_cm, ... или название контрольного упражнения
+    start_value DECIMAL(10, 2),
+    target_value DECIMAL(10, 2) NOT NULL,
+    target_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'achieved', 'abandoned')),
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    achieved_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_client_measurements_client_date ON client_measurements(client_id, measured_at);
+CREATE INDEX IF NOT EXISTS idx_client_goals_client_id ON client_goals(client_id);
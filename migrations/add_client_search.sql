@@ -0,0 +1,16 @@
+-- Индексы для поиска и фильтрации клиентов
+-- Выполнить: psql -d fitness_club -f migrations/add_client_search.sql
+-- Для pg_trgm нужны права на CREATE EXTENSION (обычно суперпользователь или владелец БД)
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Поиск подстроки по имени, email и цифрам телефона (ILIKE/LIKE '%...%')
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_clients_phone_digits_trgm ON clients USING GIN (regexp_replace(phone, '\D', '', 'g') gin_trgm_ops);
+
+-- Фильтры по месяцу рождения, статусу абонемента и последнему посещению
+CREATE INDEX IF NOT EXISTS idx_clients_birth_month ON clients ((EXTRACT(MONTH FROM birth_date)));
+CREATE INDEX IF NOT EXISTS idx_clients_user_id ON clients(user_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_client_end_date ON subscriptions(client_id, end_date);
+CREATE INDEX IF NOT EXISTS idx_visits_client_checked_in ON visits(client_id, checked_in_at DESC);
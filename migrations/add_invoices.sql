@@ -0,0 +1,31 @@
+-- Счета-квитанции по проданным абонементам
+-- Выполнить: psql -d fitness_club -f migrations/add_invoices.sql
+
+-- Счетчик номеров счетов по годам (нумерация сквозная, без пропусков)
+CREATE TABLE IF NOT EXISTS invoice_counters (
+    year INTEGER PRIMARY KEY,
+    last_number INTEGER NOT NULL DEFAULT 0
+);
+
+-- Таблица счетов. Данные плательщика сохраняются на момент выставления,
+-- поэтому счет остается действительным и после изменения или удаления абонемента.
+-- PDF формируется один раз при выставлении и дальше отдается из базы без изменений
+CREATE TABLE IF NOT EXISTS invoices (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER UNIQUE REFERENCES subscriptions(id) ON DELETE SET NULL,
+    year INTEGER NOT NULL,
+    sequence_number INTEGER NOT NULL,
+    number VARCHAR(50) UNIQUE NOT NULL,
+    client_name VARCHAR(255) NOT NULL,
+    client_email VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    issued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Состояние оплаты на момент выставления, по нему в PDF ставится отметка об оплате
+    payment_status VARCHAR(20) NOT NULL DEFAULT '',
+    paid_at TIMESTAMP,
+    pdf BYTEA NOT NULL,
+    UNIQUE(year, sequence_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_year ON invoices(year);
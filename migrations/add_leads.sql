@@ -0,0 +1,40 @@
+-- Потенциальные клиенты (лиды), пробные тренировки и связь с клиентом после конверсии
+-- Выполнить: psql -d fitness_club -f migrations/add_leads.sql
+
+CREATE TABLE IF NOT EXISTS leads (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    phone VARCHAR(20),
+    source VARCHAR(20) NOT NULL DEFAULT 'other'
+        CHECK (source IN ('walk_in', 'website', 'referral', 'social', 'ads', 'partner', 'other')),
+    status VARCHAR(20) NOT NULL DEFAULT 'new'
+        CHECK (status IN ('new', 'contacted', 'trial_booked', 'converted', 'lost')),
+    notes TEXT,
+    lost_reason TEXT,
+    assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    converted_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    converted_at TIMESTAMP,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (email IS NOT NULL OR phone IS NOT NULL)
+);
+
+-- Пробные тренировки занимают место в тренировке наравне с участниками
+CREATE TABLE IF NOT EXISTS lead_trials (
+    id SERIAL PRIMARY KEY,
+    lead_id INTEGER NOT NULL REFERENCES leads(id) ON DELETE CASCADE,
+    training_id INTEGER NOT NULL REFERENCES trainings(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'booked' CHECK (status IN ('booked', 'attended', 'no_show', 'cancelled')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lead_id, training_id)
+);
+
+-- Атрибуция: из какого лида получен клиент
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS lead_id INTEGER REFERENCES leads(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_leads_status ON leads(status);
+CREATE INDEX IF NOT EXISTS idx_leads_email ON leads(LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_lead_trials_training_id ON lead_trials(training_id);
+CREATE INDEX IF NOT EXISTS idx_clients_lead_id ON clients(lead_id);
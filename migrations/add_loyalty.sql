@@ -0,0 +1,44 @@
+-- Бонусные баллы лояльности и реферальная программа
+-- Выполнить: psql -d fitness_club -f migrations/add_loyalty.sql
+
+-- Реферальный код клиента и кто его пригласил
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS referral_code VARCHAR(20) UNIQUE;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS referred_by INTEGER REFERENCES clients(id) ON DELETE SET NULL;
+
+-- Журнал баллов: начисления (points > 0) и списания (points < 0).
+-- reversal — отмена начислений и возврат списанных баллов при отмене абонемента.
+-- remaining — неизрасходованный остаток начисления; списания и сгорание уменьшают
+-- остатки самых старых начислений первыми (FIFO).
+CREATE TABLE IF NOT EXISTS loyalty_transactions (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL
+        CHECK (reason IN ('attendance', 'checkin', 'purchase', 'referral', 'redemption', 'expiry', 'adjustment', 'reversal')),
+    points INTEGER NOT NULL CHECK (points <> 0),
+    remaining INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0),
+    expires_at DATE,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE SET NULL,
+    visit_id INTEGER REFERENCES visits(id) ON DELETE SET NULL,
+    payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    referral_client_id INTEGER REFERENCES clients(id) ON DELETE SET NULL,
+    -- Бесплатные дни, добавленные пригласившему за реферала: снимаются при отмене покупки
+    extended_subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    extended_days INTEGER NOT NULL DEFAULT 0,
+    description TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_loyalty_transactions_client ON loyalty_transactions(client_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_loyalty_transactions_open ON loyalty_transactions(client_id, expires_at) WHERE remaining > 0;
+
+-- Каждое событие начисляет баллы не больше одного раза
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loyalty_attendance_once
+    ON loyalty_transactions(client_id, training_id) WHERE reason = 'attendance';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loyalty_checkin_daily
+    ON loyalty_transactions(client_id, (created_at::date)) WHERE reason = 'checkin';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loyalty_purchase_once
+    ON loyalty_transactions(payment_id) WHERE reason = 'purchase';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_loyalty_referral_once
+    ON loyalty_transactions(referral_client_id) WHERE reason = 'referral';
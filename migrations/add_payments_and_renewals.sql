@@ -0,0 +1,27 @@
+-- Журнал платежей, продление и смена тарифа абонементов
+-- Выполнить: psql -d fitness_club -f migrations/add_payments_and_renewals.sql
+
+-- Связь абонемента с предыдущим периодом
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS previous_subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS change_kind VARCHAR(20) CHECK (change_kind IN ('renewal', 'upgrade'));
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Журнал платежей (оплаты и возвраты)
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    client_id INTEGER REFERENCES clients(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'payment' CHECK (kind IN ('payment', 'refund')),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount >= 0),
+    method VARCHAR(20) NOT NULL CHECK (method IN ('cash', 'card', 'online', 'auto')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'paid', 'failed')),
+    provider VARCHAR(50),
+    provider_reference VARCHAR(255),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_previous_id ON subscriptions(previous_subscription_id);
+CREATE INDEX IF NOT EXISTS idx_payments_subscription_id ON payments(subscription_id);
+CREATE INDEX IF NOT EXISTS idx_payments_client_id ON payments(client_id);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
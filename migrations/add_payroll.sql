@@ -0,0 +1,49 @@
+-- Схемы оплаты сотрудников, расчет зарплаты за период и закрытие периода
+-- Выполнить: psql -d fitness_club -f migrations/add_payroll.sql
+
+-- Стоимость персональной тренировки для расчета процента тренера от выручки
+ALTER TABLE trainings ADD COLUMN IF NOT EXISTS price DECIMAL(10, 2) CHECK (price >= 0);
+
+-- Схемы оплаты; у сотрудника может быть несколько одновременно (например, оклад и оплата за тренировку)
+CREATE TABLE IF NOT EXISTS compensation_schemes (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    scheme_type VARCHAR(30) NOT NULL
+        CHECK (scheme_type IN ('fixed_monthly', 'per_training', 'per_participant', 'personal_revenue_percent')),
+    rate DECIMAL(10, 2) NOT NULL CHECK (rate >= 0), -- сумма или процент для personal_revenue_percent
+    valid_from DATE NOT NULL,
+    valid_to DATE CHECK (valid_to >= valid_from), -- NULL — бессрочно
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Расчеты зарплаты; закрытый (locked) период нельзя пересчитать, а тренировки в нем — изменить
+CREATE TABLE IF NOT EXISTS payroll_runs (
+    id SERIAL PRIMARY KEY,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL CHECK (period_end >= period_start),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'locked')),
+    total DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    calculated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    locked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    locked_at TIMESTAMP
+);
+
+-- Строки расчета: начисление сотруднику по одной схеме оплаты
+CREATE TABLE IF NOT EXISTS payroll_lines (
+    id SERIAL PRIMARY KEY,
+    run_id INTEGER NOT NULL REFERENCES payroll_runs(id) ON DELETE CASCADE,
+    employee_id INTEGER REFERENCES employees(id) ON DELETE SET NULL,
+    employee_name VARCHAR(255) NOT NULL,
+    scheme_id INTEGER REFERENCES compensation_schemes(id) ON DELETE SET NULL,
+    scheme_type VARCHAR(30) NOT NULL,
+    description TEXT NOT NULL,
+    quantity DECIMAL(10, 2) NOT NULL, -- тренировки, участники, доля месяца или выручка
+    rate DECIMAL(10, 2) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_compensation_schemes_employee ON compensation_schemes(employee_id);
+CREATE INDEX IF NOT EXISTS idx_payroll_runs_period ON payroll_runs(period_start, period_end);
+CREATE INDEX IF NOT EXISTS idx_payroll_lines_run ON payroll_lines(run_id);
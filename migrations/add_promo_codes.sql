@@ -0,0 +1,42 @@
+-- Промокоды и автоматические скидки на абонементы
+-- Выполнить: psql -d fitness_club -f migrations/add_promo_codes.sql
+
+-- Таблица промокодов
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value DECIMAL(10, 2) NOT NULL CHECK (discount_value > 0),
+    valid_from DATE,
+    valid_to DATE,
+    usage_limit INTEGER,          -- NULL — без ограничения
+    per_client_limit INTEGER,     -- NULL — без ограничения
+    plans TEXT[],                 -- коды тарифов (monthly, quarterly, yearly); NULL — любые
+    times_used INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Использования промокодов (для лимита на клиента)
+CREATE TABLE IF NOT EXISTS promo_code_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER REFERENCES promo_codes(id) ON DELETE CASCADE,
+    client_id INTEGER REFERENCES clients(id) ON DELETE CASCADE,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE CASCADE,
+    redeemed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Данные клиента для автоматических скидок
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS is_student BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS family_id INTEGER; -- общий для членов одной семьи
+
+-- Скидка, примененная к абонементу
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS base_price DECIMAL(10, 2);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS discount_reason TEXT;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS promo_code_id INTEGER REFERENCES promo_codes(id) ON DELETE SET NULL;
+UPDATE subscriptions SET base_price = price WHERE base_price IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_promo_code_redemptions_client ON promo_code_redemptions(promo_code_id, client_id);
+CREATE INDEX IF NOT EXISTS idx_clients_family_id ON clients(family_id);
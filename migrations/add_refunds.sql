@@ -0,0 +1,8 @@
+-- Отмена абонементов с частичным возвратом
+-- Выполнить: psql -d fitness_club -f migrations/add_refunds.sql
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_payments_kind_status ON payments(kind, status);
@@ -0,0 +1,11 @@
+-- Дата получения оплаты и индексы для финансовых отчетов по журналу платежей.
+-- Выручка относится к периоду по paid_at — моменту проведения платежа, а не выставления.
+-- Выполнить: psql -d fitness_club -f migrations/add_reports.sql
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+
+-- Для уже проведенных платежей момент оплаты неизвестен — берем дату создания
+UPDATE payments SET paid_at = created_at WHERE status = 'paid' AND paid_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_payments_status_created_at ON payments(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_status_paid_at ON payments(status, paid_at);
@@ -0,0 +1,47 @@
+-- Смены сотрудников, недельный график и табель учета рабочего времени
+-- Выполнить: psql -d fitness_club -f migrations/add_shifts.sql
+
+-- Недельный график: повторяющиеся смены по дням недели (1 — понедельник, 7 — воскресенье)
+CREATE TABLE IF NOT EXISTS rota_entries (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    weekday INTEGER NOT NULL CHECK (weekday BETWEEN 1 AND 7),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL CHECK (end_time > start_time),
+    role VARCHAR(20) NOT NULL CHECK (role IN ('reception', 'trainer', 'cleaner', 'manager', 'other')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Смены на конкретные даты; создаются вручную или по недельному графику
+CREATE TABLE IF NOT EXISTS shifts (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('reception', 'trainer', 'cleaner', 'manager', 'other')),
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL CHECK (end_time > start_time),
+    rota_entry_id INTEGER REFERENCES rota_entries(id) ON DELETE SET NULL,
+    notes TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Табель: фактические отметки прихода и ухода
+CREATE TABLE IF NOT EXISTS time_entries (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    shift_id INTEGER REFERENCES shifts(id) ON DELETE SET NULL,
+    clock_in TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    clock_out TIMESTAMP CHECK (clock_out > clock_in),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    note TEXT,
+    reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rota_entries_employee ON rota_entries(employee_id);
+CREATE INDEX IF NOT EXISTS idx_shifts_employee_start ON shifts(employee_id, start_time);
+CREATE INDEX IF NOT EXISTS idx_shifts_start ON shifts(start_time);
+CREATE INDEX IF NOT EXISTS idx_time_entries_employee_clock_in ON time_entries(employee_id, clock_in);
+-- У сотрудника может быть только одна незакрытая отметка
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_open ON time_entries(employee_id) WHERE clock_out IS NULL;
@@ -0,0 +1,13 @@
+-- Мягкое удаление пользователей, клиентов, тренировок и абонементов:
+-- записи помечаются deleted_at и скрываются из списков, история сохраняется
+-- Выполнить: psql -d fitness_club -f migrations/add_soft_delete.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE trainings ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_not_deleted ON users(id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_clients_not_deleted ON clients(id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_trainings_start_time_not_deleted ON trainings(start_time) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_client_not_deleted ON subscriptions(client_id) WHERE deleted_at IS NULL;
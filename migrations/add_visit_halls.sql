@@ -0,0 +1,7 @@
+-- Зал посещения для счетчика загруженности клуба
+-- Выполнить: psql -d fitness_club -f migrations/add_visit_halls.sql
+
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS hall VARCHAR(100) NOT NULL DEFAULT 'gym'
+    CHECK (hall IN ('pilates', 'yoga', 'gym', 'dance', 'cardio'));
+
+CREATE INDEX IF NOT EXISTS idx_visits_open_hall ON visits(hall) WHERE checked_out_at IS NULL;
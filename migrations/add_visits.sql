@@ -0,0 +1,18 @@
+-- Журнал посещений клуба (вход и выход по QR-коду)
+-- Выполнить: psql -d fitness_club -f migrations/add_visits.sql
+
+CREATE TABLE IF NOT EXISTS visits (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    subscription_id INTEGER REFERENCES subscriptions(id) ON DELETE SET NULL,
+    checked_in_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    checked_out_at TIMESTAMP,
+    checked_in_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    checked_out_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    CHECK (checked_out_at IS NULL OR checked_out_at >= checked_in_at)
+);
+
+-- У клиента может быть только одно незакрытое посещение
+CREATE UNIQUE INDEX IF NOT EXISTS idx_visits_open_client ON visits(client_id) WHERE checked_out_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_visits_client_id ON visits(client_id);
+CREATE INDEX IF NOT EXISTS idx_visits_checked_in_at ON visits(checked_in_at);
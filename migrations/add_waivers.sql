@@ -0,0 +1,33 @@
+-- Экстренные контакты, медицинские сведения и отказ от претензий с анкетой здоровья
+-- Выполнить: psql -d fitness_club -f migrations/add_waivers.sql
+
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS emergency_contact_name VARCHAR(255);
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS emergency_contact_phone VARCHAR(20);
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS emergency_contact_relation VARCHAR(100);
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS medical_notes TEXT;
+
+-- Редакции отказа от претензий; действующая — последняя опубликованная
+CREATE TABLE IF NOT EXISTS waiver_versions (
+    id SERIAL PRIMARY KEY,
+    version VARCHAR(50) UNIQUE NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    health_questions JSONB NOT NULL DEFAULT '[]', -- вопросы анкеты здоровья
+    published_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Подписи клиентов; при новой редакции клиент подписывает заново
+CREATE TABLE IF NOT EXISTS client_waivers (
+    id SERIAL PRIMARY KEY,
+    client_id INTEGER NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    waiver_version_id INTEGER NOT NULL REFERENCES waiver_versions(id),
+    signed_name VARCHAR(255) NOT NULL,
+    health_answers JSONB NOT NULL DEFAULT '[]',
+    signed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE (client_id, waiver_version_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waiver_versions_published_at ON waiver_versions(published_at);
+CREATE INDEX IF NOT EXISTS idx_client_waivers_client_id ON client_waivers(client_id);